@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
+	"github.com/adragomir/linuxcncgo/gcode"
+	"github.com/adragomir/linuxcncgo/gcode/parser"
 	"github.com/adragomir/linuxcncgo/machine"
 )
 
@@ -30,6 +33,13 @@ func (c *cli) Start() {
 		neededState = "launcher"
 	case "stop":
 		neededState = "launcher"
+	case "check":
+		// does not need a machine
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "usage: %s check <file.ngc>...\n", os.Args[0])
+			os.Exit(2)
+		}
+		os.Exit(checkPrograms(os.Args[2:]))
 	}
 
 	c.services.AddCb("launcherUpdate", func(l *machine.Launcher) {
@@ -48,6 +58,28 @@ func (c *cli) Start() {
 	})
 }
 
+// checkPrograms simulates every program and prints the problems found,
+// the exit status is 1 if any program has errors
+func checkPrograms(paths []string) int {
+	status := 0
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			status = 1
+			continue
+		}
+		_, _, _, diagnostics := gcode.SimulateGCode(string(contents), nil, gcode.UnitsMM, nil, nil)
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%s\n", path, d)
+			if d.Severity == parser.SeverityError {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
 func (c *cli) Wait() {
 	<-c.done
 }
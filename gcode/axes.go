@@ -0,0 +1,82 @@
+package gcode
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// ExtraAxes is the position of the axes besides X, Y and Z: the rotary
+// axes A, B, C in degrees and the secondary linear axes U, V, W
+type ExtraAxes [6]float32
+
+var EXTRA_AXIS_NAMES = []string{"A", "B", "C", "U", "V", "W"}
+
+// Rotary is the position of A, B, C
+func (e ExtraAxes) Rotary() mgl32.Vec3 {
+	return mgl32.Vec3{e[0], e[1], e[2]}
+}
+
+// Linear is the position of U, V, W
+func (e ExtraAxes) Linear() mgl32.Vec3 {
+	return mgl32.Vec3{e[3], e[4], e[5]}
+}
+
+func (e ExtraAxes) lerp(to ExtraAxes, ratio float32) ExtraAxes {
+	out := ExtraAxes{}
+	for i := range e {
+		out[i] = e[i]*(1-ratio) + to[i]*ratio
+	}
+	return out
+}
+
+// extraPoint applies the A, B, C, U, V, W words of a move, in the active
+// distance mode. Work offsets only apply to X, Y, Z
+func (ms *MachineState) extraPoint(move Move) ExtraAxes {
+	out := ms.extra
+	incremental := ms.modes[GroupDistance] == "91"
+	for i, axis := range EXTRA_AXIS_NAMES {
+		val, ok := move[axis]
+		if !ok {
+			continue
+		}
+		if incremental {
+			out[i] += val
+		} else {
+			out[i] = val
+		}
+	}
+	return out
+}
+
+// rotaryTransform maps a tool position to the coordinates of the part on
+// the rotary table. A turns the part about an axis parallel to X through
+// center, B parallel to Y and C parallel to Z; the part is turned back by
+// C, then B, then A, so that the path is drawn wrapped around it
+func rotaryTransform(p mgl32.Vec3, rotary mgl32.Vec3, center mgl32.Vec3) mgl32.Vec3 {
+	if rotary == (mgl32.Vec3{}) {
+		return p
+	}
+	rot := mgl32.Rotate3DX(-mgl32.DegToRad(rotary[0])).
+		Mul3(mgl32.Rotate3DY(-mgl32.DegToRad(rotary[1]))).
+		Mul3(mgl32.Rotate3DZ(-mgl32.DegToRad(rotary[2])))
+	return rot.Mul3x1(p.Sub(center)).Add(center)
+}
+
+// degrees of rotation drawn as one straight segment
+const rotaryStep = float32(2.0)
+
+// rotarySteps is the number of segments needed to draw the rotation of a
+// fragment as a curve
+func (f *Fragment) rotarySteps() int {
+	turn := f.toExtra.Rotary().Sub(f.fromExtra.Rotary())
+	max := mgl32.Max(mgl32.Abs(turn[0]), mgl32.Max(mgl32.Abs(turn[1]), mgl32.Abs(turn[2])))
+	return int(math.Ceil(float64(max / rotaryStep)))
+}
+
+// partPointAtRatio is the point of the fragment at ratio, in the
+// coordinates of the part
+func (f *Fragment) partPointAtRatio(ratio float32, center mgl32.Vec3) mgl32.Vec3 {
+	p := COMPONENT_TYPES[f.tp].PointAtRatio(f, ratio)
+	return rotaryTransform(p, f.fromExtra.lerp(f.toExtra, ratio).Rotary(), center)
+}
@@ -0,0 +1,421 @@
+package gcode
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type CompensationSide int
+
+const (
+	CompensationOff CompensationSide = iota
+	CompensationLeft
+	CompensationRight
+)
+
+const compensationEpsilon = 0.00001
+
+type GougeError struct {
+	LineNo int
+	Msg    string
+}
+
+func (e *GougeError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.LineNo, e.Msg)
+}
+
+// compElement is a programmed fragment together with its offset end points
+type compElement struct {
+	f      *Fragment
+	from   mgl32.Vec3
+	to     mgl32.Vec3
+	dir    mgl32.Vec2
+	radius float32
+}
+
+// Compensation offsets the tool path by the tool radius (G41 / G42) in the
+// active plane. Every fragment is held back until the next one arrives, so
+// that the corner between them can be resolved: concave corners are trimmed
+// to the intersection of the offset paths, convex corners get an arc around
+// the programmed corner
+type Compensation struct {
+	side     CompensationSide
+	radius   float32
+	plane    Plane
+	pending  *compElement
+	normal   []*Fragment
+	position mgl32.Vec3
+	emit     func(*Fragment)
+
+	Errors []error
+}
+
+func NewCompensation(position mgl32.Vec3, emit func(*Fragment)) *Compensation {
+	return &Compensation{
+		side:     CompensationOff,
+		plane:    XY_PLANE,
+		normal:   make([]*Fragment, 0),
+		position: position,
+		emit:     emit,
+		Errors:   make([]error, 0),
+	}
+}
+
+func (c *Compensation) Start(side CompensationSide, radius float32, plane Plane) {
+	if c.side != CompensationOff && (c.side != side || c.radius != radius) {
+		c.flush()
+	}
+	c.side = side
+	c.radius = radius
+	c.plane = plane
+}
+
+// Stop ends compensation, the next move is the exit move from the offset
+// path back to the programmed path
+func (c *Compensation) Stop() {
+	c.flush()
+	c.side = CompensationOff
+}
+
+func (c *Compensation) Push(f *Fragment) {
+	if c.side == CompensationOff {
+		c.passThrough(f)
+		return
+	}
+	if f.tp == LineFragmentType && c.inPlane(f.to).Sub(c.inPlane(f.from)).Len() < compensationEpsilon {
+		// moves along the plane normal are kept at the end of the pending move
+		if c.pending == nil {
+			c.passThrough(f)
+		} else {
+			c.normal = append(c.normal, f)
+		}
+		return
+	}
+	e := c.newElement(f)
+	if c.pending == nil {
+		// entry move, from the uncompensated position to the offset path
+		if f.tp == ArcFragmentType {
+			c.gouge(f.lineNo, "cutter compensation entry move must be a straight line")
+			c.output(c.join(c.position, e.from, f))
+		} else {
+			e.from = c.position
+			if dir := c.inPlane(e.to).Sub(c.inPlane(e.from)); dir.Len() > compensationEpsilon {
+				e.dir = dir.Normalize()
+			}
+		}
+		c.pending = e
+		return
+	}
+	arc := c.corner(c.pending, e)
+	c.flush()
+	if arc != nil {
+		c.output(arc)
+	}
+	c.pending = e
+}
+
+func (c *Compensation) flush() {
+	if c.pending == nil {
+		return
+	}
+	c.emitElement(c.pending)
+	end := c.inPlane(c.pending.to)
+	for _, f := range c.normal {
+		out := *f
+		out.from = c.fromPlane(end, f.from[c.plane.lastCoord])
+		out.to = c.fromPlane(end, f.to[c.plane.lastCoord])
+		out.RunFragments = make([]*RunFragment, 0)
+		out.RunData = make(map[SpeedType]SpeedData)
+		c.output(&out)
+	}
+	c.normal = c.normal[:0]
+	c.pending = nil
+}
+
+func (c *Compensation) passThrough(f *Fragment) {
+	if c.inPlane(f.from).Sub(c.inPlane(c.position)).Len() > compensationEpsilon {
+		if f.tp == LineFragmentType {
+			f.from = c.position
+		} else {
+			c.output(c.join(c.position, f.from, f))
+		}
+	}
+	c.output(f)
+}
+
+func (c *Compensation) output(f *Fragment) {
+	c.position = f.to
+	c.emit(f)
+}
+
+func (c *Compensation) gouge(lineNo int, msg string) {
+	c.Errors = append(c.Errors, &GougeError{LineNo: lineNo, Msg: msg})
+}
+
+func (c *Compensation) inPlane(p mgl32.Vec3) mgl32.Vec2 {
+	return mgl32.Vec2{p[c.plane.firstCoord], p[c.plane.secondCoord]}
+}
+
+func (c *Compensation) fromPlane(p mgl32.Vec2, last float32) mgl32.Vec3 {
+	out := mgl32.Vec3{}
+	out[c.plane.firstCoord] = p[0]
+	out[c.plane.secondCoord] = p[1]
+	out[c.plane.lastCoord] = last
+	return out
+}
+
+// offset is the signed distance of the tool center to the left of the path
+func (c *Compensation) offset() float32 {
+	if c.side == CompensationRight {
+		return -c.radius
+	}
+	return c.radius
+}
+
+func leftNormal(t mgl32.Vec2) mgl32.Vec2 {
+	return mgl32.Vec2{-t[1], t[0]}
+}
+
+func cross2(a, b mgl32.Vec2) float32 {
+	return a[0]*b[1] - a[1]*b[0]
+}
+
+// tangent of a programmed fragment in the plane, at its start or at its end
+func (c *Compensation) tangent(f *Fragment, end bool) mgl32.Vec2 {
+	if f.tp == LineFragmentType {
+		return c.inPlane(f.to).Sub(c.inPlane(f.from)).Normalize()
+	}
+	p := f.from
+	if end {
+		p = f.to
+	}
+	u := c.inPlane(p).Sub(f.center).Normalize()
+	if f.angularDistance < 0 {
+		return mgl32.Vec2{u[1], -u[0]}
+	}
+	return mgl32.Vec2{-u[1], u[0]}
+}
+
+func (c *Compensation) elementTangent(e *compElement, end bool) mgl32.Vec2 {
+	if e.f.tp == LineFragmentType {
+		return e.dir
+	}
+	return c.tangent(e.f, end)
+}
+
+func (c *Compensation) offsetPoint(p mgl32.Vec3, t mgl32.Vec2) mgl32.Vec3 {
+	return c.fromPlane(c.inPlane(p).Add(leftNormal(t).Mul(c.offset())), p[c.plane.lastCoord])
+}
+
+func (c *Compensation) newElement(f *Fragment) *compElement {
+	e := &compElement{
+		f:    f,
+		from: c.offsetPoint(f.from, c.tangent(f, false)),
+		to:   c.offsetPoint(f.to, c.tangent(f, true)),
+	}
+	if f.tp == LineFragmentType {
+		e.dir = c.tangent(f, false)
+		return e
+	}
+	dirSign := float32(1.0)
+	if f.angularDistance < 0 {
+		dirSign = -1.0
+	}
+	e.radius = f.radius - c.offset()*dirSign
+	if e.radius <= compensationEpsilon {
+		c.gouge(f.lineNo, "tool radius is larger than the arc radius")
+		e.radius = f.radius
+		e.from = f.from
+		e.to = f.to
+	}
+	return e
+}
+
+// corner joins the end of a to the start of b, trimming both on a concave
+// corner or returning the arc to insert on a convex one
+func (c *Compensation) corner(a, b *compElement) *Fragment {
+	ta := c.elementTangent(a, true)
+	tb := c.elementTangent(b, false)
+	turn := cross2(ta, tb)
+	straight := mgl32.Abs(turn) < compensationEpsilon
+	if straight && ta.Dot(tb) > 0 {
+		b.from = a.to
+		return nil
+	}
+	corner := b.f.from
+	last := corner[c.plane.lastCoord]
+	if straight || turn*c.offset() < 0 {
+		if c.inPlane(a.to).Sub(c.inPlane(b.from)).Len() < compensationEpsilon {
+			b.from = a.to
+			return nil
+		}
+		na := c.inPlane(a.to).Sub(c.inPlane(corner))
+		nb := c.inPlane(b.from).Sub(c.inPlane(corner))
+		angle := float32(math.Atan2(float64(cross2(na, nb)), float64(na.Dot(nb))))
+		if straight {
+			angle = float32(math.Pi)
+			if c.offset() > 0 {
+				angle = -angle
+			}
+		}
+		center := c.inPlane(corner)
+		arc := &Fragment{
+			tp:              ArcFragmentType,
+			from:            c.fromPlane(c.inPlane(a.to), last),
+			to:              c.fromPlane(c.inPlane(b.from), last),
+			fromExtra:       b.f.fromExtra,
+			toExtra:         b.f.fromExtra,
+			plane:           c.plane,
+			center:          center,
+			centerInPlane:   center,
+			fromAngle:       float32(math.Atan2(float64(na[1]), float64(na[0]))),
+			angularDistance: angle,
+			radius:          c.radius,
+			feedRate:        b.f.feedRate,
+			lineNo:          b.f.lineNo,
+			speedTag:        b.f.speedTag,
+
+			RunFragments: make([]*RunFragment, 0),
+			RunData:      make(map[SpeedType]SpeedData),
+		}
+		return arc
+	}
+	points := c.intersect(a, b)
+	if len(points) == 0 {
+		c.gouge(b.f.lineNo, "no intersection between compensated moves")
+		b.from = a.to
+		return nil
+	}
+	best := points[0]
+	for _, p := range points[1:] {
+		if p.Sub(c.inPlane(corner)).Len() < best.Sub(c.inPlane(corner)).Len() {
+			best = p
+		}
+	}
+	a.to = c.fromPlane(best, a.f.to[c.plane.lastCoord])
+	b.from = c.fromPlane(best, b.f.from[c.plane.lastCoord])
+	return nil
+}
+
+func (c *Compensation) intersect(a, b *compElement) []mgl32.Vec2 {
+	switch {
+	case a.f.tp == LineFragmentType && b.f.tp == LineFragmentType:
+		return intersectLines(c.inPlane(a.from), a.dir, c.inPlane(b.from), b.dir)
+	case a.f.tp == LineFragmentType:
+		return intersectLineCircle(c.inPlane(a.from), a.dir, b.f.center, b.radius)
+	case b.f.tp == LineFragmentType:
+		return intersectLineCircle(c.inPlane(b.from), b.dir, a.f.center, a.radius)
+	}
+	return intersectCircles(a.f.center, a.radius, b.f.center, b.radius)
+}
+
+func intersectLines(p1, d1, p2, d2 mgl32.Vec2) []mgl32.Vec2 {
+	den := cross2(d1, d2)
+	if mgl32.Abs(den) < compensationEpsilon {
+		return nil
+	}
+	t := cross2(p2.Sub(p1), d2) / den
+	return []mgl32.Vec2{p1.Add(d1.Mul(t))}
+}
+
+func intersectLineCircle(p, d, center mgl32.Vec2, radius float32) []mgl32.Vec2 {
+	f := p.Sub(center)
+	b := f.Dot(d)
+	disc := b*b - (f.Dot(f) - radius*radius)
+	if disc < 0 {
+		return nil
+	}
+	sq := mgl32.Sqrt(disc)
+	return []mgl32.Vec2{p.Add(d.Mul(-b - sq)), p.Add(d.Mul(-b + sq))}
+}
+
+func intersectCircles(c1 mgl32.Vec2, r1 float32, c2 mgl32.Vec2, r2 float32) []mgl32.Vec2 {
+	delta := c2.Sub(c1)
+	d := delta.Len()
+	if d < compensationEpsilon || d > r1+r2 || d < mgl32.Abs(r1-r2) {
+		return nil
+	}
+	a := (r1*r1 - r2*r2 + d*d) / (2 * d)
+	h := mgl32.Sqrt(mgl32.Max(0, r1*r1-a*a))
+	m := c1.Add(delta.Mul(a / d))
+	perp := leftNormal(delta.Mul(1 / d)).Mul(h)
+	return []mgl32.Vec2{m.Add(perp), m.Sub(perp)}
+}
+
+func (c *Compensation) line(from, to mgl32.Vec3, f *Fragment) *Fragment {
+	return &Fragment{
+		tp:        LineFragmentType,
+		from:      from,
+		to:        to,
+		fromExtra: f.fromExtra,
+		toExtra:   f.toExtra,
+		feedRate:  f.feedRate,
+		lineNo:    f.lineNo,
+		speedTag:  f.speedTag,
+
+		RunFragments: make([]*RunFragment, 0),
+		RunData:      make(map[SpeedType]SpeedData),
+	}
+}
+
+// join is a straight move to the start of f, the extra axes stay where f
+// starts
+func (c *Compensation) join(from, to mgl32.Vec3, f *Fragment) *Fragment {
+	out := c.line(from, to, f)
+	out.toExtra = f.fromExtra
+	return out
+}
+
+func (c *Compensation) emitElement(e *compElement) {
+	f := e.f
+	if f.tp == LineFragmentType {
+		out := c.line(e.from, e.to, f)
+		out.dwell = f.dwell
+		if c.inPlane(e.to).Sub(c.inPlane(e.from)).Dot(e.dir) < 0 {
+			c.gouge(f.lineNo, "compensated move reverses direction, the tool would gouge")
+		}
+		c.output(out)
+		return
+	}
+	from := c.inPlane(e.from).Sub(f.center)
+	to := c.inPlane(e.to).Sub(f.center)
+	fromAngle := float32(math.Atan2(float64(from[1]), float64(from[0])))
+	angle := float32(math.Atan2(float64(to[1]), float64(to[0]))) - fromAngle
+	if f.angularDistance > 0 {
+		for angle <= 0 {
+			angle += 2 * math.Pi
+		}
+	} else {
+		for angle >= 0 {
+			angle -= 2 * math.Pi
+		}
+	}
+	full := mgl32.Abs(f.angularDistance) > 2*math.Pi-0.001
+	if full && (mgl32.Abs(angle) < 0.001 || mgl32.Abs(angle) > 2*math.Pi-0.001) {
+		angle = f.angularDistance
+	} else if mgl32.Abs(angle) > mgl32.Abs(f.angularDistance)+0.001 {
+		c.gouge(f.lineNo, "compensated arc reverses direction, the tool would gouge")
+	}
+	c.output(&Fragment{
+		tp:              ArcFragmentType,
+		from:            e.from,
+		to:              e.to,
+		fromExtra:       f.fromExtra,
+		toExtra:         f.toExtra,
+		plane:           f.plane,
+		center:          f.center,
+		centerInPlane:   f.centerInPlane,
+		fromAngle:       fromAngle,
+		angularDistance: angle,
+		radius:          e.radius,
+		feedRate:        f.feedRate,
+		lineNo:          f.lineNo,
+		speedTag:        f.speedTag,
+		dwell:           f.dwell,
+
+		RunFragments: make([]*RunFragment, 0),
+		RunData:      make(map[SpeedType]SpeedData),
+	})
+}
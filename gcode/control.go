@@ -0,0 +1,447 @@
+package gcode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+)
+
+// a single loop stops the simulation after this many iterations, so that a
+// program which never terminates does not hang the preview
+const maxLoopIterations = 100000
+
+// maximum nesting of O-word calls, protects against runaway recursion
+const maxCallDepth = 64
+
+// controlBlock is an O-word block, from its opening line (SUB, IF, WHILE,
+// DO, REPEAT) to its closing line. Indices are into program.lines
+type controlBlock struct {
+	label    string
+	keyword  string
+	start    int
+	branches []int
+	end      int
+}
+
+func (b *controlBlock) isLoop() bool {
+	return b.keyword == "WHILE" || b.keyword == "DO" || b.keyword == "REPEAT"
+}
+
+// controlError is an error in the O-word structure or while following it,
+// at a given line of the program
+type controlError struct {
+	lineNo int
+	msg    string
+}
+
+func (e *controlError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.lineNo, e.msg)
+}
+
+func newControlError(lineNo int, format string, args ...interface{}) *controlError {
+	return &controlError{lineNo: lineNo, msg: fmt.Sprintf(format, args...)}
+}
+
+type callFrame struct {
+	sub      *controlBlock
+	returnPc int
+	saved    *paramScope
+}
+
+type program struct {
+	lines  []parser.LineAst
+	blocks map[int]*controlBlock
+	subs   map[string]*controlBlock
+	// lines that break the O-word structure, they do not run
+	broken map[int]bool
+
+	frames     []callFrame
+	iterations map[int]int
+	repeats    map[int]int
+}
+
+func normalizeLabel(label string) string {
+	return strings.ToLower(label)
+}
+
+func owordOf(line parser.LineAst) (parser.OWordExpr, bool) {
+	for _, e := range line.Ast {
+		if ow, ok := e.(parser.OWordExpr); ok {
+			return ow, true
+		}
+	}
+	return parser.OWordExpr{}, false
+}
+
+// newProgram matches the O-word blocks of the parsed lines, so that
+// the executor can jump between them. A line that breaks the structure is
+// reported and skipped, a block that is never closed is reported and the
+// program stops where it opens
+func newProgram(lines []parser.LineAst) (*program, []error) {
+	p := &program{
+		lines:      lines,
+		blocks:     make(map[int]*controlBlock),
+		subs:       make(map[string]*controlBlock),
+		broken:     make(map[int]bool),
+		iterations: make(map[int]int),
+		repeats:    make(map[int]int),
+	}
+	errs := make([]error, 0)
+	open := make([]*controlBlock, 0)
+	top := func(keyword string, label string) *controlBlock {
+		if len(open) == 0 {
+			return nil
+		}
+		b := open[len(open)-1]
+		if b.keyword != keyword || b.label != label {
+			return nil
+		}
+		return b
+	}
+	for i, line := range lines {
+		ow, ok := owordOf(line)
+		if !ok {
+			continue
+		}
+		label := normalizeLabel(ow.Label)
+		switch ow.Keyword {
+		case "SUB", "IF", "DO", "REPEAT":
+			b := &controlBlock{label: label, keyword: ow.Keyword, start: i}
+			open = append(open, b)
+			p.blocks[i] = b
+		case "WHILE":
+			// a WHILE closes a DO with the same label
+			if b := top("DO", label); b != nil {
+				b.end = i
+				p.blocks[i] = b
+				open = open[:len(open)-1]
+				continue
+			}
+			b := &controlBlock{label: label, keyword: ow.Keyword, start: i}
+			open = append(open, b)
+			p.blocks[i] = b
+		case "ELSEIF", "ELSE":
+			b := top("IF", label)
+			if b == nil {
+				errs = append(errs, newControlError(line.LineNo, "O%s %s without IF", ow.Label, ow.Keyword))
+				p.broken[i] = true
+				continue
+			}
+			b.branches = append(b.branches, i)
+			p.blocks[i] = b
+		case "ENDSUB", "ENDIF", "ENDWHILE", "ENDREPEAT":
+			keyword := strings.TrimPrefix(ow.Keyword, "END")
+			b := top(keyword, label)
+			if b == nil {
+				errs = append(errs, newControlError(line.LineNo, "O%s %s without %s", ow.Label, ow.Keyword, keyword))
+				p.broken[i] = true
+				continue
+			}
+			b.end = i
+			p.blocks[i] = b
+			open = open[:len(open)-1]
+			if keyword == "SUB" {
+				p.subs[label] = b
+			}
+		case "BREAK", "CONTINUE", "RETURN":
+			var found *controlBlock
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j].label != label {
+					continue
+				}
+				if (ow.Keyword == "RETURN" && open[j].keyword == "SUB") || (ow.Keyword != "RETURN" && open[j].isLoop()) {
+					found = open[j]
+				}
+				break
+			}
+			if found == nil {
+				errs = append(errs, newControlError(line.LineNo, "O%s %s outside of its block", ow.Label, ow.Keyword))
+				p.broken[i] = true
+				continue
+			}
+			p.blocks[i] = found
+		case "CALL":
+			// resolved at run time, the sub may be defined after the call
+		}
+	}
+	if len(open) > 0 {
+		for _, b := range open {
+			errs = append(errs, newControlError(lines[b.start].LineNo, "O%s %s is never closed", b.label, b.keyword))
+		}
+		// everything after the first open block is inside it
+		end := open[0].start
+		p.lines = lines[:end]
+		for i := range p.blocks {
+			if i >= end {
+				delete(p.blocks, i)
+			}
+		}
+		for label, b := range p.subs {
+			if b.start >= end {
+				delete(p.subs, label)
+			}
+		}
+	}
+	return p, errs
+}
+
+func (p *program) condition(ow parser.OWordExpr, params *Parameters) (bool, error) {
+	if len(ow.Args) != 1 {
+		return false, fmt.Errorf("O%s %s needs one argument", ow.Label, ow.Keyword)
+	}
+	val, err := params.Eval(ow.Args[0])
+	if err != nil {
+		return false, err
+	}
+	return val != 0, nil
+}
+
+// iterate counts one more pass through a loop
+func (p *program) iterate(b *controlBlock) error {
+	p.iterations[b.start]++
+	if p.iterations[b.start] > maxLoopIterations {
+		return fmt.Errorf("O%s %s exceeded %d iterations", b.label, b.keyword, maxLoopIterations)
+	}
+	return nil
+}
+
+func (p *program) exitLoop(b *controlBlock) int {
+	delete(p.iterations, b.start)
+	delete(p.repeats, b.start)
+	return b.end + 1
+}
+
+// run executes the lines in order, following the O-word control flow
+func (p *program) run(ms *MachineState) error {
+	pc := 0
+	for pc < len(p.lines) {
+		if p.broken[pc] {
+			pc++
+			continue
+		}
+		line := p.lines[pc]
+		ow, ok := owordOf(line)
+		if !ok {
+			ms.executeLine(line)
+			if ms.programEnd {
+				return nil
+			}
+			pc++
+			continue
+		}
+		ms.lineNo = line.LineNo
+		next, err := p.step(pc, ow, ms)
+		if err != nil {
+			return newControlError(line.LineNo, "%v", err)
+		}
+		pc = next
+	}
+	return nil
+}
+
+func (p *program) step(pc int, ow parser.OWordExpr, ms *MachineState) (int, error) {
+	block := p.blocks[pc]
+	switch ow.Keyword {
+	case "SUB":
+		// definitions are skipped, they only run through CALL
+		return block.end + 1, nil
+	case "CALL":
+		sub, ok := p.subs[normalizeLabel(ow.Label)]
+		if !ok {
+			return 0, fmt.Errorf("unknown subroutine O%s", ow.Label)
+		}
+		if len(p.frames) >= maxCallDepth {
+			return 0, fmt.Errorf("call depth exceeded %d", maxCallDepth)
+		}
+		args := make([]float64, len(ow.Args))
+		for i, arg := range ow.Args {
+			val, err := ms.params.Eval(arg)
+			if err != nil {
+				return 0, err
+			}
+			args[i] = val
+		}
+		saved, err := ms.params.pushScope(args)
+		if err != nil {
+			return 0, err
+		}
+		p.frames = append(p.frames, callFrame{sub: sub, returnPc: pc + 1, saved: saved})
+		return sub.start + 1, nil
+	case "RETURN", "ENDSUB":
+		if len(p.frames) == 0 {
+			return 0, fmt.Errorf("O%s %s outside of a call", ow.Label, ow.Keyword)
+		}
+		var value float64
+		hasValue := len(ow.Args) > 0
+		if hasValue {
+			val, err := ms.params.Eval(ow.Args[0])
+			if err != nil {
+				return 0, err
+			}
+			value = val
+		}
+		frame := p.frames[len(p.frames)-1]
+		p.frames = p.frames[:len(p.frames)-1]
+		ms.params.popScope(frame.saved)
+		if hasValue {
+			ms.params.Set(parser.ParamExpr{Type: parser.VarType, Val: "_value"}, value)
+		}
+		// loops left through RETURN start fresh on the next call
+		for start := range p.iterations {
+			if start > frame.sub.start && start < frame.sub.end {
+				delete(p.iterations, start)
+			}
+		}
+		for start := range p.repeats {
+			if start > frame.sub.start && start < frame.sub.end {
+				delete(p.repeats, start)
+			}
+		}
+		return frame.returnPc, nil
+	case "IF":
+		cond, err := p.condition(ow, ms.params)
+		if err != nil {
+			return 0, err
+		}
+		if cond {
+			return pc + 1, nil
+		}
+		for _, branch := range block.branches {
+			bow, _ := owordOf(p.lines[branch])
+			if bow.Keyword == "ELSE" {
+				return branch + 1, nil
+			}
+			cond, err := p.condition(bow, ms.params)
+			if err != nil {
+				return 0, err
+			}
+			if cond {
+				return branch + 1, nil
+			}
+		}
+		return block.end + 1, nil
+	case "ELSEIF", "ELSE":
+		// reached at the end of the previous branch
+		return block.end + 1, nil
+	case "ENDIF":
+		return pc + 1, nil
+	case "DO":
+		return pc + 1, nil
+	case "WHILE":
+		cond, err := p.condition(ow, ms.params)
+		if err != nil {
+			return 0, err
+		}
+		if block.keyword == "DO" {
+			if !cond {
+				return p.exitLoop(block), nil
+			}
+			if err := p.iterate(block); err != nil {
+				return 0, err
+			}
+			return block.start + 1, nil
+		}
+		if !cond {
+			return p.exitLoop(block), nil
+		}
+		if err := p.iterate(block); err != nil {
+			return 0, err
+		}
+		return pc + 1, nil
+	case "ENDWHILE", "ENDREPEAT":
+		return block.start, nil
+	case "REPEAT":
+		remaining, active := p.repeats[pc]
+		if !active {
+			if len(ow.Args) != 1 {
+				return 0, fmt.Errorf("O%s REPEAT needs one argument", ow.Label)
+			}
+			val, err := ms.params.Eval(ow.Args[0])
+			if err != nil {
+				return 0, err
+			}
+			remaining = int(val)
+		}
+		if remaining <= 0 {
+			return p.exitLoop(block), nil
+		}
+		if err := p.iterate(block); err != nil {
+			return 0, err
+		}
+		p.repeats[pc] = remaining - 1
+		return pc + 1, nil
+	case "BREAK":
+		return p.exitLoop(block), nil
+	case "CONTINUE":
+		if block.keyword == "DO" {
+			return block.end, nil
+		}
+		return block.start, nil
+	}
+	return 0, fmt.Errorf("unsupported O-word %s", ow.Keyword)
+}
+
+// streamProgram runs a program as it is read. Lines outside of O-word
+// blocks run right away, a block is kept until it is closed and then runs
+// together with the subroutines defined so far. Unlike a whole program, a
+// subroutine must be defined before it is called
+type streamProgram struct {
+	subs    []parser.LineAst
+	pending []parser.LineAst
+	open    []string
+}
+
+func (sp *streamProgram) push(line parser.LineAst, ms *MachineState) error {
+	ow, ok := owordOf(line)
+	if !ok && len(sp.open) == 0 {
+		ms.executeLine(line)
+		return nil
+	}
+	sp.pending = append(sp.pending, line)
+	if ok {
+		label := normalizeLabel(ow.Label)
+		switch ow.Keyword {
+		case "SUB", "IF", "DO", "REPEAT":
+			sp.open = append(sp.open, ow.Keyword+" "+label)
+		case "WHILE":
+			if len(sp.open) > 0 && sp.open[len(sp.open)-1] == "DO "+label {
+				sp.open = sp.open[:len(sp.open)-1]
+			} else {
+				sp.open = append(sp.open, ow.Keyword+" "+label)
+			}
+		case "ENDSUB", "ENDIF", "ENDWHILE", "ENDREPEAT":
+			if len(sp.open) > 0 {
+				sp.open = sp.open[:len(sp.open)-1]
+			}
+		}
+	}
+	if len(sp.open) > 0 {
+		return nil
+	}
+	lines := make([]parser.LineAst, 0, len(sp.subs)+len(sp.pending))
+	lines = append(lines, sp.subs...)
+	lines = append(lines, sp.pending...)
+	prog, errs := newProgram(lines)
+	for _, err := range errs {
+		ms.reportControlError(err)
+	}
+	err := prog.run(ms)
+	if first, _ := owordOf(sp.pending[0]); first.Keyword == "SUB" {
+		sp.subs = append(sp.subs, sp.pending...)
+	}
+	sp.pending = nil
+	return err
+}
+
+// finish reports the blocks that are still open at the end of the
+// program, their lines do not run
+func (sp *streamProgram) finish(ms *MachineState) {
+	if len(sp.pending) == 0 {
+		return
+	}
+	_, errs := newProgram(sp.pending)
+	for _, err := range errs {
+		ms.reportControlError(err)
+	}
+}
@@ -0,0 +1,43 @@
+package gcode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+)
+
+// diagnostic codes of the simulator, parse errors use parser.CodeSyntax
+const (
+	CodeExpression   = "expression"
+	CodeControlFlow  = "control-flow"
+	CodeCannedCycle  = "canned-cycle"
+	CodeCompensation = "cutter-compensation"
+	CodeTool         = "tool"
+	CodeUnsupported  = "unsupported"
+	CodeModalGroup   = "modal-group"
+	CodeOffsets      = "offsets"
+	CodeArc          = "arc"
+)
+
+func (ms *MachineState) report(severity parser.Severity, code string, format string, args ...interface{}) {
+	ms.reportAt(ms.lineNo, severity, code, format, args...)
+}
+
+func (ms *MachineState) reportAt(lineNo int, severity parser.Severity, code string, format string, args ...interface{}) {
+	ms.diagnostics = append(ms.diagnostics, parser.Diagnostic{
+		Line:     lineNo + 1,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func sortDiagnostics(diagnostics []parser.Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+}
@@ -0,0 +1,101 @@
+package gcode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// diagnostic code of the moves past the soft limits of the machine
+const CodeSoftLimits = "soft-limits"
+
+// axes the envelope is checked on
+var ENVELOPE_AXES = []string{"X", "Y", "Z"}
+
+// Envelope is the travel of the machine between its soft limits, in machine
+// coordinates. An axis with Min >= Max is not limited
+type Envelope struct {
+	Min, Max mgl32.Vec3
+}
+
+// over is how far past the limits of an axis a point is, 0 if within
+func (e Envelope) over(p mgl32.Vec3, axis int) float32 {
+	if e.Min[axis] >= e.Max[axis] {
+		return 0
+	}
+	if p[axis] < e.Min[axis] {
+		return e.Min[axis] - p[axis]
+	}
+	if p[axis] > e.Max[axis] {
+		return p[axis] - e.Max[axis]
+	}
+	return 0
+}
+
+// LimitViolation is a line of the program that moves past the soft limits
+type LimitViolation struct {
+	// 0-based, as LineAst.LineNo
+	LineNo int
+	// the farthest the line goes past the limits of every axis
+	Over mgl32.Vec3
+	// the pieces of the simulated path of the line with an end outside the
+	// envelope, where they were simulated
+	Segments [][2]mgl32.Vec3
+}
+
+// Diagnostic reports the violation in the problems of the program
+func (v LimitViolation) Diagnostic() parser.Diagnostic {
+	axes := make([]string, 0, len(ENVELOPE_AXES))
+	for i, name := range ENVELOPE_AXES {
+		if v.Over[i] > 0 {
+			axes = append(axes, fmt.Sprintf("%s by %.4g", name, v.Over[i]))
+		}
+	}
+	return parser.Diagnostic{
+		Line:     v.LineNo + 1,
+		Severity: parser.SeverityError,
+		Code:     CodeSoftLimits,
+		Message:  "moves past the soft limits: " + strings.Join(axes, ", "),
+	}
+}
+
+// CheckEnvelope finds the lines of a simulated program that move outside
+// the envelope. shift moves the path before the check, when the work offsets
+// changed since the program was simulated
+func CheckEnvelope(segments []SimMap, envelope Envelope, shift mgl32.Vec3) []LimitViolation {
+	out := make([]LimitViolation, 0)
+	for _, sm := range segments {
+		var violation *LimitViolation
+		outside := make([]bool, len(sm.Segments))
+		for i, p := range sm.Segments {
+			for axis := range ENVELOPE_AXES {
+				over := envelope.over(p.Add(shift), axis)
+				if over <= 0 {
+					continue
+				}
+				outside[i] = true
+				if violation == nil {
+					// a line can be simulated in several fragments
+					if n := len(out); n > 0 && out[n-1].LineNo == sm.LineNo {
+						violation = &out[n-1]
+					} else {
+						out = append(out, LimitViolation{LineNo: sm.LineNo})
+						violation = &out[len(out)-1]
+					}
+				}
+				violation.Over[axis] = mgl32.Max(violation.Over[axis], over)
+			}
+		}
+		if violation == nil {
+			continue
+		}
+		for i := 1; i < len(sm.Segments); i++ {
+			if outside[i-1] || outside[i] {
+				violation.Segments = append(violation.Segments, [2]mgl32.Vec3{sm.Segments[i-1], sm.Segments[i]})
+			}
+		}
+	}
+	return out
+}
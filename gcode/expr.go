@@ -0,0 +1,306 @@
+package gcode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+)
+
+// equality tolerance used by EQ / NE, same as the RS274NGC interpreter
+const paramEqualTolerance = 0.0001
+
+type Parameters struct {
+	numbered map[int]float64
+	named    map[string]float64
+}
+
+func NewParameters() *Parameters {
+	return &Parameters{
+		numbered: make(map[int]float64),
+		named:    make(map[string]float64),
+	}
+}
+
+func normalizeParamName(name string) string {
+	return strings.ToLower(name)
+}
+
+// Get returns the value of a parameter. Unset numbered parameters read as 0,
+// unset named parameters are an error
+func (p *Parameters) Get(param parser.ParamExpr) (float64, error) {
+	switch param.Type {
+	case parser.NumType:
+		return p.numbered[param.Val.(int)], nil
+	default:
+		name := normalizeParamName(param.Val.(string))
+		if val, ok := p.named[name]; ok {
+			return val, nil
+		}
+		return 0, fmt.Errorf("named parameter #<%s> not defined", name)
+	}
+}
+
+func (p *Parameters) Set(param parser.ParamExpr, val float64) {
+	switch param.Type {
+	case parser.NumType:
+		p.numbered[param.Val.(int)] = val
+	default:
+		p.named[normalizeParamName(param.Val.(string))] = val
+	}
+}
+
+func (p *Parameters) Exists(param parser.ParamExpr) bool {
+	switch param.Type {
+	case parser.NumType:
+		_, ok := p.numbered[param.Val.(int)]
+		return ok
+	default:
+		_, ok := p.named[normalizeParamName(param.Val.(string))]
+		return ok
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+func (p *Parameters) Eval(e parser.Expr) (float64, error) {
+	switch expr := e.(type) {
+	case parser.ConstExpr:
+		return expr.Val, nil
+	case parser.ParamExpr:
+		return p.Get(expr)
+	case parser.BinaryExpr:
+		return p.evalBinary(expr)
+	case parser.FuncOp:
+		return p.evalFunc(expr)
+	}
+	return 0, fmt.Errorf("cannot evaluate expression %v", e)
+}
+
+func (p *Parameters) evalBinary(e parser.BinaryExpr) (float64, error) {
+	l, err := p.Eval(e.L)
+	if err != nil {
+		return 0, err
+	}
+	r, err := p.Eval(e.R)
+	if err != nil {
+		return 0, err
+	}
+	switch e.Op {
+	case parser.PowOp:
+		return math.Pow(l, r), nil
+	case parser.MulOp:
+		return l * r, nil
+	case parser.DivOp:
+		if r == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return l / r, nil
+	case parser.ModOp:
+		if r == 0 {
+			return 0, errors.New("modulo by zero")
+		}
+		res := math.Mod(l, r)
+		if res < 0 {
+			res += math.Abs(r)
+		}
+		return res, nil
+	case parser.PlusOp:
+		return l + r, nil
+	case parser.MinusOp:
+		return l - r, nil
+	case parser.EqOp:
+		return boolToFloat(math.Abs(l-r) < paramEqualTolerance), nil
+	case parser.NeOp:
+		return boolToFloat(math.Abs(l-r) >= paramEqualTolerance), nil
+	case parser.GtOp:
+		return boolToFloat(l > r), nil
+	case parser.GeOp:
+		return boolToFloat(l >= r), nil
+	case parser.LtOp:
+		return boolToFloat(l < r), nil
+	case parser.LeOp:
+		return boolToFloat(l <= r), nil
+	case parser.AndOp:
+		return boolToFloat(l != 0 && r != 0), nil
+	case parser.OrOp:
+		return boolToFloat(l != 0 || r != 0), nil
+	case parser.XorOp:
+		return boolToFloat((l != 0) != (r != 0)), nil
+	}
+	return 0, fmt.Errorf("unknown operator %s", e.Op)
+}
+
+func (p *Parameters) evalFunc(e parser.FuncOp) (float64, error) {
+	if e.Fct == "EXISTS" {
+		param, ok := e.Arg1.(parser.ParamExpr)
+		if !ok {
+			return 0, errors.New("EXISTS needs a parameter argument")
+		}
+		return boolToFloat(p.Exists(param)), nil
+	}
+	arg, err := p.Eval(e.Arg1)
+	if err != nil {
+		return 0, err
+	}
+	switch e.Fct {
+	case "ATAN":
+		arg2, err := p.Eval(e.Arg2)
+		if err != nil {
+			return 0, err
+		}
+		return radToDeg(math.Atan2(arg, arg2)), nil
+	case "ABS":
+		return math.Abs(arg), nil
+	case "ACOS":
+		if arg < -1 || arg > 1 {
+			return 0, fmt.Errorf("ACOS argument %f out of range", arg)
+		}
+		return radToDeg(math.Acos(arg)), nil
+	case "ASIN":
+		if arg < -1 || arg > 1 {
+			return 0, fmt.Errorf("ASIN argument %f out of range", arg)
+		}
+		return radToDeg(math.Asin(arg)), nil
+	case "COS":
+		return math.Cos(degToRad(arg)), nil
+	case "EXP":
+		return math.Exp(arg), nil
+	case "FIX":
+		return math.Floor(arg), nil
+	case "FUP":
+		return math.Ceil(arg), nil
+	case "ROUND":
+		return math.Round(arg), nil
+	case "LN":
+		if arg <= 0 {
+			return 0, fmt.Errorf("LN argument %f out of range", arg)
+		}
+		return math.Log(arg), nil
+	case "SIN":
+		return math.Sin(degToRad(arg)), nil
+	case "SQRT":
+		if arg < 0 {
+			return 0, fmt.Errorf("SQRT argument %f out of range", arg)
+		}
+		return math.Sqrt(arg), nil
+	case "TAN":
+		return math.Tan(degToRad(arg)), nil
+	}
+	return 0, fmt.Errorf("unknown function %s", e.Fct)
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180.0
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180.0 / math.Pi
+}
+
+// resolveLine evaluates every word of a line to a constant and applies
+// the parameter assignments. As in RS274NGC, assignments take effect only
+// after all the values on the line have been read
+func (p *Parameters) resolveLine(line []parser.Expr) ([]parser.Expr, error) {
+	out := make([]parser.Expr, 0, len(line))
+	assignments := make([]parser.AssignOp, 0)
+	values := make([]float64, 0)
+	for _, tmp := range line {
+		switch expr := tmp.(type) {
+		case parser.WordExpr:
+			if _, ok := expr.Val.(parser.ConstExpr); ok {
+				out = append(out, expr)
+				continue
+			}
+			val, err := p.Eval(expr.Val)
+			if err != nil {
+				return nil, fmt.Errorf("%s word: %w", expr.Word, err)
+			}
+			out = append(out, parser.WordExpr{
+				Word: expr.Word,
+				Val: parser.ConstExpr{
+					Val:    val,
+					RawVal: strconv.FormatFloat(val, 'f', -1, 64),
+				},
+			})
+		case parser.AssignOp:
+			val, err := p.Eval(expr.Val)
+			if err != nil {
+				return nil, fmt.Errorf("assignment to %s: %w", expr.Param, err)
+			}
+			assignments = append(assignments, expr)
+			values = append(values, val)
+		}
+	}
+	for i, a := range assignments {
+		p.Set(a.Param, values[i])
+	}
+	return out, nil
+}
+
+// numbered parameters #1 - #30 are local to a subroutine call
+const maxCallArgs = 30
+
+type paramScope struct {
+	numbered map[int]float64
+	named    map[string]float64
+}
+
+func isLocalName(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
+
+// pushScope saves the local parameters of the caller and sets #1 .. #n to
+// the call arguments. Named parameters starting with _ are global and are
+// shared with the subroutine
+func (p *Parameters) pushScope(args []float64) (*paramScope, error) {
+	if len(args) > maxCallArgs {
+		return nil, fmt.Errorf("too many call arguments: %d", len(args))
+	}
+	saved := &paramScope{
+		numbered: make(map[int]float64),
+		named:    make(map[string]float64),
+	}
+	for i := 1; i <= maxCallArgs; i++ {
+		if val, ok := p.numbered[i]; ok {
+			saved.numbered[i] = val
+			delete(p.numbered, i)
+		}
+	}
+	for name, val := range p.named {
+		if isLocalName(name) {
+			saved.named[name] = val
+			delete(p.named, name)
+		}
+	}
+	for i, arg := range args {
+		p.numbered[i+1] = arg
+	}
+	return saved, nil
+}
+
+// popScope drops the locals of the subroutine and restores the caller's
+func (p *Parameters) popScope(saved *paramScope) {
+	for i := 1; i <= maxCallArgs; i++ {
+		delete(p.numbered, i)
+	}
+	for name := range p.named {
+		if isLocalName(name) {
+			delete(p.named, name)
+		}
+	}
+	for i, val := range saved.numbered {
+		p.numbered[i] = val
+	}
+	for name, val := range saved.named {
+		p.named[name] = val
+	}
+}
@@ -109,6 +109,6 @@ G1 X0 Y0 Z-0.5
 	sim := Simulation{
 		CurrentTime: 0.0,
 	}
-	groups, accumulator, stats := sim.Simulate(in, 15*60, 15*60, mgl32.Vec3{0, 0, 0})
+	groups, accumulator, stats, _ := sim.Simulate(in, 15*60, 15*60, mgl32.Vec3{0, 0, 0})
 	return groups, accumulator, stats
 }
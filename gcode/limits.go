@@ -0,0 +1,146 @@
+package gcode
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// MachineLimits are the limits the trajectory is planned with, in machine
+// units (degrees for A, B, C) per second. A limit of 0 is not known and
+// does not apply
+type MachineLimits struct {
+	// by axis: X, Y, Z, A, B, C, U, V, W
+	MaxVelocity     [9]float32
+	MaxAcceleration [9]float32
+	// along the path, [TRAJ]MAX_VELOCITY and [TRAJ]MAX_ACCELERATION
+	MaxPathVelocity     float32
+	MaxPathAcceleration float32
+	// feed of the moves before the first F word
+	DefaultVelocity float32
+	// 1 is 100%
+	FeedOverride  float32
+	RapidOverride float32
+}
+
+// DefaultLimits are used when the machine is not known: 15 mm/s and
+// 1000 mm/s^2 along the path
+func DefaultLimits(units Units) *MachineLimits {
+	scale := 1 / units.Millimeters()
+	return &MachineLimits{
+		MaxPathVelocity:     15 * scale,
+		MaxPathAcceleration: 1000 * scale,
+		DefaultVelocity:     15 * scale,
+		FeedOverride:        1,
+		RapidOverride:       1,
+	}
+}
+
+// feedRate is the feed of the moves before the first F word, in units per
+// minute
+func (l *MachineLimits) feedRate() float32 {
+	return l.DefaultVelocity * 60
+}
+
+// rapidFeedRate is the feed of G0, in units per minute. The axis limits
+// slow it down further along the path
+func (l *MachineLimits) rapidFeedRate() float32 {
+	if l.MaxPathVelocity > 0 {
+		return l.MaxPathVelocity * 60
+	}
+	max := float32(0)
+	for _, v := range l.MaxVelocity {
+		max += v * v
+	}
+	return mgl32.Sqrt(max) * 60
+}
+
+// feedSpeed is the programmed speed of f with the override, in units per
+// second. An override of 0 pauses the machine, the estimate is then made at
+// the programmed feed
+func (l *MachineLimits) feedSpeed(f *Fragment) float32 {
+	override := l.FeedOverride
+	if f.speedTag == RapidSpeedTag {
+		override = l.RapidOverride
+	}
+	if override <= 0 {
+		override = 1
+	}
+	return f.feedRate / 60.0 * override
+}
+
+// axisRatios is the most every axis moves along a unit of the path of f.
+// The tangent of an arc turns in its plane, both axes of the plane can move
+// at the full speed
+func axisRatios(f *Fragment) [9]float32 {
+	out := [9]float32{}
+	if f.length == 0 {
+		return out
+	}
+	for i := 0; i < 3; i++ {
+		out[i] = mgl32.Abs(f.to[i]-f.from[i]) / f.length
+	}
+	for i := range f.toExtra {
+		out[3+i] = mgl32.Abs(f.toExtra[i]-f.fromExtra[i]) / f.length
+	}
+	if f.tp == ArcFragmentType {
+		out[f.plane.firstCoord], out[f.plane.secondCoord] = 1, 1
+	}
+	return out
+}
+
+// lowerLimit is the lowest of two limits, 0 is no limit
+func lowerLimit(limit float32, val float32) float32 {
+	if val <= 0 {
+		return limit
+	}
+	if limit <= 0 || val < limit {
+		return val
+	}
+	return limit
+}
+
+// pathLimits are the highest speed and acceleration along the path of f
+// that keep every axis within its limits
+func (l *MachineLimits) pathLimits(f *Fragment) (float32, float32) {
+	vel, acc := l.MaxPathVelocity, l.MaxPathAcceleration
+	for i, ratio := range axisRatios(f) {
+		if ratio == 0 {
+			continue
+		}
+		vel = lowerLimit(vel, l.MaxVelocity[i]/ratio)
+		acc = lowerLimit(acc, l.MaxAcceleration[i]/ratio)
+	}
+	return vel, acc
+}
+
+// blendSqSpeed is the highest squared speed the machine takes the corner
+// between prev and f at. In G64 the corner is rounded by an arc that stays
+// within the tolerance (half the shortest fragment without a P word), and
+// the arc is run at the acceleration of f. It is false if the machine stops
+// between the fragments: in G61, G61.1 and after a dwell
+func blendSqSpeed(prev *Fragment, f *Fragment) (float32, bool) {
+	if prev == nil || prev.pathControl != "64" || prev.dwell > 0 {
+		return 0, false
+	}
+	exitDir := COMPONENT_TYPES[prev.tp].ExitDir(prev)
+	entryDir := COMPONENT_TYPES[f.tp].EntryDir(f)
+	sinHalfAngle := mgl32.Sqrt(mgl32.Max(0, 0.5*(1+exitDir.Dot(entryDir))))
+	if sinHalfAngle > 0.9999 {
+		return math.MaxFloat32, true
+	}
+	tolerance := mgl32.Min(prev.length, f.length) / 2
+	if prev.blendTolerance > 0 {
+		tolerance = mgl32.Min(tolerance, prev.blendTolerance)
+	}
+	radius := tolerance * sinHalfAngle / (1 - sinHalfAngle)
+	return f.maxAccel * radius, true
+}
+
+// prepareFragment sets the length and the speed limits of a fragment
+func prepareFragment(f *Fragment, limits *MachineLimits) {
+	trait := COMPONENT_TYPES[f.tp]
+	f.length = trait.Length(f)
+	sp, acc := trait.Speed(f, limits)
+	f.squaredSpeed, f.maxAccel = sp*sp, acc
+}
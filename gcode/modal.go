@@ -0,0 +1,216 @@
+package gcode
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+)
+
+// ModalGroup is an RS274NGC modal group. At most one code of a group can
+// appear in a block
+type ModalGroup int
+
+const (
+	GroupNonModal ModalGroup = iota
+	GroupMotion
+	GroupPlane
+	GroupDistance
+	GroupArcDistance
+	GroupFeedRateMode
+	GroupUnits
+	GroupCutterCompensation
+	GroupToolLengthOffset
+	GroupRetract
+	GroupCoordinateSystem
+	GroupPathControl
+	GroupSpindleSpeedMode
+	GroupLatheDiameter
+
+	GroupStopping
+	GroupToolChange
+	GroupSpindle
+	GroupCoolant
+	GroupOverride
+)
+
+var modalGroupNames = map[ModalGroup]string{
+	GroupNonModal:           "non-modal (G group 0)",
+	GroupMotion:             "motion (G group 1)",
+	GroupPlane:              "plane selection (G group 2)",
+	GroupDistance:           "distance mode (G group 3)",
+	GroupArcDistance:        "arc distance mode (G group 4)",
+	GroupFeedRateMode:       "feed rate mode (G group 5)",
+	GroupUnits:              "units (G group 6)",
+	GroupCutterCompensation: "cutter compensation (G group 7)",
+	GroupToolLengthOffset:   "tool length offset (G group 8)",
+	GroupRetract:            "canned cycle return mode (G group 10)",
+	GroupCoordinateSystem:   "coordinate system (G group 12)",
+	GroupPathControl:        "path control mode (G group 13)",
+	GroupSpindleSpeedMode:   "spindle speed mode (G group 14)",
+	GroupLatheDiameter:      "lathe diameter mode (G group 15)",
+	GroupStopping:           "stopping (M group 4)",
+	GroupToolChange:         "tool change (M group 6)",
+	GroupSpindle:            "spindle (M group 7)",
+	GroupCoolant:            "coolant (M group 8)",
+	GroupOverride:           "override switches (M group 9)",
+}
+
+func (g ModalGroup) String() string {
+	return modalGroupNames[g]
+}
+
+var G_MODAL_GROUPS = map[string]ModalGroup{
+	"4": GroupNonModal, "10": GroupNonModal, "28": GroupNonModal, "28.1": GroupNonModal,
+	"30": GroupNonModal, "30.1": GroupNonModal, "52": GroupNonModal, "53": GroupNonModal,
+	"92": GroupNonModal, "92.1": GroupNonModal, "92.2": GroupNonModal, "92.3": GroupNonModal,
+
+	"0": GroupMotion, "1": GroupMotion, "2": GroupMotion, "3": GroupMotion,
+	"5": GroupMotion, "5.1": GroupMotion, "5.2": GroupMotion, "33": GroupMotion, "33.1": GroupMotion,
+	"38.2": GroupMotion, "38.3": GroupMotion, "38.4": GroupMotion, "38.5": GroupMotion,
+	"73": GroupMotion, "76": GroupMotion, "80": GroupMotion, "81": GroupMotion,
+	"82": GroupMotion, "83": GroupMotion, "84": GroupMotion, "85": GroupMotion,
+	"86": GroupMotion, "87": GroupMotion, "88": GroupMotion, "89": GroupMotion,
+
+	"17": GroupPlane, "18": GroupPlane, "19": GroupPlane,
+	"17.1": GroupPlane, "18.1": GroupPlane, "19.1": GroupPlane,
+
+	"90": GroupDistance, "91": GroupDistance,
+	"90.1": GroupArcDistance, "91.1": GroupArcDistance,
+	"93": GroupFeedRateMode, "94": GroupFeedRateMode, "95": GroupFeedRateMode,
+	"20": GroupUnits, "21": GroupUnits,
+
+	"40": GroupCutterCompensation, "41": GroupCutterCompensation, "41.1": GroupCutterCompensation,
+	"42": GroupCutterCompensation, "42.1": GroupCutterCompensation,
+	"43": GroupToolLengthOffset, "43.1": GroupToolLengthOffset, "43.2": GroupToolLengthOffset,
+	"49": GroupToolLengthOffset,
+
+	"98": GroupRetract, "99": GroupRetract,
+	"54": GroupCoordinateSystem, "55": GroupCoordinateSystem, "56": GroupCoordinateSystem,
+	"57": GroupCoordinateSystem, "58": GroupCoordinateSystem, "59": GroupCoordinateSystem,
+	"59.1": GroupCoordinateSystem, "59.2": GroupCoordinateSystem, "59.3": GroupCoordinateSystem,
+	"61": GroupPathControl, "61.1": GroupPathControl, "64": GroupPathControl,
+	"96": GroupSpindleSpeedMode, "97": GroupSpindleSpeedMode,
+	"7": GroupLatheDiameter, "8": GroupLatheDiameter,
+}
+
+var M_MODAL_GROUPS = map[int]ModalGroup{
+	0: GroupStopping, 1: GroupStopping, 2: GroupStopping, 30: GroupStopping, 60: GroupStopping,
+	6: GroupToolChange, 61: GroupToolChange,
+	3: GroupSpindle, 4: GroupSpindle, 5: GroupSpindle,
+	7: GroupCoolant, 8: GroupCoolant, 9: GroupCoolant,
+	48: GroupOverride, 49: GroupOverride, 50: GroupOverride,
+	51: GroupOverride, 52: GroupOverride, 53: GroupOverride,
+}
+
+// G codes of group 0 that use the axis words, they cannot share a block
+// with a motion
+var AXIS_WORD_CODES = map[string]bool{
+	"10": true, "28": true, "30": true, "52": true, "92": true,
+}
+
+// order in which the modal G codes of a block take effect, RS274NGC
+// section 3.8. F, S, T, M6 and G4 come before these, the rest of group 0
+// after the retract mode, the motion last
+var G_EXECUTION_ORDER = []ModalGroup{
+	GroupFeedRateMode,
+	GroupPlane,
+	GroupUnits,
+	GroupCutterCompensation,
+	GroupToolLengthOffset,
+	GroupCoordinateSystem,
+	GroupPathControl,
+	GroupDistance,
+	GroupArcDistance,
+	GroupRetract,
+	GroupSpindleSpeedMode,
+	GroupLatheDiameter,
+	GroupNonModal,
+	GroupMotion,
+}
+
+// block is a line of the program with its G and M codes sorted in modal
+// groups. words holds every other word, in program order
+type block struct {
+	g       map[ModalGroup]string
+	m       map[ModalGroup][]int
+	unknown []string
+	words   []parser.Expr
+}
+
+// gcodeNumber normalizes the number of a G word, G01 and G1.0 are both "1"
+func gcodeNumber(val float64) string {
+	return strconv.FormatFloat(math.Round(val*10)/10, 'f', -1, 64)
+}
+
+// newBlock sorts the words of a resolved line, two codes of the same modal
+// group in a block are an error
+func newBlock(line []parser.Expr) (*block, error) {
+	b := &block{
+		g:       make(map[ModalGroup]string),
+		m:       make(map[ModalGroup][]int),
+		unknown: make([]string, 0),
+		words:   make([]parser.Expr, 0, len(line)),
+	}
+	for _, tmp := range line {
+		we, ok := tmp.(parser.WordExpr)
+		if !ok {
+			b.words = append(b.words, tmp)
+			continue
+		}
+		val := we.Val.(parser.ConstExpr).Val
+		switch we.Word {
+		case "G":
+			code := gcodeNumber(val)
+			group, ok := G_MODAL_GROUPS[code]
+			if !ok {
+				b.unknown = append(b.unknown, code)
+				continue
+			}
+			if other, ok := b.g[group]; ok {
+				return nil, fmt.Errorf("G%s and G%s are both in the %s modal group", other, code, group)
+			}
+			b.g[group] = code
+		case "M":
+			code := int(math.Round(val))
+			group, ok := M_MODAL_GROUPS[code]
+			if !ok {
+				continue
+			}
+			if others, ok := b.m[group]; ok {
+				// mist and flood coolant can be turned on together
+				mistAndFlood := group == GroupCoolant && len(others) == 1 &&
+					others[0] != code && others[0] != 9 && code != 9
+				if !mistAndFlood {
+					return nil, fmt.Errorf("M%d and M%d are both in the %s modal group", others[0], code, group)
+				}
+			}
+			b.m[group] = append(b.m[group], code)
+		default:
+			b.words = append(b.words, we)
+		}
+	}
+	if code, ok := b.g[GroupNonModal]; ok && AXIS_WORD_CODES[code] {
+		if motion, ok := b.g[GroupMotion]; ok && motion != "80" {
+			return nil, fmt.Errorf("G%s and G%s both use the axis words", code, motion)
+		}
+	}
+	return b, nil
+}
+
+func (b *block) hasM(group ModalGroup, code int) bool {
+	for _, m := range b.m[group] {
+		if m == code {
+			return true
+		}
+	}
+	return false
+}
+
+// usesAxisWords is true if a code of group 0 takes the axis words of the
+// block, which are then not a move
+func (b *block) usesAxisWords() bool {
+	code, ok := b.g[GroupNonModal]
+	return ok && AXIS_WORD_CODES[code]
+}
@@ -0,0 +1,138 @@
+package gcode
+
+import (
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// WorkOffsets seeds the coordinate systems of the simulator, usually from
+// the status of the machine. Only the active coordinate system is known
+// from the status, the other ones start at zero
+type WorkOffsets struct {
+	// active coordinate system, 1 is G54 .. 9 is G59.3
+	Origin       int
+	OriginOffset mgl32.Vec3
+	G92Offset    mgl32.Vec3
+}
+
+// G codes of the coordinate systems, by index
+var ORIGIN_CODES = []string{"", "54", "55", "56", "57", "58", "59", "59.1", "59.2", "59.3"}
+
+// RS274NGC parameters that hold the offsets
+const (
+	paramG92Enabled    = 5210
+	paramG92Offset     = 5211
+	paramCurrentOrigin = 5220
+	paramOrigins       = 5221
+	paramOriginStride  = 20
+)
+
+func (ms *MachineState) seedOffsets(offsets *WorkOffsets) {
+	if offsets.Origin >= 1 && offsets.Origin < len(ms.origins) {
+		ms.selectOrigin(offsets.Origin)
+		ms.modes[GroupCoordinateSystem] = ORIGIN_CODES[offsets.Origin]
+		ms.setOrigin(offsets.Origin, offsets.OriginOffset)
+	}
+	if offsets.G92Offset.Len() > 0 {
+		ms.setG92Offset(offsets.G92Offset)
+	}
+}
+
+func (ms *MachineState) selectOrigin(origin int) {
+	ms.currentOrigin = origin
+	ms.params.numbered[paramCurrentOrigin] = float64(origin)
+}
+
+func (ms *MachineState) setOrigin(origin int, offset mgl32.Vec3) {
+	ms.origins[origin] = offset
+	for i := range AXIS_NAMES {
+		ms.params.numbered[paramOrigins+(origin-1)*paramOriginStride+i] = float64(offset[i])
+	}
+}
+
+func (ms *MachineState) setG92Offset(offset mgl32.Vec3) {
+	ms.g92Offset = offset
+	ms.params.numbered[paramG92Enabled] = 1
+	for i := range AXIS_NAMES {
+		ms.params.numbered[paramG92Offset+i] = float64(offset[i])
+	}
+}
+
+// setOffsets runs G10, G92 and its variants. Axis words are in program
+// coordinates, the offsets in machine coordinates
+func (ms *MachineState) setOffsets(code string, words map[string]float32) {
+	switch code {
+	case "10":
+		ms.setCoordinateSystem(words)
+	case "92":
+		offset := ms.g92Offset
+		for i, axis := range AXIS_NAMES {
+			if val, ok := words[axis]; ok {
+				offset[i] = ms.position[i] - ms.origins[ms.currentOrigin][i] - ms.toolOffset()[i] - val
+			}
+		}
+		ms.setG92Offset(offset)
+	case "92.1":
+		ms.setG92Offset(mgl32.Vec3{})
+		ms.params.numbered[paramG92Enabled] = 0
+	case "92.2":
+		// the parameters keep the offset for G92.3
+		ms.g92Offset = mgl32.Vec3{}
+		ms.params.numbered[paramG92Enabled] = 0
+	case "92.3":
+		offset := mgl32.Vec3{}
+		for i := range AXIS_NAMES {
+			offset[i] = float32(ms.params.numbered[paramG92Offset+i])
+		}
+		ms.setG92Offset(offset)
+	}
+}
+
+// setCoordinateSystem runs G10 L2 (set the origin of a coordinate system)
+// and G10 L20 (set it so that the current position has the given
+// coordinates). P0 is the active coordinate system
+func (ms *MachineState) setCoordinateSystem(words map[string]float32) {
+	l := int(words["L"])
+	if l != 2 && l != 20 {
+		ms.report(parser.SeverityWarning, CodeUnsupported, "G10 L%d is not simulated", l)
+		return
+	}
+	p := int(words["P"])
+	if p == 0 {
+		p = ms.currentOrigin
+	}
+	if p < 1 || p >= len(ms.origins) {
+		ms.report(parser.SeverityError, CodeOffsets, "G10 L%d P%d is not a coordinate system", l, p)
+		return
+	}
+	if _, ok := words["R"]; ok {
+		ms.report(parser.SeverityWarning, CodeUnsupported, "G10 L%d rotation is not simulated", l)
+	}
+	origin := ms.origins[p]
+	for i, axis := range AXIS_NAMES {
+		val, ok := words[axis]
+		if !ok {
+			continue
+		}
+		if l == 2 {
+			origin[i] = val
+		} else {
+			origin[i] = ms.position[i] - ms.g92Offset[i] - ms.toolOffset()[i] - val
+		}
+	}
+	ms.setOrigin(p, origin)
+}
+
+// checkG53 validates a move in machine coordinates, it must be a straight
+// move in absolute distance mode
+func (ms *MachineState) checkG53() bool {
+	if motion := ms.modes[GroupMotion]; motion != "0" && motion != "1" {
+		ms.report(parser.SeverityError, CodeOffsets, "G53 needs G0 or G1, not G%s", motion)
+		return false
+	}
+	if ms.modes[GroupDistance] != "90" {
+		ms.report(parser.SeverityError, CodeOffsets, "G53 cannot be used in incremental distance mode")
+		return false
+	}
+	return true
+}
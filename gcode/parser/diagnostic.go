@@ -0,0 +1,36 @@
+package parser
+
+import "fmt"
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+const CodeSyntax = "syntax"
+
+// Diagnostic is a problem found in a program. Line and Column are 1-based,
+// Column is 0 when the problem is about the whole line
+type Diagnostic struct {
+	Line     int
+	Column   int
+	Severity Severity
+	Code     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Column == 0 {
+		return fmt.Sprintf("%d: %s [%s] %s", d.Line, d.Severity, d.Code, d.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s [%s] %s", d.Line, d.Column, d.Severity, d.Code, d.Message)
+}
@@ -19,9 +19,9 @@ import (
 )
 
 var (
-	commandReStr   = `([EFGHIJKLMNPRSTXYZ][-+]?[0-9]*\.?[0-9]*)`
-	commandLineStr = regexp.MustCompile(`^([EFGHIJKLMNPRSTXYZ][-+]?[0-9]*\.?[0-9]*)+$`)
-	commandRe      = regexp.MustCompile(`([EFGHIJKLMNPRSTXYZ])([-+]?[0-9]*\.?[0-9]*)`)
+	commandReStr   = `([ABCDEFGHIJKLMNPQRSTUVWXYZ][-+]?[0-9]*\.?[0-9]*)`
+	commandLineStr = regexp.MustCompile(`^([ABCDEFGHIJKLMNPQRSTUVWXYZ][-+]?[0-9]*\.?[0-9]*)+$`)
+	commandRe      = regexp.MustCompile(`([ABCDEFGHIJKLMNPQRSTUVWXYZ])([-+]?[0-9]*\.?[0-9]*)`)
 	splitter       = regexp.MustCompile(`\r?\n`)
 	ignoreRe       = regexp.MustCompile(`^[\t ]*%[\t ]*$`)
 	tabsSpacesRe   = regexp.MustCompile(`[\t ]+`)
@@ -29,11 +29,53 @@ var (
 	comment2Re     = regexp.MustCompile(`;.*$`)
 )
 
-func cleanup(line string) string {
-	tmp := tabsSpacesRe.ReplaceAllString(line, "")
-	tmp = comment1Re.ReplaceAllString(tmp, "")
-	tmp = comment2Re.ReplaceAllString(tmp, "")
-	return tmp
+// removeMatches drops the matches of re from s, keeping idx, the offset in
+// the original line of every byte of s, in step
+func removeMatches(re *regexp.Regexp, s string, idx []int) (string, []int) {
+	var buf strings.Builder
+	outIdx := make([]int, 0, len(idx))
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		buf.WriteString(s[last:m[0]])
+		outIdx = append(outIdx, idx[last:m[0]]...)
+		last = m[1]
+	}
+	buf.WriteString(s[last:])
+	outIdx = append(outIdx, idx[last:]...)
+	return buf.String(), outIdx
+}
+
+// cleanup strips whitespace and comments, and returns for every byte of the
+// cleaned line its offset in the original line, to report error columns
+func cleanup(line string) (string, []int) {
+	idx := make([]int, len(line))
+	for i := range idx {
+		idx[i] = i
+	}
+	tmp, idx := removeMatches(tabsSpacesRe, line, idx)
+	tmp, idx = removeMatches(comment1Re, tmp, idx)
+	tmp, idx = removeMatches(comment2Re, tmp, idx)
+	return strings.ToUpper(tmp), idx
+}
+
+func syntaxDiagnostic(lineNo int, idx []int, err error) Diagnostic {
+	out := Diagnostic{
+		Line:     lineNo + 1,
+		Severity: SeverityError,
+		Code:     CodeSyntax,
+		Message:  err.Error(),
+	}
+	if list, ok := err.(errList); ok && len(list) > 0 {
+		if pe, ok := list[0].(*parserError); ok {
+			out.Message = pe.Inner.Error()
+			if pe.pos.offset < len(idx) {
+				out.Column = idx[pe.pos.offset] + 1
+			} else if len(idx) > 0 {
+				out.Column = idx[len(idx)-1] + 2
+			}
+		}
+	}
+	return out
 }
 
 func parseQuick(l string) ([]Expr, error) {
@@ -46,7 +88,7 @@ func parseQuick(l string) ([]Expr, error) {
 				RawVal: piece[2],
 			}
 			if piece[1] == "G" {
-				tmpConst.RawVal = strings.TrimLeft(tmpConst.RawVal, "0")
+				tmpConst.RawVal = strconv.FormatFloat(val, 'f', -1, 32)
 			}
 			tmp := WordExpr{Word: piece[1], Val: tmpConst}
 			out = append(out, tmp)
@@ -62,64 +104,46 @@ type LineAst struct {
 	Ast    []Expr
 }
 
-func ParseAll(in string) ([]LineAst, error) {
+func ParseAll(in string) ([]LineAst, []Diagnostic) {
 	out := make([]LineAst, 0)
+	diagnostics := make([]Diagnostic, 0)
 	for lineNo, l := range splitter.Split(in, -1) {
-		if ignoreRe.MatchString(l) {
-			continue
-		}
-		cleaned := cleanup(l)
-		parser := newParser("nil.ngc", []byte(cleaned))
-		if cleaned == "" {
-			continue
+		line, diagnostic := ParseLine(lineNo, l)
+		if diagnostic != nil {
+			diagnostics = append(diagnostics, *diagnostic)
+		} else if line != nil {
+			out = append(out, *line)
 		}
-		if ast, err := parseQuick(cleaned); err == nil {
-			out = append(out, LineAst{LineNo: lineNo, Ast: ast})
-		} else {
-			rawAst2, err2 := parser.parse(g)
-			if err2 != nil {
-				return nil, errors.New(fmt.Sprintf("%s", err2))
-			}
-			ast2 := rawAst2.([]interface{})
-			casted := make([]Expr, len(ast2))
-			for i, val := range ast2 {
-				casted[i] = val.(Expr)
-			}
-			gIndices0 := make([]int, 0)
-			for i, val := range casted {
-				word, isWord := val.(WordExpr)
-				if isWord {
-					if word.Word == "G" {
-						gIndices0 = append(gIndices0, i)
-					}
-				}
-			}
-			gIndices1 := make([][]int, 0)
-			if len(gIndices0) > 1 {
-				for i := 0; i < len(gIndices0)-1; i++ {
-					gIndices1 = append(gIndices1, []int{gIndices0[i], gIndices0[i+1]})
-				}
-				gIndices1 = append(gIndices1, []int{gIndices0[len(gIndices0)-1], len(casted)})
-			}
+	}
+	return out, diagnostics
+}
 
-			if len(gIndices1) > 0 {
-				outss := make([]LineAst, 0)
-				for _, indices := range gIndices1 {
-					start := indices[0]
-					end := indices[1]
-					if start < end {
-						outss = append(outss, LineAst{LineNo: lineNo, Ast: casted[start:end]})
-					} else {
-						outss = append(outss, LineAst{LineNo: lineNo, Ast: casted[start:]})
-					}
-				}
-				out = append(out, outss...)
-			} else {
-				out = append(out, LineAst{LineNo: lineNo, Ast: casted})
-			}
-		}
+// ParseLine parses a single line of a program, both results are nil for a
+// line without any code
+func ParseLine(lineNo int, l string) (*LineAst, *Diagnostic) {
+	if ignoreRe.MatchString(l) {
+		return nil, nil
 	}
-	return out, nil
+	cleaned, idx := cleanup(l)
+	if cleaned == "" {
+		return nil, nil
+	}
+	if ast, err := parseQuick(cleaned); err == nil {
+		return &LineAst{LineNo: lineNo, Ast: ast}, nil
+	}
+	rawAst, err := newParser("nil.ngc", []byte(cleaned)).parse(g)
+	if err != nil {
+		diagnostic := syntaxDiagnostic(lineNo, idx, err)
+		return nil, &diagnostic
+	}
+	ast := rawAst.([]interface{})
+	casted := make([]Expr, len(ast))
+	for i, val := range ast {
+		casted[i] = val.(Expr)
+	}
+	// a block is executed as a whole, modal groups are resolved by the
+	// simulator
+	return &LineAst{LineNo: lineNo, Ast: casted}, nil
 }
 
 func isl(v interface{}) []interface{} {
@@ -129,6 +153,14 @@ func isl(v interface{}) []interface{} {
 	return v.([]interface{})
 }
 
+func exprs(v interface{}) []Expr {
+	out := make([]Expr, 0)
+	for _, e := range isl(v) {
+		out = append(out, e.(Expr))
+	}
+	return out
+}
+
 func termer(first interface{}, rest interface{}) (Expr, error) {
 	l := first.(Expr)
 	for _, v := range isl(rest) {
@@ -299,31 +331,46 @@ func (e WordExpr) String() string {
 	return fmt.Sprintf("%s[%s]", e.Word, e.Val)
 }
 
+type OWordExpr struct {
+	Label   string
+	Keyword string
+	Args    []Expr
+}
+
+func (_ OWordExpr) isExpr() {}
+func (e OWordExpr) String() string {
+	return fmt.Sprintf("O%s %s%v", e.Label, e.Keyword, e.Args)
+}
+
 var g = &grammar{
 	rules: []*rule{
 		{
 			name: "Line",
-			pos:  position{line: 291, col: 1, offset: 5493},
+			pos:  position{line: 337, col: 1, offset: 6707},
 			expr: &actionExpr{
-				pos: position{line: 291, col: 9, offset: 5501},
+				pos: position{line: 337, col: 9, offset: 6715},
 				run: (*parser).callonLine1,
 				expr: &seqExpr{
-					pos: position{line: 291, col: 9, offset: 5501},
+					pos: position{line: 337, col: 9, offset: 6715},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 291, col: 9, offset: 5501},
+							pos:   position{line: 337, col: 9, offset: 6715},
 							label: "x",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 291, col: 11, offset: 5503},
+								pos: position{line: 337, col: 11, offset: 6717},
 								expr: &choiceExpr{
-									pos: position{line: 291, col: 12, offset: 5504},
+									pos: position{line: 337, col: 12, offset: 6718},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 291, col: 12, offset: 5504},
+											pos:  position{line: 337, col: 12, offset: 6718},
+											name: "OWord",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 337, col: 20, offset: 6726},
 											name: "Affectation",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 291, col: 26, offset: 5518},
+											pos:  position{line: 337, col: 34, offset: 6740},
 											name: "Word",
 										},
 									},
@@ -331,9 +378,9 @@ var g = &grammar{
 							},
 						},
 						&notExpr{
-							pos: position{line: 449, col: 8, offset: 9610},
+							pos: position{line: 513, col: 8, offset: 11413},
 							expr: &anyMatcher{
-								line: 449, col: 9, offset: 9611,
+								line: 513, col: 9, offset: 11414,
 							},
 						},
 					},
@@ -342,49 +389,49 @@ var g = &grammar{
 		},
 		{
 			name: "Expr",
-			pos:  position{line: 331, col: 1, offset: 6481},
+			pos:  position{line: 377, col: 1, offset: 7703},
 			expr: &actionExpr{
-				pos: position{line: 331, col: 9, offset: 6489},
+				pos: position{line: 377, col: 9, offset: 7711},
 				run: (*parser).callonExpr1,
 				expr: &seqExpr{
-					pos: position{line: 331, col: 9, offset: 6489},
+					pos: position{line: 377, col: 9, offset: 7711},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 331, col: 9, offset: 6489},
+							pos:   position{line: 377, col: 9, offset: 7711},
 							label: "first",
 							expr: &ruleRefExpr{
-								pos:  position{line: 331, col: 15, offset: 6495},
+								pos:  position{line: 377, col: 15, offset: 7717},
 								name: "TermBoolean",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 331, col: 27, offset: 6507},
+							pos:   position{line: 377, col: 27, offset: 7729},
 							label: "rest",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 331, col: 32, offset: 6512},
+								pos: position{line: 377, col: 32, offset: 7734},
 								expr: &seqExpr{
-									pos: position{line: 331, col: 34, offset: 6514},
+									pos: position{line: 377, col: 34, offset: 7736},
 									exprs: []interface{}{
 										&actionExpr{
-											pos: position{line: 361, col: 14, offset: 7220},
+											pos: position{line: 407, col: 14, offset: 8442},
 											run: (*parser).callonExpr8,
 											expr: &choiceExpr{
-												pos: position{line: 361, col: 16, offset: 7222},
+												pos: position{line: 407, col: 16, offset: 8444},
 												alternatives: []interface{}{
 													&litMatcher{
-														pos:        position{line: 361, col: 16, offset: 7222},
+														pos:        position{line: 407, col: 16, offset: 8444},
 														val:        "AND",
 														ignoreCase: false,
 														want:       "\"AND\"",
 													},
 													&litMatcher{
-														pos:        position{line: 361, col: 24, offset: 7230},
+														pos:        position{line: 407, col: 24, offset: 8452},
 														val:        "OR",
 														ignoreCase: false,
 														want:       "\"OR\"",
 													},
 													&litMatcher{
-														pos:        position{line: 361, col: 31, offset: 7237},
+														pos:        position{line: 407, col: 31, offset: 8459},
 														val:        "XOR",
 														ignoreCase: false,
 														want:       "\"XOR\"",
@@ -393,7 +440,7 @@ var g = &grammar{
 											},
 										},
 										&ruleRefExpr{
-											pos:  position{line: 331, col: 44, offset: 6524},
+											pos:  position{line: 377, col: 44, offset: 7746},
 											name: "TermBoolean",
 										},
 									},
@@ -406,67 +453,67 @@ var g = &grammar{
 		},
 		{
 			name: "TermBoolean",
-			pos:  position{line: 335, col: 1, offset: 6575},
+			pos:  position{line: 381, col: 1, offset: 7797},
 			expr: &actionExpr{
-				pos: position{line: 335, col: 16, offset: 6590},
+				pos: position{line: 381, col: 16, offset: 7812},
 				run: (*parser).callonTermBoolean1,
 				expr: &seqExpr{
-					pos: position{line: 335, col: 16, offset: 6590},
+					pos: position{line: 381, col: 16, offset: 7812},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 335, col: 16, offset: 6590},
+							pos:   position{line: 381, col: 16, offset: 7812},
 							label: "first",
 							expr: &ruleRefExpr{
-								pos:  position{line: 335, col: 22, offset: 6596},
+								pos:  position{line: 381, col: 22, offset: 7818},
 								name: "TermCompare",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 335, col: 34, offset: 6608},
+							pos:   position{line: 381, col: 34, offset: 7830},
 							label: "rest",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 335, col: 39, offset: 6613},
+								pos: position{line: 381, col: 39, offset: 7835},
 								expr: &seqExpr{
-									pos: position{line: 335, col: 41, offset: 6615},
+									pos: position{line: 381, col: 41, offset: 7837},
 									exprs: []interface{}{
 										&actionExpr{
-											pos: position{line: 365, col: 14, offset: 7294},
+											pos: position{line: 411, col: 14, offset: 8516},
 											run: (*parser).callonTermBoolean8,
 											expr: &choiceExpr{
-												pos: position{line: 365, col: 16, offset: 7296},
+												pos: position{line: 411, col: 16, offset: 8518},
 												alternatives: []interface{}{
 													&litMatcher{
-														pos:        position{line: 365, col: 16, offset: 7296},
+														pos:        position{line: 411, col: 16, offset: 8518},
 														val:        "EQ",
 														ignoreCase: false,
 														want:       "\"EQ\"",
 													},
 													&litMatcher{
-														pos:        position{line: 365, col: 23, offset: 7303},
+														pos:        position{line: 411, col: 23, offset: 8525},
 														val:        "NE",
 														ignoreCase: false,
 														want:       "\"NE\"",
 													},
 													&litMatcher{
-														pos:        position{line: 365, col: 30, offset: 7310},
+														pos:        position{line: 411, col: 30, offset: 8532},
 														val:        "GT",
 														ignoreCase: false,
 														want:       "\"GT\"",
 													},
 													&litMatcher{
-														pos:        position{line: 365, col: 37, offset: 7317},
+														pos:        position{line: 411, col: 37, offset: 8539},
 														val:        "GE",
 														ignoreCase: false,
 														want:       "\"GE\"",
 													},
 													&litMatcher{
-														pos:        position{line: 365, col: 44, offset: 7324},
+														pos:        position{line: 411, col: 44, offset: 8546},
 														val:        "LT",
 														ignoreCase: false,
 														want:       "\"LT\"",
 													},
 													&litMatcher{
-														pos:        position{line: 365, col: 51, offset: 7331},
+														pos:        position{line: 411, col: 51, offset: 8553},
 														val:        "LE",
 														ignoreCase: false,
 														want:       "\"LE\"",
@@ -475,7 +522,7 @@ var g = &grammar{
 											},
 										},
 										&ruleRefExpr{
-											pos:  position{line: 335, col: 51, offset: 6625},
+											pos:  position{line: 381, col: 51, offset: 7847},
 											name: "TermCompare",
 										},
 									},
@@ -488,34 +535,34 @@ var g = &grammar{
 		},
 		{
 			name: "TermCompare",
-			pos:  position{line: 339, col: 1, offset: 6676},
+			pos:  position{line: 385, col: 1, offset: 7898},
 			expr: &actionExpr{
-				pos: position{line: 339, col: 16, offset: 6691},
+				pos: position{line: 385, col: 16, offset: 7913},
 				run: (*parser).callonTermCompare1,
 				expr: &seqExpr{
-					pos: position{line: 339, col: 16, offset: 6691},
+					pos: position{line: 385, col: 16, offset: 7913},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 339, col: 16, offset: 6691},
+							pos:   position{line: 385, col: 16, offset: 7913},
 							label: "first",
 							expr: &ruleRefExpr{
-								pos:  position{line: 339, col: 22, offset: 6697},
+								pos:  position{line: 385, col: 22, offset: 7919},
 								name: "TermArithAdd",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 339, col: 35, offset: 6710},
+							pos:   position{line: 385, col: 35, offset: 7932},
 							label: "rest",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 339, col: 40, offset: 6715},
+								pos: position{line: 385, col: 40, offset: 7937},
 								expr: &seqExpr{
-									pos: position{line: 339, col: 42, offset: 6717},
+									pos: position{line: 385, col: 42, offset: 7939},
 									exprs: []interface{}{
 										&actionExpr{
-											pos: position{line: 369, col: 15, offset: 7388},
+											pos: position{line: 415, col: 15, offset: 8610},
 											run: (*parser).callonTermCompare8,
 											expr: &charClassMatcher{
-												pos:             position{line: 369, col: 17, offset: 7390},
+												pos:             position{line: 415, col: 17, offset: 8612},
 												val:             "[+-]",
 												chars:           []rune{'+', '-'},
 												basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -524,7 +571,7 @@ var g = &grammar{
 											},
 										},
 										&ruleRefExpr{
-											pos:  position{line: 339, col: 53, offset: 6728},
+											pos:  position{line: 385, col: 53, offset: 7950},
 											name: "TermArithAdd",
 										},
 									},
@@ -537,37 +584,37 @@ var g = &grammar{
 		},
 		{
 			name: "TermArithAdd",
-			pos:  position{line: 343, col: 1, offset: 6780},
+			pos:  position{line: 389, col: 1, offset: 8002},
 			expr: &actionExpr{
-				pos: position{line: 343, col: 17, offset: 6796},
+				pos: position{line: 389, col: 17, offset: 8018},
 				run: (*parser).callonTermArithAdd1,
 				expr: &seqExpr{
-					pos: position{line: 343, col: 17, offset: 6796},
+					pos: position{line: 389, col: 17, offset: 8018},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 343, col: 17, offset: 6796},
+							pos:   position{line: 389, col: 17, offset: 8018},
 							label: "first",
 							expr: &ruleRefExpr{
-								pos:  position{line: 343, col: 23, offset: 6802},
+								pos:  position{line: 389, col: 23, offset: 8024},
 								name: "TermArithMul",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 343, col: 36, offset: 6815},
+							pos:   position{line: 389, col: 36, offset: 8037},
 							label: "rest",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 343, col: 41, offset: 6820},
+								pos: position{line: 389, col: 41, offset: 8042},
 								expr: &seqExpr{
-									pos: position{line: 343, col: 43, offset: 6822},
+									pos: position{line: 389, col: 43, offset: 8044},
 									exprs: []interface{}{
 										&actionExpr{
-											pos: position{line: 373, col: 15, offset: 7452},
+											pos: position{line: 419, col: 15, offset: 8674},
 											run: (*parser).callonTermArithAdd8,
 											expr: &choiceExpr{
-												pos: position{line: 373, col: 17, offset: 7454},
+												pos: position{line: 419, col: 17, offset: 8676},
 												alternatives: []interface{}{
 													&charClassMatcher{
-														pos:             position{line: 373, col: 17, offset: 7454},
+														pos:             position{line: 419, col: 17, offset: 8676},
 														val:             "[*/]",
 														chars:           []rune{'*', '/'},
 														basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, false, false, false, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -575,7 +622,7 @@ var g = &grammar{
 														inverted:        false,
 													},
 													&litMatcher{
-														pos:        position{line: 373, col: 29, offset: 7466},
+														pos:        position{line: 419, col: 29, offset: 8688},
 														val:        "MOD",
 														ignoreCase: false,
 														want:       "\"MOD\"",
@@ -584,7 +631,7 @@ var g = &grammar{
 											},
 										},
 										&ruleRefExpr{
-											pos:  position{line: 343, col: 54, offset: 6833},
+											pos:  position{line: 389, col: 54, offset: 8055},
 											name: "TermArithMul",
 										},
 									},
@@ -597,41 +644,41 @@ var g = &grammar{
 		},
 		{
 			name: "TermArithMul",
-			pos:  position{line: 347, col: 1, offset: 6885},
+			pos:  position{line: 393, col: 1, offset: 8107},
 			expr: &actionExpr{
-				pos: position{line: 347, col: 17, offset: 6901},
+				pos: position{line: 393, col: 17, offset: 8123},
 				run: (*parser).callonTermArithMul1,
 				expr: &seqExpr{
-					pos: position{line: 347, col: 17, offset: 6901},
+					pos: position{line: 393, col: 17, offset: 8123},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 347, col: 17, offset: 6901},
+							pos:   position{line: 393, col: 17, offset: 8123},
 							label: "first",
 							expr: &ruleRefExpr{
-								pos:  position{line: 347, col: 23, offset: 6907},
+								pos:  position{line: 393, col: 23, offset: 8129},
 								name: "TermArithPow",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 347, col: 36, offset: 6920},
+							pos:   position{line: 393, col: 36, offset: 8142},
 							label: "rest",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 347, col: 41, offset: 6925},
+								pos: position{line: 393, col: 41, offset: 8147},
 								expr: &seqExpr{
-									pos: position{line: 347, col: 43, offset: 6927},
+									pos: position{line: 393, col: 43, offset: 8149},
 									exprs: []interface{}{
 										&actionExpr{
-											pos: position{line: 377, col: 15, offset: 7524},
+											pos: position{line: 423, col: 15, offset: 8746},
 											run: (*parser).callonTermArithMul8,
 											expr: &litMatcher{
-												pos:        position{line: 377, col: 15, offset: 7524},
+												pos:        position{line: 423, col: 15, offset: 8746},
 												val:        "**",
 												ignoreCase: false,
 												want:       "\"**\"",
 											},
 										},
 										&ruleRefExpr{
-											pos:  position{line: 347, col: 54, offset: 6938},
+											pos:  position{line: 393, col: 54, offset: 8160},
 											name: "TermArithPow",
 										},
 									},
@@ -644,32 +691,32 @@ var g = &grammar{
 		},
 		{
 			name: "TermArithPow",
-			pos:  position{line: 351, col: 1, offset: 6990},
+			pos:  position{line: 397, col: 1, offset: 8212},
 			expr: &choiceExpr{
-				pos: position{line: 351, col: 17, offset: 7006},
+				pos: position{line: 397, col: 17, offset: 8228},
 				alternatives: []interface{}{
 					&actionExpr{
-						pos: position{line: 351, col: 17, offset: 7006},
+						pos: position{line: 397, col: 17, offset: 8228},
 						run: (*parser).callonTermArithPow2,
 						expr: &seqExpr{
-							pos: position{line: 351, col: 17, offset: 7006},
+							pos: position{line: 397, col: 17, offset: 8228},
 							exprs: []interface{}{
 								&litMatcher{
-									pos:        position{line: 351, col: 17, offset: 7006},
+									pos:        position{line: 397, col: 17, offset: 8228},
 									val:        "[",
 									ignoreCase: false,
 									want:       "\"[\"",
 								},
 								&labeledExpr{
-									pos:   position{line: 351, col: 21, offset: 7010},
+									pos:   position{line: 397, col: 21, offset: 8232},
 									label: "expr",
 									expr: &ruleRefExpr{
-										pos:  position{line: 351, col: 26, offset: 7015},
+										pos:  position{line: 397, col: 26, offset: 8237},
 										name: "Expr",
 									},
 								},
 								&litMatcher{
-									pos:        position{line: 351, col: 31, offset: 7020},
+									pos:        position{line: 397, col: 31, offset: 8242},
 									val:        "]",
 									ignoreCase: false,
 									want:       "\"]\"",
@@ -678,36 +725,36 @@ var g = &grammar{
 						},
 					},
 					&actionExpr{
-						pos: position{line: 353, col: 5, offset: 7051},
+						pos: position{line: 399, col: 5, offset: 8273},
 						run: (*parser).callonTermArithPow8,
 						expr: &labeledExpr{
-							pos:   position{line: 353, col: 5, offset: 7051},
+							pos:   position{line: 399, col: 5, offset: 8273},
 							label: "parameter",
 							expr: &actionExpr{
-								pos: position{line: 321, col: 14, offset: 6204},
+								pos: position{line: 367, col: 14, offset: 7426},
 								run: (*parser).callonTermArithPow10,
 								expr: &seqExpr{
-									pos: position{line: 321, col: 14, offset: 6204},
+									pos: position{line: 367, col: 14, offset: 7426},
 									exprs: []interface{}{
 										&litMatcher{
-											pos:        position{line: 321, col: 14, offset: 6204},
+											pos:        position{line: 367, col: 14, offset: 7426},
 											val:        "#",
 											ignoreCase: false,
 											want:       "\"#\"",
 										},
 										&labeledExpr{
-											pos:   position{line: 321, col: 18, offset: 6208},
+											pos:   position{line: 367, col: 18, offset: 7430},
 											label: "param",
 											expr: &choiceExpr{
-												pos: position{line: 321, col: 25, offset: 6215},
+												pos: position{line: 367, col: 25, offset: 7437},
 												alternatives: []interface{}{
 													&actionExpr{
-														pos: position{line: 297, col: 11, offset: 5606},
+														pos: position{line: 343, col: 11, offset: 6828},
 														run: (*parser).callonTermArithPow15,
 														expr: &oneOrMoreExpr{
-															pos: position{line: 297, col: 12, offset: 5607},
+															pos: position{line: 343, col: 12, offset: 6829},
 															expr: &charClassMatcher{
-																pos:             position{line: 297, col: 12, offset: 5607},
+																pos:             position{line: 343, col: 12, offset: 6829},
 																val:             "[0-9]",
 																ranges:          []rune{'0', '9'},
 																basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -717,27 +764,27 @@ var g = &grammar{
 														},
 													},
 													&actionExpr{
-														pos: position{line: 318, col: 12, offset: 6121},
+														pos: position{line: 364, col: 12, offset: 7343},
 														run: (*parser).callonTermArithPow18,
 														expr: &seqExpr{
-															pos: position{line: 318, col: 12, offset: 6121},
+															pos: position{line: 364, col: 12, offset: 7343},
 															exprs: []interface{}{
 																&litMatcher{
-																	pos:        position{line: 318, col: 12, offset: 6121},
+																	pos:        position{line: 364, col: 12, offset: 7343},
 																	val:        "<",
 																	ignoreCase: false,
 																	want:       "\"<\"",
 																},
 																&labeledExpr{
-																	pos:   position{line: 318, col: 16, offset: 6125},
+																	pos:   position{line: 364, col: 16, offset: 7347},
 																	label: "ident",
 																	expr: &actionExpr{
-																		pos: position{line: 315, col: 10, offset: 6043},
+																		pos: position{line: 361, col: 10, offset: 7265},
 																		run: (*parser).callonTermArithPow22,
 																		expr: &oneOrMoreExpr{
-																			pos: position{line: 315, col: 10, offset: 6043},
+																			pos: position{line: 361, col: 10, offset: 7265},
 																			expr: &charClassMatcher{
-																				pos:             position{line: 315, col: 17, offset: 6050},
+																				pos:             position{line: 361, col: 17, offset: 7272},
 																				val:             "[_0-9a-zA-Z]",
 																				chars:           []rune{'_'},
 																				ranges:          []rune{'0', '9', 'a', 'z', 'A', 'Z'},
@@ -749,7 +796,7 @@ var g = &grammar{
 																	},
 																},
 																&litMatcher{
-																	pos:        position{line: 318, col: 28, offset: 6137},
+																	pos:        position{line: 364, col: 28, offset: 7359},
 																	val:        ">",
 																	ignoreCase: false,
 																	want:       "\">\"",
@@ -766,21 +813,21 @@ var g = &grammar{
 						},
 					},
 					&actionExpr{
-						pos: position{line: 355, col: 5, offset: 7103},
+						pos: position{line: 401, col: 5, offset: 8325},
 						run: (*parser).callonTermArithPow26,
 						expr: &labeledExpr{
-							pos:   position{line: 355, col: 5, offset: 7103},
+							pos:   position{line: 401, col: 5, offset: 8325},
 							label: "decimal",
 							expr: &actionExpr{
-								pos: position{line: 310, col: 12, offset: 5888},
+								pos: position{line: 356, col: 12, offset: 7110},
 								run: (*parser).callonTermArithPow28,
 								expr: &seqExpr{
-									pos: position{line: 310, col: 12, offset: 5888},
+									pos: position{line: 356, col: 12, offset: 7110},
 									exprs: []interface{}{
 										&zeroOrOneExpr{
-											pos: position{line: 310, col: 12, offset: 5888},
+											pos: position{line: 356, col: 12, offset: 7110},
 											expr: &charClassMatcher{
-												pos:             position{line: 310, col: 13, offset: 5889},
+												pos:             position{line: 356, col: 13, offset: 7111},
 												val:             "[+-]",
 												chars:           []rune{'+', '-'},
 												basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -789,24 +836,24 @@ var g = &grammar{
 											},
 										},
 										&actionExpr{
-											pos: position{line: 307, col: 19, offset: 5808},
+											pos: position{line: 353, col: 19, offset: 7030},
 											run: (*parser).callonTermArithPow32,
 											expr: &choiceExpr{
-												pos: position{line: 307, col: 20, offset: 5809},
+												pos: position{line: 353, col: 20, offset: 7031},
 												alternatives: []interface{}{
 													&actionExpr{
-														pos: position{line: 304, col: 22, offset: 5735},
+														pos: position{line: 350, col: 22, offset: 6957},
 														run: (*parser).callonTermArithPow34,
 														expr: &seqExpr{
-															pos: position{line: 304, col: 22, offset: 5735},
+															pos: position{line: 350, col: 22, offset: 6957},
 															exprs: []interface{}{
 																&actionExpr{
-																	pos: position{line: 297, col: 11, offset: 5606},
+																	pos: position{line: 343, col: 11, offset: 6828},
 																	run: (*parser).callonTermArithPow36,
 																	expr: &oneOrMoreExpr{
-																		pos: position{line: 297, col: 12, offset: 5607},
+																		pos: position{line: 343, col: 12, offset: 6829},
 																		expr: &charClassMatcher{
-																			pos:             position{line: 297, col: 12, offset: 5607},
+																			pos:             position{line: 343, col: 12, offset: 6829},
 																			val:             "[0-9]",
 																			ranges:          []rune{'0', '9'},
 																			basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -816,28 +863,28 @@ var g = &grammar{
 																	},
 																},
 																&zeroOrOneExpr{
-																	pos: position{line: 304, col: 29, offset: 5742},
+																	pos: position{line: 350, col: 29, offset: 6964},
 																	expr: &actionExpr{
-																		pos: position{line: 301, col: 16, offset: 5667},
+																		pos: position{line: 347, col: 16, offset: 6889},
 																		run: (*parser).callonTermArithPow40,
 																		expr: &seqExpr{
-																			pos: position{line: 301, col: 16, offset: 5667},
+																			pos: position{line: 347, col: 16, offset: 6889},
 																			exprs: []interface{}{
 																				&litMatcher{
-																					pos:        position{line: 301, col: 16, offset: 5667},
+																					pos:        position{line: 347, col: 16, offset: 6889},
 																					val:        ".",
 																					ignoreCase: false,
 																					want:       "\".\"",
 																				},
 																				&zeroOrOneExpr{
-																					pos: position{line: 301, col: 20, offset: 5671},
+																					pos: position{line: 347, col: 20, offset: 6893},
 																					expr: &actionExpr{
-																						pos: position{line: 297, col: 11, offset: 5606},
+																						pos: position{line: 343, col: 11, offset: 6828},
 																						run: (*parser).callonTermArithPow44,
 																						expr: &oneOrMoreExpr{
-																							pos: position{line: 297, col: 12, offset: 5607},
+																							pos: position{line: 343, col: 12, offset: 6829},
 																							expr: &charClassMatcher{
-																								pos:             position{line: 297, col: 12, offset: 5607},
+																								pos:             position{line: 343, col: 12, offset: 6829},
 																								val:             "[0-9]",
 																								ranges:          []rune{'0', '9'},
 																								basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -855,26 +902,26 @@ var g = &grammar{
 														},
 													},
 													&actionExpr{
-														pos: position{line: 301, col: 16, offset: 5667},
+														pos: position{line: 347, col: 16, offset: 6889},
 														run: (*parser).callonTermArithPow47,
 														expr: &seqExpr{
-															pos: position{line: 301, col: 16, offset: 5667},
+															pos: position{line: 347, col: 16, offset: 6889},
 															exprs: []interface{}{
 																&litMatcher{
-																	pos:        position{line: 301, col: 16, offset: 5667},
+																	pos:        position{line: 347, col: 16, offset: 6889},
 																	val:        ".",
 																	ignoreCase: false,
 																	want:       "\".\"",
 																},
 																&zeroOrOneExpr{
-																	pos: position{line: 301, col: 20, offset: 5671},
+																	pos: position{line: 347, col: 20, offset: 6893},
 																	expr: &actionExpr{
-																		pos: position{line: 297, col: 11, offset: 5606},
+																		pos: position{line: 343, col: 11, offset: 6828},
 																		run: (*parser).callonTermArithPow51,
 																		expr: &oneOrMoreExpr{
-																			pos: position{line: 297, col: 12, offset: 5607},
+																			pos: position{line: 343, col: 12, offset: 6829},
 																			expr: &charClassMatcher{
-																				pos:             position{line: 297, col: 12, offset: 5607},
+																				pos:             position{line: 343, col: 12, offset: 6829},
 																				val:             "[0-9]",
 																				ranges:          []rune{'0', '9'},
 																				basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -896,13 +943,13 @@ var g = &grammar{
 						},
 					},
 					&actionExpr{
-						pos: position{line: 357, col: 5, offset: 7159},
+						pos: position{line: 403, col: 5, offset: 8381},
 						run: (*parser).callonTermArithPow54,
 						expr: &labeledExpr{
-							pos:   position{line: 357, col: 5, offset: 7159},
+							pos:   position{line: 403, col: 5, offset: 8381},
 							label: "funcCall",
 							expr: &ruleRefExpr{
-								pos:  position{line: 357, col: 14, offset: 7168},
+								pos:  position{line: 403, col: 14, offset: 8390},
 								name: "FuncCallOp",
 							},
 						},
@@ -912,64 +959,64 @@ var g = &grammar{
 		},
 		{
 			name: "FuncCallOp",
-			pos:  position{line: 382, col: 1, offset: 7566},
+			pos:  position{line: 428, col: 1, offset: 8788},
 			expr: &choiceExpr{
-				pos: position{line: 382, col: 17, offset: 7582},
+				pos: position{line: 428, col: 17, offset: 8804},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 17, offset: 7582},
+						pos:  position{line: 428, col: 17, offset: 8804},
 						name: "AtanCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 28, offset: 7593},
+						pos:  position{line: 428, col: 28, offset: 8815},
 						name: "AbsCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 38, offset: 7603},
+						pos:  position{line: 428, col: 38, offset: 8825},
 						name: "AcosCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 49, offset: 7614},
+						pos:  position{line: 428, col: 49, offset: 8836},
 						name: "AsinCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 60, offset: 7625},
+						pos:  position{line: 428, col: 60, offset: 8847},
 						name: "CosCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 70, offset: 7635},
+						pos:  position{line: 428, col: 70, offset: 8857},
 						name: "ExpCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 80, offset: 7645},
+						pos:  position{line: 428, col: 80, offset: 8867},
 						name: "FixCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 90, offset: 7655},
+						pos:  position{line: 428, col: 90, offset: 8877},
 						name: "FupCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 100, offset: 7665},
+						pos:  position{line: 428, col: 100, offset: 8887},
 						name: "RoundCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 112, offset: 7677},
+						pos:  position{line: 428, col: 112, offset: 8899},
 						name: "LnCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 121, offset: 7686},
+						pos:  position{line: 428, col: 121, offset: 8908},
 						name: "SinCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 131, offset: 7696},
+						pos:  position{line: 428, col: 131, offset: 8918},
 						name: "SqrtCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 142, offset: 7707},
+						pos:  position{line: 428, col: 142, offset: 8929},
 						name: "TanCall",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 382, col: 152, offset: 7717},
+						pos:  position{line: 428, col: 152, offset: 8939},
 						name: "ExistsCall",
 					},
 				},
@@ -977,53 +1024,53 @@ var g = &grammar{
 		},
 		{
 			name: "AtanCall",
-			pos:  position{line: 384, col: 1, offset: 7732},
+			pos:  position{line: 430, col: 1, offset: 8954},
 			expr: &actionExpr{
-				pos: position{line: 384, col: 13, offset: 7744},
+				pos: position{line: 430, col: 13, offset: 8966},
 				run: (*parser).callonAtanCall1,
 				expr: &seqExpr{
-					pos: position{line: 384, col: 13, offset: 7744},
+					pos: position{line: 430, col: 13, offset: 8966},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 384, col: 13, offset: 7744},
+							pos:   position{line: 430, col: 13, offset: 8966},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 384, col: 17, offset: 7748},
+								pos:        position{line: 430, col: 17, offset: 8970},
 								val:        "ATAN",
 								ignoreCase: false,
 								want:       "\"ATAN\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 384, col: 24, offset: 7755},
+							pos:        position{line: 430, col: 24, offset: 8977},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 384, col: 28, offset: 7759},
+							pos:   position{line: 430, col: 28, offset: 8981},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 384, col: 33, offset: 7764},
+								pos:  position{line: 430, col: 33, offset: 8986},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 384, col: 38, offset: 7769},
+							pos:        position{line: 430, col: 38, offset: 8991},
 							val:        "]/[",
 							ignoreCase: false,
 							want:       "\"]/[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 384, col: 44, offset: 7775},
+							pos:   position{line: 430, col: 44, offset: 8997},
 							label: "arg2",
 							expr: &ruleRefExpr{
-								pos:  position{line: 384, col: 49, offset: 7780},
+								pos:  position{line: 430, col: 49, offset: 9002},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 384, col: 54, offset: 7785},
+							pos:        position{line: 430, col: 54, offset: 9007},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1034,39 +1081,39 @@ var g = &grammar{
 		},
 		{
 			name: "AbsCall",
-			pos:  position{line: 388, col: 1, offset: 7874},
+			pos:  position{line: 434, col: 1, offset: 9104},
 			expr: &actionExpr{
-				pos: position{line: 388, col: 12, offset: 7885},
+				pos: position{line: 434, col: 12, offset: 9115},
 				run: (*parser).callonAbsCall1,
 				expr: &seqExpr{
-					pos: position{line: 388, col: 12, offset: 7885},
+					pos: position{line: 434, col: 12, offset: 9115},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 388, col: 12, offset: 7885},
+							pos:   position{line: 434, col: 12, offset: 9115},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 388, col: 16, offset: 7889},
+								pos:        position{line: 434, col: 16, offset: 9119},
 								val:        "ABS",
 								ignoreCase: false,
 								want:       "\"ABS\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 388, col: 22, offset: 7895},
+							pos:        position{line: 434, col: 22, offset: 9125},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 388, col: 26, offset: 7899},
+							pos:   position{line: 434, col: 26, offset: 9129},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 388, col: 31, offset: 7904},
+								pos:  position{line: 434, col: 31, offset: 9134},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 388, col: 36, offset: 7909},
+							pos:        position{line: 434, col: 36, offset: 9139},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1077,39 +1124,39 @@ var g = &grammar{
 		},
 		{
 			name: "AcosCall",
-			pos:  position{line: 392, col: 1, offset: 7987},
+			pos:  position{line: 438, col: 1, offset: 9217},
 			expr: &actionExpr{
-				pos: position{line: 392, col: 13, offset: 7999},
+				pos: position{line: 438, col: 13, offset: 9229},
 				run: (*parser).callonAcosCall1,
 				expr: &seqExpr{
-					pos: position{line: 392, col: 13, offset: 7999},
+					pos: position{line: 438, col: 13, offset: 9229},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 392, col: 13, offset: 7999},
+							pos:   position{line: 438, col: 13, offset: 9229},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 392, col: 17, offset: 8003},
+								pos:        position{line: 438, col: 17, offset: 9233},
 								val:        "ACOS",
 								ignoreCase: false,
 								want:       "\"ACOS\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 392, col: 24, offset: 8010},
+							pos:        position{line: 438, col: 24, offset: 9240},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 392, col: 28, offset: 8014},
+							pos:   position{line: 438, col: 28, offset: 9244},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 392, col: 33, offset: 8019},
+								pos:  position{line: 438, col: 33, offset: 9249},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 392, col: 38, offset: 8024},
+							pos:        position{line: 438, col: 38, offset: 9254},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1120,39 +1167,39 @@ var g = &grammar{
 		},
 		{
 			name: "AsinCall",
-			pos:  position{line: 396, col: 1, offset: 8101},
+			pos:  position{line: 442, col: 1, offset: 9331},
 			expr: &actionExpr{
-				pos: position{line: 396, col: 13, offset: 8113},
+				pos: position{line: 442, col: 13, offset: 9343},
 				run: (*parser).callonAsinCall1,
 				expr: &seqExpr{
-					pos: position{line: 396, col: 13, offset: 8113},
+					pos: position{line: 442, col: 13, offset: 9343},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 396, col: 13, offset: 8113},
+							pos:   position{line: 442, col: 13, offset: 9343},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 396, col: 17, offset: 8117},
+								pos:        position{line: 442, col: 17, offset: 9347},
 								val:        "ASIN",
 								ignoreCase: false,
 								want:       "\"ASIN\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 396, col: 24, offset: 8124},
+							pos:        position{line: 442, col: 24, offset: 9354},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 396, col: 28, offset: 8128},
+							pos:   position{line: 442, col: 28, offset: 9358},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 396, col: 33, offset: 8133},
+								pos:  position{line: 442, col: 33, offset: 9363},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 396, col: 38, offset: 8138},
+							pos:        position{line: 442, col: 38, offset: 9368},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1163,39 +1210,39 @@ var g = &grammar{
 		},
 		{
 			name: "CosCall",
-			pos:  position{line: 400, col: 1, offset: 8215},
+			pos:  position{line: 446, col: 1, offset: 9445},
 			expr: &actionExpr{
-				pos: position{line: 400, col: 12, offset: 8226},
+				pos: position{line: 446, col: 12, offset: 9456},
 				run: (*parser).callonCosCall1,
 				expr: &seqExpr{
-					pos: position{line: 400, col: 12, offset: 8226},
+					pos: position{line: 446, col: 12, offset: 9456},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 400, col: 12, offset: 8226},
+							pos:   position{line: 446, col: 12, offset: 9456},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 400, col: 16, offset: 8230},
+								pos:        position{line: 446, col: 16, offset: 9460},
 								val:        "COS",
 								ignoreCase: false,
 								want:       "\"COS\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 400, col: 22, offset: 8236},
+							pos:        position{line: 446, col: 22, offset: 9466},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 400, col: 26, offset: 8240},
+							pos:   position{line: 446, col: 26, offset: 9470},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 400, col: 31, offset: 8245},
+								pos:  position{line: 446, col: 31, offset: 9475},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 400, col: 36, offset: 8250},
+							pos:        position{line: 446, col: 36, offset: 9480},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1206,39 +1253,39 @@ var g = &grammar{
 		},
 		{
 			name: "ExpCall",
-			pos:  position{line: 404, col: 1, offset: 8327},
+			pos:  position{line: 450, col: 1, offset: 9557},
 			expr: &actionExpr{
-				pos: position{line: 404, col: 12, offset: 8338},
+				pos: position{line: 450, col: 12, offset: 9568},
 				run: (*parser).callonExpCall1,
 				expr: &seqExpr{
-					pos: position{line: 404, col: 12, offset: 8338},
+					pos: position{line: 450, col: 12, offset: 9568},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 404, col: 12, offset: 8338},
+							pos:   position{line: 450, col: 12, offset: 9568},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 404, col: 16, offset: 8342},
+								pos:        position{line: 450, col: 16, offset: 9572},
 								val:        "EXP",
 								ignoreCase: false,
 								want:       "\"EXP\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 404, col: 22, offset: 8348},
+							pos:        position{line: 450, col: 22, offset: 9578},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 404, col: 26, offset: 8352},
+							pos:   position{line: 450, col: 26, offset: 9582},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 404, col: 31, offset: 8357},
+								pos:  position{line: 450, col: 31, offset: 9587},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 404, col: 36, offset: 8362},
+							pos:        position{line: 450, col: 36, offset: 9592},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1249,39 +1296,39 @@ var g = &grammar{
 		},
 		{
 			name: "FixCall",
-			pos:  position{line: 408, col: 1, offset: 8439},
+			pos:  position{line: 454, col: 1, offset: 9669},
 			expr: &actionExpr{
-				pos: position{line: 408, col: 12, offset: 8450},
+				pos: position{line: 454, col: 12, offset: 9680},
 				run: (*parser).callonFixCall1,
 				expr: &seqExpr{
-					pos: position{line: 408, col: 12, offset: 8450},
+					pos: position{line: 454, col: 12, offset: 9680},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 408, col: 12, offset: 8450},
+							pos:   position{line: 454, col: 12, offset: 9680},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 408, col: 16, offset: 8454},
+								pos:        position{line: 454, col: 16, offset: 9684},
 								val:        "FIX",
 								ignoreCase: false,
 								want:       "\"FIX\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 408, col: 22, offset: 8460},
+							pos:        position{line: 454, col: 22, offset: 9690},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 408, col: 26, offset: 8464},
+							pos:   position{line: 454, col: 26, offset: 9694},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 408, col: 31, offset: 8469},
+								pos:  position{line: 454, col: 31, offset: 9699},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 408, col: 36, offset: 8474},
+							pos:        position{line: 454, col: 36, offset: 9704},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1292,39 +1339,39 @@ var g = &grammar{
 		},
 		{
 			name: "FupCall",
-			pos:  position{line: 412, col: 1, offset: 8551},
+			pos:  position{line: 458, col: 1, offset: 9781},
 			expr: &actionExpr{
-				pos: position{line: 412, col: 12, offset: 8562},
+				pos: position{line: 458, col: 12, offset: 9792},
 				run: (*parser).callonFupCall1,
 				expr: &seqExpr{
-					pos: position{line: 412, col: 12, offset: 8562},
+					pos: position{line: 458, col: 12, offset: 9792},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 412, col: 12, offset: 8562},
+							pos:   position{line: 458, col: 12, offset: 9792},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 412, col: 16, offset: 8566},
+								pos:        position{line: 458, col: 16, offset: 9796},
 								val:        "FUP",
 								ignoreCase: false,
 								want:       "\"FUP\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 412, col: 22, offset: 8572},
+							pos:        position{line: 458, col: 22, offset: 9802},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 412, col: 26, offset: 8576},
+							pos:   position{line: 458, col: 26, offset: 9806},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 412, col: 31, offset: 8581},
+								pos:  position{line: 458, col: 31, offset: 9811},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 412, col: 36, offset: 8586},
+							pos:        position{line: 458, col: 36, offset: 9816},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1335,39 +1382,39 @@ var g = &grammar{
 		},
 		{
 			name: "RoundCall",
-			pos:  position{line: 416, col: 1, offset: 8663},
+			pos:  position{line: 462, col: 1, offset: 9893},
 			expr: &actionExpr{
-				pos: position{line: 416, col: 14, offset: 8676},
+				pos: position{line: 462, col: 14, offset: 9906},
 				run: (*parser).callonRoundCall1,
 				expr: &seqExpr{
-					pos: position{line: 416, col: 14, offset: 8676},
+					pos: position{line: 462, col: 14, offset: 9906},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 416, col: 14, offset: 8676},
+							pos:   position{line: 462, col: 14, offset: 9906},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 416, col: 18, offset: 8680},
+								pos:        position{line: 462, col: 18, offset: 9910},
 								val:        "ROUND",
 								ignoreCase: false,
 								want:       "\"ROUND\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 416, col: 26, offset: 8688},
+							pos:        position{line: 462, col: 26, offset: 9918},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 416, col: 30, offset: 8692},
+							pos:   position{line: 462, col: 30, offset: 9922},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 416, col: 35, offset: 8697},
+								pos:  position{line: 462, col: 35, offset: 9927},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 416, col: 40, offset: 8702},
+							pos:        position{line: 462, col: 40, offset: 9932},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1378,39 +1425,39 @@ var g = &grammar{
 		},
 		{
 			name: "LnCall",
-			pos:  position{line: 420, col: 1, offset: 8779},
+			pos:  position{line: 466, col: 1, offset: 10009},
 			expr: &actionExpr{
-				pos: position{line: 420, col: 11, offset: 8789},
+				pos: position{line: 466, col: 11, offset: 10019},
 				run: (*parser).callonLnCall1,
 				expr: &seqExpr{
-					pos: position{line: 420, col: 11, offset: 8789},
+					pos: position{line: 466, col: 11, offset: 10019},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 420, col: 11, offset: 8789},
+							pos:   position{line: 466, col: 11, offset: 10019},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 420, col: 15, offset: 8793},
+								pos:        position{line: 466, col: 15, offset: 10023},
 								val:        "LN",
 								ignoreCase: false,
 								want:       "\"LN\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 420, col: 20, offset: 8798},
+							pos:        position{line: 466, col: 20, offset: 10028},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 420, col: 24, offset: 8802},
+							pos:   position{line: 466, col: 24, offset: 10032},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 420, col: 29, offset: 8807},
+								pos:  position{line: 466, col: 29, offset: 10037},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 420, col: 34, offset: 8812},
+							pos:        position{line: 466, col: 34, offset: 10042},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1421,39 +1468,39 @@ var g = &grammar{
 		},
 		{
 			name: "SinCall",
-			pos:  position{line: 424, col: 1, offset: 8889},
+			pos:  position{line: 470, col: 1, offset: 10119},
 			expr: &actionExpr{
-				pos: position{line: 424, col: 12, offset: 8900},
+				pos: position{line: 470, col: 12, offset: 10130},
 				run: (*parser).callonSinCall1,
 				expr: &seqExpr{
-					pos: position{line: 424, col: 12, offset: 8900},
+					pos: position{line: 470, col: 12, offset: 10130},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 424, col: 12, offset: 8900},
+							pos:   position{line: 470, col: 12, offset: 10130},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 424, col: 16, offset: 8904},
+								pos:        position{line: 470, col: 16, offset: 10134},
 								val:        "SIN",
 								ignoreCase: false,
 								want:       "\"SIN\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 424, col: 22, offset: 8910},
+							pos:        position{line: 470, col: 22, offset: 10140},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 424, col: 26, offset: 8914},
+							pos:   position{line: 470, col: 26, offset: 10144},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 424, col: 31, offset: 8919},
+								pos:  position{line: 470, col: 31, offset: 10149},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 424, col: 36, offset: 8924},
+							pos:        position{line: 470, col: 36, offset: 10154},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1464,39 +1511,39 @@ var g = &grammar{
 		},
 		{
 			name: "SqrtCall",
-			pos:  position{line: 428, col: 1, offset: 9001},
+			pos:  position{line: 474, col: 1, offset: 10231},
 			expr: &actionExpr{
-				pos: position{line: 428, col: 13, offset: 9013},
+				pos: position{line: 474, col: 13, offset: 10243},
 				run: (*parser).callonSqrtCall1,
 				expr: &seqExpr{
-					pos: position{line: 428, col: 13, offset: 9013},
+					pos: position{line: 474, col: 13, offset: 10243},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 428, col: 13, offset: 9013},
+							pos:   position{line: 474, col: 13, offset: 10243},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 428, col: 17, offset: 9017},
+								pos:        position{line: 474, col: 17, offset: 10247},
 								val:        "SQRT",
 								ignoreCase: false,
 								want:       "\"SQRT\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 428, col: 24, offset: 9024},
+							pos:        position{line: 474, col: 24, offset: 10254},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 428, col: 28, offset: 9028},
+							pos:   position{line: 474, col: 28, offset: 10258},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 428, col: 33, offset: 9033},
+								pos:  position{line: 474, col: 33, offset: 10263},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 428, col: 38, offset: 9038},
+							pos:        position{line: 474, col: 38, offset: 10268},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1507,39 +1554,39 @@ var g = &grammar{
 		},
 		{
 			name: "TanCall",
-			pos:  position{line: 432, col: 1, offset: 9115},
+			pos:  position{line: 478, col: 1, offset: 10345},
 			expr: &actionExpr{
-				pos: position{line: 432, col: 12, offset: 9126},
+				pos: position{line: 478, col: 12, offset: 10356},
 				run: (*parser).callonTanCall1,
 				expr: &seqExpr{
-					pos: position{line: 432, col: 12, offset: 9126},
+					pos: position{line: 478, col: 12, offset: 10356},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 432, col: 12, offset: 9126},
+							pos:   position{line: 478, col: 12, offset: 10356},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 432, col: 16, offset: 9130},
+								pos:        position{line: 478, col: 16, offset: 10360},
 								val:        "TAN",
 								ignoreCase: false,
 								want:       "\"TAN\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 432, col: 22, offset: 9136},
+							pos:        position{line: 478, col: 22, offset: 10366},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 432, col: 26, offset: 9140},
+							pos:   position{line: 478, col: 26, offset: 10370},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 432, col: 31, offset: 9145},
+								pos:  position{line: 478, col: 31, offset: 10375},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 432, col: 36, offset: 9150},
+							pos:        position{line: 478, col: 36, offset: 10380},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1550,39 +1597,39 @@ var g = &grammar{
 		},
 		{
 			name: "ExistsCall",
-			pos:  position{line: 436, col: 1, offset: 9227},
+			pos:  position{line: 482, col: 1, offset: 10457},
 			expr: &actionExpr{
-				pos: position{line: 436, col: 15, offset: 9241},
+				pos: position{line: 482, col: 15, offset: 10471},
 				run: (*parser).callonExistsCall1,
 				expr: &seqExpr{
-					pos: position{line: 436, col: 15, offset: 9241},
+					pos: position{line: 482, col: 15, offset: 10471},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 436, col: 15, offset: 9241},
+							pos:   position{line: 482, col: 15, offset: 10471},
 							label: "fct",
 							expr: &litMatcher{
-								pos:        position{line: 436, col: 19, offset: 9245},
+								pos:        position{line: 482, col: 19, offset: 10475},
 								val:        "EXISTS",
 								ignoreCase: false,
 								want:       "\"EXISTS\"",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 436, col: 28, offset: 9254},
+							pos:        position{line: 482, col: 28, offset: 10484},
 							val:        "[",
 							ignoreCase: false,
 							want:       "\"[\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 436, col: 32, offset: 9258},
+							pos:   position{line: 482, col: 32, offset: 10488},
 							label: "arg1",
 							expr: &ruleRefExpr{
-								pos:  position{line: 436, col: 37, offset: 9263},
+								pos:  position{line: 482, col: 37, offset: 10493},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 436, col: 42, offset: 9268},
+							pos:        position{line: 482, col: 42, offset: 10498},
 							val:        "]",
 							ignoreCase: false,
 							want:       "\"]\"",
@@ -1593,41 +1640,41 @@ var g = &grammar{
 		},
 		{
 			name: "Affectation",
-			pos:  position{line: 440, col: 1, offset: 9345},
+			pos:  position{line: 486, col: 1, offset: 10575},
 			expr: &actionExpr{
-				pos: position{line: 440, col: 16, offset: 9360},
+				pos: position{line: 486, col: 16, offset: 10590},
 				run: (*parser).callonAffectation1,
 				expr: &seqExpr{
-					pos: position{line: 440, col: 16, offset: 9360},
+					pos: position{line: 486, col: 16, offset: 10590},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 440, col: 16, offset: 9360},
+							pos:   position{line: 486, col: 16, offset: 10590},
 							label: "parameter",
 							expr: &actionExpr{
-								pos: position{line: 321, col: 14, offset: 6204},
+								pos: position{line: 367, col: 14, offset: 7426},
 								run: (*parser).callonAffectation4,
 								expr: &seqExpr{
-									pos: position{line: 321, col: 14, offset: 6204},
+									pos: position{line: 367, col: 14, offset: 7426},
 									exprs: []interface{}{
 										&litMatcher{
-											pos:        position{line: 321, col: 14, offset: 6204},
+											pos:        position{line: 367, col: 14, offset: 7426},
 											val:        "#",
 											ignoreCase: false,
 											want:       "\"#\"",
 										},
 										&labeledExpr{
-											pos:   position{line: 321, col: 18, offset: 6208},
+											pos:   position{line: 367, col: 18, offset: 7430},
 											label: "param",
 											expr: &choiceExpr{
-												pos: position{line: 321, col: 25, offset: 6215},
+												pos: position{line: 367, col: 25, offset: 7437},
 												alternatives: []interface{}{
 													&actionExpr{
-														pos: position{line: 297, col: 11, offset: 5606},
+														pos: position{line: 343, col: 11, offset: 6828},
 														run: (*parser).callonAffectation9,
 														expr: &oneOrMoreExpr{
-															pos: position{line: 297, col: 12, offset: 5607},
+															pos: position{line: 343, col: 12, offset: 6829},
 															expr: &charClassMatcher{
-																pos:             position{line: 297, col: 12, offset: 5607},
+																pos:             position{line: 343, col: 12, offset: 6829},
 																val:             "[0-9]",
 																ranges:          []rune{'0', '9'},
 																basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -1637,27 +1684,27 @@ var g = &grammar{
 														},
 													},
 													&actionExpr{
-														pos: position{line: 318, col: 12, offset: 6121},
+														pos: position{line: 364, col: 12, offset: 7343},
 														run: (*parser).callonAffectation12,
 														expr: &seqExpr{
-															pos: position{line: 318, col: 12, offset: 6121},
+															pos: position{line: 364, col: 12, offset: 7343},
 															exprs: []interface{}{
 																&litMatcher{
-																	pos:        position{line: 318, col: 12, offset: 6121},
+																	pos:        position{line: 364, col: 12, offset: 7343},
 																	val:        "<",
 																	ignoreCase: false,
 																	want:       "\"<\"",
 																},
 																&labeledExpr{
-																	pos:   position{line: 318, col: 16, offset: 6125},
+																	pos:   position{line: 364, col: 16, offset: 7347},
 																	label: "ident",
 																	expr: &actionExpr{
-																		pos: position{line: 315, col: 10, offset: 6043},
+																		pos: position{line: 361, col: 10, offset: 7265},
 																		run: (*parser).callonAffectation16,
 																		expr: &oneOrMoreExpr{
-																			pos: position{line: 315, col: 10, offset: 6043},
+																			pos: position{line: 361, col: 10, offset: 7265},
 																			expr: &charClassMatcher{
-																				pos:             position{line: 315, col: 17, offset: 6050},
+																				pos:             position{line: 361, col: 17, offset: 7272},
 																				val:             "[_0-9a-zA-Z]",
 																				chars:           []rune{'_'},
 																				ranges:          []rune{'0', '9', 'a', 'z', 'A', 'Z'},
@@ -1669,7 +1716,7 @@ var g = &grammar{
 																	},
 																},
 																&litMatcher{
-																	pos:        position{line: 318, col: 28, offset: 6137},
+																	pos:        position{line: 364, col: 28, offset: 7359},
 																	val:        ">",
 																	ignoreCase: false,
 																	want:       "\">\"",
@@ -1685,9 +1732,9 @@ var g = &grammar{
 							},
 						},
 						&zeroOrMoreExpr{
-							pos: position{line: 295, col: 19, offset: 5584},
+							pos: position{line: 341, col: 19, offset: 6806},
 							expr: &charClassMatcher{
-								pos:             position{line: 295, col: 19, offset: 5584},
+								pos:             position{line: 341, col: 19, offset: 6806},
 								val:             "[ \\n\\t\\r]",
 								chars:           []rune{' ', '\n', '\t', '\r'},
 								basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, true, true, false, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -1696,15 +1743,15 @@ var g = &grammar{
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 440, col: 38, offset: 9382},
+							pos:        position{line: 486, col: 38, offset: 10612},
 							val:        "=",
 							ignoreCase: false,
 							want:       "\"=\"",
 						},
 						&zeroOrMoreExpr{
-							pos: position{line: 295, col: 19, offset: 5584},
+							pos: position{line: 341, col: 19, offset: 6806},
 							expr: &charClassMatcher{
-								pos:             position{line: 295, col: 19, offset: 5584},
+								pos:             position{line: 341, col: 19, offset: 6806},
 								val:             "[ \\n\\t\\r]",
 								chars:           []rune{' ', '\n', '\t', '\r'},
 								basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, true, true, false, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
@@ -1713,10 +1760,10 @@ var g = &grammar{
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 440, col: 44, offset: 9388},
+							pos:   position{line: 486, col: 44, offset: 10618},
 							label: "expr",
 							expr: &ruleRefExpr{
-								pos:  position{line: 440, col: 49, offset: 9393},
+								pos:  position{line: 486, col: 49, offset: 10623},
 								name: "TermArithPow",
 							},
 						},
@@ -1726,30 +1773,30 @@ var g = &grammar{
 		},
 		{
 			name: "Word",
-			pos:  position{line: 444, col: 1, offset: 9482},
+			pos:  position{line: 490, col: 1, offset: 10712},
 			expr: &actionExpr{
-				pos: position{line: 444, col: 9, offset: 9490},
+				pos: position{line: 490, col: 9, offset: 10720},
 				run: (*parser).callonWord1,
 				expr: &seqExpr{
-					pos: position{line: 444, col: 9, offset: 9490},
+					pos: position{line: 490, col: 9, offset: 10720},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 444, col: 9, offset: 9490},
+							pos:   position{line: 490, col: 9, offset: 10720},
 							label: "word",
 							expr: &charClassMatcher{
-								pos:             position{line: 444, col: 14, offset: 9495},
-								val:             "[EFGHIJKLMNPRSTXYZ]",
-								chars:           []rune{'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'P', 'R', 'S', 'T', 'X', 'Y', 'Z'},
-								basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, true, false, true, true, true, false, false, false, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
+								pos:             position{line: 490, col: 14, offset: 10725},
+								val:             "[ABCDEFGHIJKLMNPQRSTUVWXYZ]",
+								chars:           []rune{'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z'},
+								basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, true, true, true, true, false, true, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
 								ignoreCase:      false,
 								inverted:        false,
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 444, col: 34, offset: 9515},
+							pos:   position{line: 490, col: 42, offset: 10753},
 							label: "expr",
 							expr: &ruleRefExpr{
-								pos:  position{line: 444, col: 39, offset: 9520},
+								pos:  position{line: 490, col: 47, offset: 10758},
 								name: "Expr",
 							},
 						},
@@ -1757,6 +1804,257 @@ var g = &grammar{
 				},
 			},
 		},
+		{
+			name: "OWord",
+			pos:  position{line: 494, col: 1, offset: 10840},
+			expr: &actionExpr{
+				pos: position{line: 494, col: 10, offset: 10849},
+				run: (*parser).callonOWord1,
+				expr: &seqExpr{
+					pos: position{line: 494, col: 10, offset: 10849},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 494, col: 10, offset: 10849},
+							val:        "O",
+							ignoreCase: false,
+							want:       "\"O\"",
+						},
+						&labeledExpr{
+							pos:   position{line: 494, col: 14, offset: 10853},
+							label: "label",
+							expr: &ruleRefExpr{
+								pos:  position{line: 494, col: 20, offset: 10859},
+								name: "OLabel",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 494, col: 27, offset: 10866},
+							label: "keyword",
+							expr: &ruleRefExpr{
+								pos:  position{line: 494, col: 35, offset: 10874},
+								name: "OKeyword",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 494, col: 44, offset: 10883},
+							label: "args",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 494, col: 49, offset: 10888},
+								expr: &ruleRefExpr{
+									pos:  position{line: 494, col: 49, offset: 10888},
+									name: "OArg",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "OLabel",
+			pos:  position{line: 498, col: 1, offset: 10994},
+			expr: &choiceExpr{
+				pos: position{line: 498, col: 11, offset: 11004},
+				alternatives: []interface{}{
+					&actionExpr{
+						pos: position{line: 498, col: 11, offset: 11004},
+						run: (*parser).callonOLabel2,
+						expr: &actionExpr{
+							pos: position{line: 343, col: 11, offset: 6828},
+							run: (*parser).callonOLabel3,
+							expr: &oneOrMoreExpr{
+								pos: position{line: 343, col: 12, offset: 6829},
+								expr: &charClassMatcher{
+									pos:             position{line: 343, col: 12, offset: 6829},
+									val:             "[0-9]",
+									ranges:          []rune{'0', '9'},
+									basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false},
+									ignoreCase:      false,
+									inverted:        false,
+								},
+							},
+						},
+					},
+					&actionExpr{
+						pos: position{line: 501, col: 5, offset: 11094},
+						run: (*parser).callonOLabel6,
+						expr: &seqExpr{
+							pos: position{line: 501, col: 5, offset: 11094},
+							exprs: []interface{}{
+								&litMatcher{
+									pos:        position{line: 501, col: 5, offset: 11094},
+									val:        "<",
+									ignoreCase: false,
+									want:       "\"<\"",
+								},
+								&labeledExpr{
+									pos:   position{line: 501, col: 9, offset: 11098},
+									label: "ident",
+									expr: &actionExpr{
+										pos: position{line: 361, col: 10, offset: 7265},
+										run: (*parser).callonOLabel10,
+										expr: &oneOrMoreExpr{
+											pos: position{line: 361, col: 10, offset: 7265},
+											expr: &charClassMatcher{
+												pos:             position{line: 361, col: 11, offset: 7266},
+												val:             "[_0-9a-zA-Z]",
+												chars:           []rune{'_'},
+												ranges:          []rune{'0', '9', 'a', 'z', 'A', 'Z'},
+												basicLatinChars: [128]bool{false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false, false, false, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, false, false, false, false, true, false, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, true, false, false, false, false, false},
+												ignoreCase:      false,
+												inverted:        false,
+											},
+										},
+									},
+								},
+								&litMatcher{
+									pos:        position{line: 501, col: 21, offset: 11110},
+									val:        ">",
+									ignoreCase: false,
+									want:       "\">\"",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "OKeyword",
+			pos:  position{line: 505, col: 1, offset: 11150},
+			expr: &actionExpr{
+				pos: position{line: 505, col: 13, offset: 11162},
+				run: (*parser).callonOKeyword1,
+				expr: &choiceExpr{
+					pos: position{line: 505, col: 15, offset: 11164},
+					alternatives: []interface{}{
+						&litMatcher{
+							pos:        position{line: 505, col: 15, offset: 11164},
+							val:        "ENDSUB",
+							ignoreCase: false,
+							want:       "\"ENDSUB\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 26, offset: 11175},
+							val:        "SUB",
+							ignoreCase: false,
+							want:       "\"SUB\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 34, offset: 11183},
+							val:        "CALL",
+							ignoreCase: false,
+							want:       "\"CALL\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 43, offset: 11192},
+							val:        "RETURN",
+							ignoreCase: false,
+							want:       "\"RETURN\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 54, offset: 11203},
+							val:        "ELSEIF",
+							ignoreCase: false,
+							want:       "\"ELSEIF\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 65, offset: 11214},
+							val:        "ELSE",
+							ignoreCase: false,
+							want:       "\"ELSE\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 74, offset: 11223},
+							val:        "ENDIF",
+							ignoreCase: false,
+							want:       "\"ENDIF\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 84, offset: 11233},
+							val:        "IF",
+							ignoreCase: false,
+							want:       "\"IF\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 91, offset: 11240},
+							val:        "ENDWHILE",
+							ignoreCase: false,
+							want:       "\"ENDWHILE\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 104, offset: 11253},
+							val:        "WHILE",
+							ignoreCase: false,
+							want:       "\"WHILE\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 114, offset: 11263},
+							val:        "DO",
+							ignoreCase: false,
+							want:       "\"DO\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 121, offset: 11270},
+							val:        "ENDREPEAT",
+							ignoreCase: false,
+							want:       "\"ENDREPEAT\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 135, offset: 11284},
+							val:        "REPEAT",
+							ignoreCase: false,
+							want:       "\"REPEAT\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 146, offset: 11295},
+							val:        "BREAK",
+							ignoreCase: false,
+							want:       "\"BREAK\"",
+						},
+						&litMatcher{
+							pos:        position{line: 505, col: 156, offset: 11305},
+							val:        "CONTINUE",
+							ignoreCase: false,
+							want:       "\"CONTINUE\"",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "OArg",
+			pos:  position{line: 509, col: 1, offset: 11354},
+			expr: &actionExpr{
+				pos: position{line: 509, col: 9, offset: 11362},
+				run: (*parser).callonOArg1,
+				expr: &seqExpr{
+					pos: position{line: 509, col: 9, offset: 11362},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 509, col: 9, offset: 11362},
+							val:        "[",
+							ignoreCase: false,
+							want:       "\"[\"",
+						},
+						&labeledExpr{
+							pos:   position{line: 509, col: 13, offset: 11366},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 509, col: 18, offset: 11371},
+								name: "Expr",
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 509, col: 23, offset: 11376},
+							val:        "]",
+							ignoreCase: false,
+							want:       "\"]\"",
+						},
+					},
+				},
+			},
+		},
 	},
 }
 
@@ -2038,7 +2336,7 @@ func (p *parser) callonTermArithPow54() (interface{}, error) {
 }
 
 func (c *current) onAtanCall1(fct, arg1, arg2 interface{}) (interface{}, error) {
-	return FuncOp{Fct: fct.(string), Arg1: arg1.(Expr), Arg2: arg2.(Expr)}, nil
+	return FuncOp{Fct: string(fct.([]byte)), Arg1: arg1.(Expr), Arg2: arg2.(Expr)}, nil
 }
 
 func (p *parser) callonAtanCall1() (interface{}, error) {
@@ -2243,6 +2541,77 @@ func (p *parser) callonWord1() (interface{}, error) {
 	return p.cur.onWord1(stack["word"], stack["expr"])
 }
 
+func (c *current) onOWord1(label, keyword, args interface{}) (interface{}, error) {
+	return OWordExpr{Label: label.(string), Keyword: keyword.(string), Args: exprs(args)}, nil
+}
+
+func (p *parser) callonOWord1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOWord1(stack["label"], stack["keyword"], stack["args"])
+}
+
+func (c *current) onOLabel3() (interface{}, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonOLabel3() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOLabel3()
+}
+
+func (c *current) onOLabel2() (interface{}, error) {
+	num, _ := strconv.Atoi(string(c.text))
+	return strconv.Itoa(num), nil
+}
+
+func (p *parser) callonOLabel2() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOLabel2()
+}
+
+func (c *current) onOLabel10() (interface{}, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonOLabel10() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOLabel10()
+}
+
+func (c *current) onOLabel6(ident interface{}) (interface{}, error) {
+	return ident.(string), nil
+}
+
+func (p *parser) callonOLabel6() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOLabel6(stack["ident"])
+}
+
+func (c *current) onOKeyword1() (interface{}, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonOKeyword1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOKeyword1()
+}
+
+func (c *current) onOArg1(expr interface{}) (interface{}, error) {
+	return expr, nil
+}
+
+func (p *parser) callonOArg1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onOArg1(stack["expr"])
+}
+
 var (
 	// errNoRule is returned when the grammar to parse has no rule.
 	errNoRule = errors.New("grammar has no rule")
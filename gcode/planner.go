@@ -0,0 +1,102 @@
+package gcode
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// planner plans the speed of the machine along a tool path. The path is cut
+// in runs the machine does not stop in, every fragment of a run gets a
+// trapezoidal speed profile
+type planner struct {
+	limits *MachineLimits
+}
+
+// newPlanner plans with the given limits, the default ones for millimeters
+// if nil
+func newPlanner(limits *MachineLimits) *planner {
+	if limits == nil {
+		limits = DefaultLimits(UnitsMM)
+	}
+	return &planner{limits: limits}
+}
+
+// plan plans the speed along a path and returns its runs, the machine stops
+// at the end of every run
+func (p *planner) plan(path []*Fragment) [][]*Fragment {
+	groups := p.groupConnectedComponents(path)
+	for _, group := range groups {
+		planSpeed(group, 0)
+	}
+	return groups
+}
+
+// connect prepares the next fragment of a path and tells if the machine
+// goes on into it from previous without stopping, at its blend speed
+func (p *planner) connect(previous *Fragment, f *Fragment) bool {
+	prepareFragment(f, p.limits)
+	sqSpeed, ok := blendSqSpeed(previous, f)
+	f.blendSqSpeed = sqSpeed
+	return ok
+}
+
+// groupConnectedComponents cuts a path in the runs the machine does not
+// stop in
+func (p *planner) groupConnectedComponents(path []*Fragment) [][]*Fragment {
+	groups := make([][]*Fragment, 0)
+	var previous *Fragment
+	for _, component := range path {
+		if p.connect(previous, component) {
+			groups[len(groups)-1] = append(groups[len(groups)-1], component)
+		} else {
+			groups = append(groups, []*Fragment{component})
+		}
+		previous = component
+	}
+	return groups
+}
+
+func (t SpeedType) String() string {
+	switch t {
+	case SpeedAccel:
+		return "accel"
+	case SpeedDecel:
+		return "decel"
+	}
+	return "cruise"
+}
+
+// speedSegment is a part of a fragment where the speed changes at a
+// constant acceleration
+type speedSegment struct {
+	Type SpeedType
+	// distances from the start of the fragment
+	Start float32
+	Stop  float32
+	// in units per second
+	StartSpeed float32
+	StopSpeed  float32
+	// in seconds
+	Duration float32
+}
+
+// profile is the planned speed along the fragment, in order
+func (f *Fragment) profile() []speedSegment {
+	out := make([]speedSegment, 0, len(f.RunFragments))
+	for _, rf := range f.RunFragments {
+		segment := speedSegment{
+			Type:     rf.tp,
+			Start:    rf.startX,
+			Stop:     rf.stopX,
+			Duration: rf.duration,
+		}
+		if rf.tp == SpeedConst {
+			segment.StartSpeed = mgl32.Sqrt(rf.squaredSpeed)
+			segment.StopSpeed = segment.StartSpeed
+		} else {
+			segment.StartSpeed = mgl32.Sqrt(rf.fromSqSpeed)
+			segment.StopSpeed = mgl32.Sqrt(rf.toSqSpeed)
+		}
+		out = append(out, segment)
+	}
+	return out
+}
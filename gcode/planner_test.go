@@ -0,0 +1,204 @@
+package gcode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// limits of the hand computed profiles: 100 mm/s and 1000 mm/s^2
+func testLimits() *MachineLimits {
+	return &MachineLimits{
+		MaxPathVelocity:     100,
+		MaxPathAcceleration: 1000,
+		DefaultVelocity:     100,
+		FeedOverride:        1,
+		RapidOverride:       1,
+	}
+}
+
+func planProgram(t *testing.T, program string) [][]*Fragment {
+	t.Helper()
+	path, _, _, diagnostics := Evaluate(program, 6000, 6000, mgl32.Vec3{}, nil, UnitsMM, nil, mgl32.Vec3{})
+	if len(diagnostics) > 0 {
+		t.Fatalf("diagnostics: %v", diagnostics)
+	}
+	return newPlanner(testLimits()).plan(path)
+}
+
+func near(a, b float32) bool {
+	return mgl32.Abs(a-b) < 1e-3
+}
+
+func TestPlannerProfiles(t *testing.T) {
+	// the speed of a 90 degrees corner blended within 0.01: the arc of
+	// radius 0.01 sin(45) / (1 - sin(45)) at 1000 mm/s^2
+	corner := mgl32.Sqrt(1000 * 0.01 * 0.70710677 / (1 - 0.70710677))
+	tests := []struct {
+		name    string
+		program string
+		// segments of the fragments of every group
+		groups [][][]speedSegment
+	}{
+		{
+			name:    "line reaching cruise",
+			program: "G1 X100 F3000",
+			groups: [][][]speedSegment{{{
+				{Type: SpeedAccel, Start: 0, Stop: 1.25, StartSpeed: 0, StopSpeed: 50, Duration: 0.05},
+				{Type: SpeedConst, Start: 1.25, Stop: 98.75, StartSpeed: 50, StopSpeed: 50, Duration: 1.95},
+				{Type: SpeedDecel, Start: 98.75, Stop: 100, StartSpeed: 50, StopSpeed: 0, Duration: 0.05},
+			}}},
+		},
+		{
+			name:    "short triangular move",
+			program: "G1 X1 F3000",
+			groups: [][][]speedSegment{{{
+				{Type: SpeedAccel, Start: 0, Stop: 0.5, StartSpeed: 0, StopSpeed: 31.6228, Duration: 0.0316228},
+				{Type: SpeedDecel, Start: 0.5, Stop: 1, StartSpeed: 31.6228, StopSpeed: 0, Duration: 0.0316228},
+			}}},
+		},
+		{
+			name:    "G61 stops between moves",
+			program: "G61 G1 X10 F3000\nX20",
+			groups: [][][]speedSegment{
+				{{
+					{Type: SpeedAccel, Start: 0, Stop: 1.25, StartSpeed: 0, StopSpeed: 50, Duration: 0.05},
+					{Type: SpeedConst, Start: 1.25, Stop: 8.75, StartSpeed: 50, StopSpeed: 50, Duration: 0.15},
+					{Type: SpeedDecel, Start: 8.75, Stop: 10, StartSpeed: 50, StopSpeed: 0, Duration: 0.05},
+				}},
+				{{
+					{Type: SpeedAccel, Start: 0, Stop: 1.25, StartSpeed: 0, StopSpeed: 50, Duration: 0.05},
+					{Type: SpeedConst, Start: 1.25, Stop: 8.75, StartSpeed: 50, StopSpeed: 50, Duration: 0.15},
+					{Type: SpeedDecel, Start: 8.75, Stop: 10, StartSpeed: 50, StopSpeed: 0, Duration: 0.05},
+				}},
+			},
+		},
+		{
+			name:    "G64 blends straight moves",
+			program: "G64 G1 X10 F3000\nX20",
+			groups: [][][]speedSegment{{
+				{
+					{Type: SpeedAccel, Start: 0, Stop: 1.25, StartSpeed: 0, StopSpeed: 50, Duration: 0.05},
+					{Type: SpeedConst, Start: 1.25, Stop: 10, StartSpeed: 50, StopSpeed: 50, Duration: 0.175},
+				},
+				{
+					{Type: SpeedConst, Start: 0, Stop: 8.75, StartSpeed: 50, StopSpeed: 50, Duration: 0.175},
+					{Type: SpeedDecel, Start: 8.75, Stop: 10, StartSpeed: 50, StopSpeed: 0, Duration: 0.05},
+				},
+			}},
+		},
+		{
+			name:    "G64 slows down in a corner",
+			program: "G64 P0.01 G1 X10 F3000\nY10",
+			groups: [][][]speedSegment{{
+				{
+					{Type: SpeedAccel, Start: 0, Stop: 1.25, StartSpeed: 0, StopSpeed: 50, Duration: 0.05},
+					{Type: SpeedConst, Start: 1.25, Stop: 10 - (2500-corner*corner)/2000, StartSpeed: 50, StopSpeed: 50},
+					{Type: SpeedDecel, Start: 10 - (2500-corner*corner)/2000, Stop: 10, StartSpeed: 50, StopSpeed: corner, Duration: (50 - corner) / 1000},
+				},
+				{
+					{Type: SpeedAccel, Start: 0, Stop: (2500 - corner*corner) / 2000, StartSpeed: corner, StopSpeed: 50, Duration: (50 - corner) / 1000},
+					{Type: SpeedConst, Start: (2500 - corner*corner) / 2000, Stop: 8.75, StartSpeed: 50, StopSpeed: 50},
+					{Type: SpeedDecel, Start: 8.75, Stop: 10, StartSpeed: 50, StopSpeed: 0, Duration: 0.05},
+				},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			groups := planProgram(t, test.program)
+			if len(groups) != len(test.groups) {
+				t.Fatalf("got %d groups, want %d", len(groups), len(test.groups))
+			}
+			for g, group := range groups {
+				if len(group) != len(test.groups[g]) {
+					t.Fatalf("group %d: got %d fragments, want %d", g, len(group), len(test.groups[g]))
+				}
+				for i, fragment := range group {
+					got, want := fragment.profile(), test.groups[g][i]
+					if len(got) != len(want) {
+						t.Fatalf("group %d fragment %d: got %+v, want %+v", g, i, got, want)
+					}
+					duration := float32(0)
+					for k := range got {
+						a, b := got[k], want[k]
+						// a cruise duration of 0 is not checked, it is
+						// the rest of the fragment
+						if a.Type != b.Type || !near(a.Start, b.Start) || !near(a.Stop, b.Stop) ||
+							!near(a.StartSpeed, b.StartSpeed) || !near(a.StopSpeed, b.StopSpeed) ||
+							(b.Duration != 0 && !near(a.Duration, b.Duration)) {
+							t.Errorf("group %d fragment %d segment %d: got %+v, want %+v", g, i, k, a, b)
+						}
+						duration += a.Duration
+					}
+					if !near(fragment.duration, duration) {
+						t.Errorf("group %d fragment %d: duration %f, segments %f", g, i, fragment.duration, duration)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPlannerSpeedAt(t *testing.T) {
+	groups := planProgram(t, "G1 X100 F3000")
+	f := groups[0][0]
+	if !near(f.length, 100) || f.lineNo != 0 || f.speedTag == RapidSpeedTag {
+		t.Fatalf("got length %f, line %d, speed %v", f.length, f.lineNo, f.speedTag)
+	}
+	tests := []struct {
+		ratio float32
+		speed float32
+		time  float32
+	}{
+		{0, 0, 0},
+		// half way through the acceleration: v^2 = 2 a x
+		{0.00625, mgl32.Sqrt(2 * 1000 * 0.625), mgl32.Sqrt(2 * 0.625 / 1000)},
+		{0.5, 50, 0.05 + 48.75/50},
+		{1, 0, 2.05},
+	}
+	for _, test := range tests {
+		speed, time := dataForRatio(f, test.ratio)
+		if !near(speed, test.speed) || !near(time, test.time) {
+			t.Errorf("at %f: got %f at %f, want %f at %f", test.ratio, speed, time, test.speed, test.time)
+		}
+	}
+}
+
+func TestGroupConnectedComponents(t *testing.T) {
+	path, _, _, _ := Evaluate("G64 G1 X10 F3000\nX20\nX30\nG61 X40\nX50\nG0 Z10", 6000, 6000, mgl32.Vec3{}, nil, UnitsMM, nil, mgl32.Vec3{})
+	groups := newPlanner(testLimits()).groupConnectedComponents(path)
+	// the machine stops after the G61 move, and G61 stays active: X50 and
+	// the G0 run alone too
+	sizes := make([]int, len(groups))
+	for i, group := range groups {
+		sizes[i] = len(group)
+	}
+	if fmt.Sprint(sizes) != "[4 1 1]" {
+		t.Fatalf("got groups of %v fragments, want [4 1 1]", sizes)
+	}
+	if groups[2][0].speedTag != RapidSpeedTag {
+		t.Errorf("the last group is not the rapid move")
+	}
+}
+
+func TestStreamPlanMatchesPlan(t *testing.T) {
+	// a blended run longer than the streamed groups, in tiny moves
+	var b strings.Builder
+	b.WriteString("G64 G1 F6000\n")
+	for i := 0; i < 3*maxStreamGroup; i++ {
+		b.WriteString(fmt.Sprintf("X%.3f Y%.3f\n", float32(i)*0.01, float32(i%7)*0.001))
+	}
+	b.WriteString("G0 Z10\n")
+	program := b.String()
+	_, _, stats, _ := SimulateGCode(program, nil, UnitsMM, nil, testLimits())
+	_, streamStats, _, err := SimulateGCodeStream(strings.NewReader(program), nil, UnitsMM, nil, testLimits(), mgl32.Vec3{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(stats.TotalTime, streamStats.TotalTime) {
+		t.Errorf("streamed time %f, planned %f", streamStats.TotalTime, stats.TotalTime)
+	}
+}
@@ -3,7 +3,8 @@ package gcode
 import (
 	"errors"
 	"math"
-	"reflect"
+	"sort"
+	"strconv"
 
 	"github.com/adragomir/linuxcncgo/gcode/parser"
 	"github.com/adragomir/linuxcncgo/ui/glutil"
@@ -86,6 +87,8 @@ type Fragment struct {
 	tp              FragmentType
 	from            mgl32.Vec3
 	to              mgl32.Vec3
+	fromExtra       ExtraAxes
+	toExtra         ExtraAxes
 	plane           Plane
 	center          mgl32.Vec2
 	centerInPlane   mgl32.Vec2
@@ -95,11 +98,21 @@ type Fragment struct {
 	feedRate        float32
 	lineNo          int
 	speedTag        SpeedTagType
+	dwell           float32
+	// tool in the spindle
+	tool int
+	// path control mode the move ends in, and the G64 P tolerance
+	pathControl    string
+	blendTolerance float32
 
 	length       float32
 	duration     float32
 	squaredSpeed float32
 	maxAccel     float32
+	// highest squared speed the fragment is entered at
+	blendSqSpeed float32
+	// simulated time the fragment starts at
+	startTime float32
 
 	RunData      map[SpeedType]SpeedData
 	RunFragments []*RunFragment
@@ -107,6 +120,7 @@ type Fragment struct {
 
 type MachineState struct {
 	position       mgl32.Vec3
+	extra          ExtraAxes
 	lineNo         int
 	distanceMode   func(mgl32.Vec3, Move) mgl32.Vec3
 	motionMode     func([]parser.Expr, *MachineState)
@@ -114,9 +128,31 @@ type MachineState struct {
 	feedRate       float32
 	travelFeedRate float32
 	pathControl    string
+	blendTolerance float32
 	path           []*Fragment
 	origins        []mgl32.Vec3
 	currentOrigin  int
+	g92Offset      mgl32.Vec3
+	modes          map[ModalGroup]string
+	params         *Parameters
+	retractMode    string
+	cycleWords     map[string]float32
+	tools          ToolTable
+	tool           int
+	preparedTool   int
+	compensation   *Compensation
+	programUnits   Units
+	machineUnits   Units
+
+	toolLengthOffset   float32
+	programEnd         bool
+	machineCoordinates bool
+
+	diagnostics []parser.Diagnostic
+
+	// streamed programs do not keep the path
+	keepPath     bool
+	lastFragment *Fragment
 
 	accumulator *Accumulator
 	fragmentCb  func(*Fragment)
@@ -127,16 +163,28 @@ var GROUPS_TRANSITIONS = map[string]interface{}{
 	"1":    map[string]interface{}{"motionMode": moveG1},
 	"2":    map[string]interface{}{"motionMode": moveG2},
 	"3":    map[string]interface{}{"motionMode": moveG3},
-	"4":    nil,
+	"73":   map[string]interface{}{"motionMode": moveG73},
+	"76":   map[string]interface{}{"motionMode": moveG76},
+	"4":    map[string]interface{}{"dwell": true},
+	"10":   map[string]interface{}{"offsets": "10"},
+	"53":   map[string]interface{}{"machineCoordinates": true},
+	"92":   map[string]interface{}{"offsets": "92"},
+	"92.1": map[string]interface{}{"offsets": "92.1"},
+	"92.2": map[string]interface{}{"offsets": "92.2"},
+	"92.3": map[string]interface{}{"offsets": "92.3"},
 	"17":   map[string]interface{}{"planeMode": XY_PLANE},
 	"18":   map[string]interface{}{"planeMode": XZ_PLANE},
 	"19":   map[string]interface{}{"planeMode": YZ_PLANE},
-	"20":   nil,
-	"21":   "unsupported",
-	"40":   "unsupported",
-	"41":   "unsupported",
-	"42":   "unsupported",
-	"49":   nil,
+	"20":   map[string]interface{}{"units": UnitsInch},
+	"21":   map[string]interface{}{"units": UnitsMM},
+	"40":   map[string]interface{}{"compensation": "40"},
+	"41":   map[string]interface{}{"compensation": "41"},
+	"41.1": map[string]interface{}{"compensation": "41.1"},
+	"42":   map[string]interface{}{"compensation": "42"},
+	"42.1": map[string]interface{}{"compensation": "42.1"},
+	"43":   map[string]interface{}{"toolLengthOffset": "43"},
+	"43.1": map[string]interface{}{"toolLengthOffset": "43.1"},
+	"49":   map[string]interface{}{"toolLengthOffset": "49"},
 	"54":   map[string]interface{}{"currentOrigin": 1},
 	"55":   map[string]interface{}{"currentOrigin": 2},
 	"56":   map[string]interface{}{"currentOrigin": 3},
@@ -150,9 +198,20 @@ var GROUPS_TRANSITIONS = map[string]interface{}{
 	"61.1": map[string]interface{}{"pathControl": "61.1"},
 	"64":   map[string]interface{}{"pathControl": "64"},
 	"80":   map[string]interface{}{"motionMode": moveG80},
+	"81":   map[string]interface{}{"motionMode": moveG81},
+	"82":   map[string]interface{}{"motionMode": moveG82},
+	"83":   map[string]interface{}{"motionMode": moveG83},
+	"84":   map[string]interface{}{"motionMode": moveG84},
+	"85":   map[string]interface{}{"motionMode": moveG85},
+	"86":   map[string]interface{}{"motionMode": moveG86},
+	"87":   map[string]interface{}{"motionMode": moveG87},
+	"88":   map[string]interface{}{"motionMode": moveG88},
+	"89":   map[string]interface{}{"motionMode": moveG89},
 	"90":   map[string]interface{}{"distanceMode": absoluteDistanceMode},
 	"91":   map[string]interface{}{"distanceMode": incrementalDistanceMode},
 	"94":   nil,
+	"98":   map[string]interface{}{"retractMode": "98"},
+	"99":   map[string]interface{}{"retractMode": "99"},
 }
 
 func NewMachineState(feedRate float32, travelFeedRate float32, initialPosition mgl32.Vec3) *MachineState {
@@ -168,30 +227,149 @@ func NewMachineState(feedRate float32, travelFeedRate float32, initialPosition m
 		planeMode:      XY_PLANE,
 		feedRate:       feedRate,
 		travelFeedRate: travelFeedRate,
-		pathControl:    "61",
+		pathControl:    "64",
 		path:           make([]*Fragment, 0),
 		origins:        origins,
 		currentOrigin:  1,
-
+		modes: map[ModalGroup]string{
+			GroupMotion:             "0",
+			GroupPlane:              "17",
+			GroupDistance:           "90",
+			GroupFeedRateMode:       "94",
+			GroupCutterCompensation: "40",
+			GroupToolLengthOffset:   "49",
+			GroupRetract:            "98",
+			GroupCoordinateSystem:   "54",
+			GroupPathControl:        "64",
+		},
+		params:      NewParameters(),
+		retractMode: "98",
+		cycleWords:  make(map[string]float32),
+		tools:       make(ToolTable),
+		diagnostics: make([]parser.Diagnostic, 0),
+
+		keepPath:    true,
 		accumulator: accumulator,
 		fragmentCb:  accumulator.FragmentListener,
 	}
+	tmp.compensation = NewCompensation(initialPosition, tmp.emitPathFragment)
+	tmp.selectOrigin(tmp.currentOrigin)
 	return tmp
 }
 
 func (ms *MachineState) absolutePoint(in Move) mgl32.Vec3 {
-	currentOrigin := ms.origins[ms.currentOrigin]
-	out := Move{}
-	for i, key := range []string{"X", "Y", "Z"} {
-		if val, ok := in[key]; ok {
-			out[key] = val + currentOrigin[i]
+	return ms.absolutePointFrom(ms.position, in)
+}
+
+// absolutePointFrom applies a move in program coordinates to a point in
+// machine coordinates. The distance mode works in program coordinates, the
+// work offset and the tool length offset are added back afterwards
+func (ms *MachineState) absolutePointFrom(from mgl32.Vec3, in Move) mgl32.Vec3 {
+	offset := ms.offset()
+	return ms.distanceMode(from.Sub(offset), in).Add(offset)
+}
+
+// offset is the origin of the program coordinates in machine coordinates:
+// the active coordinate system, the G92 offset and the tool length offset.
+// G53 moves are in machine coordinates
+func (ms *MachineState) offset() mgl32.Vec3 {
+	if ms.machineCoordinates {
+		return mgl32.Vec3{}
+	}
+	return ms.origins[ms.currentOrigin].Add(ms.g92Offset).Add(ms.toolOffset())
+}
+
+func (ms *MachineState) toolOffset() mgl32.Vec3 {
+	return mgl32.Vec3{0, 0, ms.toolLengthOffset}
+}
+
+// words that are lengths, and are converted from program to machine units
+var LENGTH_WORDS = map[string]bool{
+	"X": true, "Y": true, "Z": true,
+	"I": true, "J": true, "K": true,
+	"R": true, "Q": true, "F": true,
+	"U": true, "V": true, "W": true,
+}
+
+// toMachineUnits scales the length words of a line to machine units. G76
+// uses R, Q as a ratio and an angle and P as the pitch, G41.1 / G42.1 use
+// D as the tool diameter, M61 uses Q as the tool number and G64 uses P as
+// the tolerance
+func (ms *MachineState) toMachineUnits(line []parser.Expr, b *block) []parser.Expr {
+	scale := ms.programUnits.Millimeters() / ms.machineUnits.Millimeters()
+	if scale == 1 {
+		return line
+	}
+	out := make([]parser.Expr, 0, len(line))
+	for _, tmp := range line {
+		we, ok := tmp.(parser.WordExpr)
+		if !ok {
+			out = append(out, tmp)
+			continue
 		}
+		isLength := LENGTH_WORDS[we.Word]
+		motion := b.g[GroupMotion]
+		compensation := b.g[GroupCutterCompensation]
+		switch {
+		case motion == "76" && (we.Word == "R" || we.Word == "Q"):
+			isLength = false
+		case motion == "76" && we.Word == "P":
+			isLength = true
+		case (compensation == "41.1" || compensation == "42.1") && we.Word == "D":
+			isLength = true
+		case b.hasM(GroupToolChange, 61) && we.Word == "Q":
+			isLength = false
+		case b.g[GroupPathControl] == "64" && we.Word == "P":
+			isLength = true
+		}
+		if isLength {
+			val := we.Val.(parser.ConstExpr).Val * float64(scale)
+			we = parser.WordExpr{
+				Word: we.Word,
+				Val: parser.ConstExpr{
+					Val:    val,
+					RawVal: strconv.FormatFloat(val, 'f', -1, 64),
+				},
+			}
+		}
+		out = append(out, we)
 	}
-	return ms.distanceMode(ms.position, out)
+	return out
+}
+
+func withoutWords(line []parser.Expr, words ...string) []parser.Expr {
+	out := make([]parser.Expr, 0, len(line))
+	for _, tmp := range line {
+		if we, ok := tmp.(parser.WordExpr); ok {
+			skip := false
+			for _, word := range words {
+				skip = skip || we.Word == word
+			}
+			if skip {
+				continue
+			}
+		}
+		out = append(out, tmp)
+	}
+	return out
 }
 
 func (ms *MachineState) addPathFragment(p *Fragment) {
-	ms.path = append(ms.path, p)
+	p.pathControl, p.blendTolerance = ms.pathControl, ms.blendTolerance
+	p.tool = ms.tool
+	ms.compensation.Push(p)
+}
+
+func (ms *MachineState) emitPathFragment(p *Fragment) {
+	if p.pathControl == "" {
+		// made by the cutter compensation
+		p.pathControl, p.blendTolerance = ms.pathControl, ms.blendTolerance
+		p.tool = ms.tool
+	}
+	if ms.keepPath {
+		ms.path = append(ms.path, p)
+	}
+	ms.lastFragment = p
 	if ms.fragmentCb != nil {
 		ms.fragmentCb(p)
 	}
@@ -199,6 +377,14 @@ func (ms *MachineState) addPathFragment(p *Fragment) {
 
 type Move map[string]float32
 
+var AXIS_NAMES = []string{"X", "Y", "Z"}
+
+var MOVE_WORDS = map[string]bool{
+	"X": true, "Y": true, "Z": true,
+	"A": true, "B": true, "C": true,
+	"U": true, "V": true, "W": true,
+}
+
 func moveG0(line []parser.Expr, ms *MachineState) {
 	moveStraight(line, ms, ms.travelFeedRate, RapidSpeedTag)
 }
@@ -234,13 +420,8 @@ func detectMove(line []parser.Expr) Move {
 		switch tmp.(type) {
 		case parser.WordExpr:
 			we := tmp.(parser.WordExpr)
-			switch we.Word {
-			case "X":
-				result["X"] = float32(we.Val.(parser.ConstExpr).Val)
-			case "Y":
-				result["Y"] = float32(we.Val.(parser.ConstExpr).Val)
-			case "Z":
-				result["Z"] = float32(we.Val.(parser.ConstExpr).Val)
+			if MOVE_WORDS[we.Word] {
+				result[we.Word] = float32(we.Val.(parser.ConstExpr).Val)
 			}
 		}
 	}
@@ -255,11 +436,14 @@ func moveArc(line []parser.Expr, clockwise bool, ms *MachineState) {
 
 	currentPosition := ms.position
 	targetPos := ms.absolutePoint(move)
+	targetExtra := ms.extraPoint(move)
 	plane := ms.planeMode
 	xCoord := plane.firstCoord
 	yCoord := plane.secondCoord
 	radius, toCenterX, toCenterY, err := findCircle(line, targetPos, plane, currentPosition, clockwise)
-	if err == nil {
+	if err != nil {
+		ms.reportAt(ms.lineNo, parser.SeverityError, CodeArc, "%v, the arc is not drawn", err)
+	} else {
 		centerX := currentPosition[xCoord] + toCenterX
 		centerY := currentPosition[yCoord] + toCenterY
 		targetCenterX := targetPos[xCoord] - centerX
@@ -279,6 +463,8 @@ func moveArc(line []parser.Expr, clockwise bool, ms *MachineState) {
 			tp:              ArcFragmentType,
 			from:            currentPosition,
 			to:              targetPos,
+			fromExtra:       ms.extra,
+			toExtra:         targetExtra,
 			plane:           plane,
 			center:          mgl32.Vec2{centerX, centerY},
 			centerInPlane:   mgl32.Vec2{centerX, centerY},
@@ -293,6 +479,7 @@ func moveArc(line []parser.Expr, clockwise bool, ms *MachineState) {
 			RunData:      make(map[SpeedType]SpeedData),
 		})
 		ms.position = targetPos
+		ms.extra = targetExtra
 	}
 }
 
@@ -319,7 +506,7 @@ func findCircle(line []parser.Expr, targetPos mgl32.Vec3, plane Plane, currentPo
 		xMatch, okx := lineMap[plane.firstCenterCoord]
 		yMatch, oky := lineMap[plane.secondCenterCoord]
 		if !okx && !oky {
-			err = errors.New("no center")
+			err = errors.New("arc with no center (I, J, K) nor radius (R)")
 		}
 		if okx {
 			toCenterX = xMatch
@@ -340,28 +527,39 @@ func moveStraight(line []parser.Expr, ms *MachineState, feedRate float32, speedT
 	move := detectMove(line)
 	if len(move) > 0 {
 		apoint := ms.absolutePoint(move)
-		addPathComponent(apoint, ms, feedRate, speedTag)
+		addAxesPathComponent(apoint, ms.extraPoint(move), ms, feedRate, speedTag)
 	}
 }
 
 func addPathComponent(apoint mgl32.Vec3, ms *MachineState, feedRate float32, speedTag SpeedTagType) {
+	addAxesPathComponent(apoint, ms.extra, ms, feedRate, speedTag)
+}
+
+// addAxesPathComponent adds a straight move of all the axes
+func addAxesPathComponent(apoint mgl32.Vec3, extra ExtraAxes, ms *MachineState, feedRate float32, speedTag SpeedTagType) {
 	hasMove := false
 	for i := 0; i < 3; i++ {
 		hasMove = hasMove || mgl32.Abs(apoint[i]-ms.position[i]) > 0.00001
 	}
+	for i := range extra {
+		hasMove = hasMove || mgl32.Abs(extra[i]-ms.extra[i]) > 0.00001
+	}
 	if hasMove {
 		ms.addPathFragment(&Fragment{
-			tp:       LineFragmentType,
-			from:     ms.position,
-			to:       apoint,
-			feedRate: feedRate,
-			lineNo:   ms.lineNo,
-			speedTag: speedTag,
+			tp:        LineFragmentType,
+			from:      ms.position,
+			to:        apoint,
+			fromExtra: ms.extra,
+			toExtra:   extra,
+			feedRate:  feedRate,
+			lineNo:    ms.lineNo,
+			speedTag:  speedTag,
 
 			RunFragments: make([]*RunFragment, 0),
 			RunData:      make(map[SpeedType]SpeedData),
 		})
 		ms.position = apoint
+		ms.extra = extra
 	}
 }
 
@@ -369,6 +567,293 @@ func moveG80(line []parser.Expr, ms *MachineState) {
 	// do Nothing
 }
 
+// clearance above the previous peck depth for G73 / G83 in mm, 0.010 inch
+// as in LinuxCNC
+const peckClearance = float32(0.254)
+
+// drillCycle holds the heights of a canned cycle along the drilling axis,
+// which is the axis normal to the active plane
+type drillCycle struct {
+	axis   int
+	r      float32
+	bottom float32
+	clear  float32
+	words  map[string]float32
+}
+
+func (c *drillCycle) direction() float32 {
+	if c.bottom < c.r {
+		return -1
+	}
+	return 1
+}
+
+func cycleMove(ms *MachineState, axis int, val float32, speedTag SpeedTagType) {
+	target := ms.position
+	target[axis] = val
+	feedRate := ms.feedRate
+	if speedTag == RapidSpeedTag {
+		feedRate = ms.travelFeedRate
+	}
+	addPathComponent(target, ms, feedRate, speedTag)
+}
+
+func cycleDwell(ms *MachineState, c *drillCycle) {
+	ms.dwell(c.words)
+}
+
+// cannedCycle does the moves shared by all drilling cycles: position over
+// the hole at the clear height, rapid down to R, run the cycle and retract
+// to R (G99) or to the initial height (G98). R, Q, P and the drilling axis
+// are sticky while the cycle is active; L repeats the cycle, in incremental
+// mode every repeat moves by X, Y again
+func cannedCycle(line []parser.Expr, ms *MachineState, cycle func(*MachineState, *drillCycle)) {
+	move := detectMove(line)
+	if len(move) == 0 {
+		return
+	}
+	lineMap, _ := mapify(line)
+	plane := ms.planeMode
+	axis := plane.lastCoord
+	axisName := AXIS_NAMES[axis]
+	for _, word := range []string{axisName, "R", "Q", "P", "I", "J", "K"} {
+		if val, ok := lineMap[word]; ok {
+			ms.cycleWords[word] = val
+		}
+	}
+	bottom, okBottom := ms.cycleWords[axisName]
+	r, okR := ms.cycleWords["R"]
+	if !okBottom || !okR {
+		ms.report(parser.SeverityError, CodeCannedCycle, "%s and R are required", axisName)
+		return
+	}
+	repeats := 1
+	if l, ok := lineMap["L"]; ok {
+		repeats = int(l)
+	}
+
+	initial := ms.position[axis]
+	rPoint := ms.absolutePoint(Move{axisName: r})
+	c := &drillCycle{
+		axis:   axis,
+		r:      rPoint[axis],
+		bottom: ms.absolutePointFrom(rPoint, Move{axisName: bottom})[axis],
+		words:  ms.cycleWords,
+	}
+	c.clear = c.r
+	if ms.retractMode == "98" && initial > c.r {
+		c.clear = initial
+	}
+	if initial < c.r {
+		cycleMove(ms, axis, c.r, RapidSpeedTag)
+	}
+
+	position := Move{}
+	for _, coord := range []int{plane.firstCoord, plane.secondCoord} {
+		if val, ok := move[AXIS_NAMES[coord]]; ok {
+			position[AXIS_NAMES[coord]] = val
+		}
+	}
+	for i := 0; i < repeats; i++ {
+		target := ms.absolutePoint(position)
+		target[axis] = ms.position[axis]
+		addPathComponent(target, ms, ms.travelFeedRate, RapidSpeedTag)
+		cycleMove(ms, axis, c.r, RapidSpeedTag)
+		cycle(ms, c)
+		cycleMove(ms, axis, c.clear, RapidSpeedTag)
+	}
+}
+
+func pecks(ms *MachineState, c *drillCycle, retract func(depth float32)) {
+	q := c.words["Q"]
+	if q <= 0 {
+		ms.report(parser.SeverityError, CodeCannedCycle, "Q must be positive")
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		return
+	}
+	dir := c.direction()
+	depth := c.r
+	for i := 0; i < maxLoopIterations; i++ {
+		next := depth + dir*q
+		if dir*(next-c.bottom) > 0 {
+			next = c.bottom
+		}
+		cycleMove(ms, c.axis, next, NormalSpeedTag)
+		if next == c.bottom {
+			return
+		}
+		retract(next)
+		depth = next
+	}
+}
+
+// G73, peck drilling with chip breaking
+func moveG73(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		pecks(ms, c, func(depth float32) {
+			cycleMove(ms, c.axis, depth-c.direction()*peckClearance/ms.machineUnits.Millimeters(), RapidSpeedTag)
+		})
+	})
+}
+
+// G81, drilling
+func moveG81(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+	})
+}
+
+// G82, drilling with dwell
+func moveG82(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		cycleDwell(ms, c)
+	})
+}
+
+// G83, peck drilling, retracting to R after every peck
+func moveG83(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		pecks(ms, c, func(depth float32) {
+			cycleMove(ms, c.axis, c.r, RapidSpeedTag)
+			cycleMove(ms, c.axis, depth-c.direction()*peckClearance/ms.machineUnits.Millimeters(), RapidSpeedTag)
+		})
+	})
+}
+
+// G84, right hand tapping, the spindle reverses at the bottom
+func moveG84(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		cycleDwell(ms, c)
+		cycleMove(ms, c.axis, c.r, NormalSpeedTag)
+	})
+}
+
+// G85, boring, feed out
+func moveG85(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		cycleMove(ms, c.axis, c.r, NormalSpeedTag)
+	})
+}
+
+// G86, boring, spindle stop, rapid out
+func moveG86(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		cycleDwell(ms, c)
+	})
+}
+
+// G87, back boring. I, J offset the tool to pass through the hole, K is the
+// height of the back bore
+func moveG87(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		plane := ms.planeMode
+		center := ms.position
+		offset := center
+		offset[plane.firstCoord] += c.words[plane.firstCenterCoord]
+		offset[plane.secondCoord] += c.words[plane.secondCenterCoord]
+		k, ok := c.words["K"]
+		if !ok {
+			ms.report(parser.SeverityError, CodeCannedCycle, "G87 needs K")
+			return
+		}
+		bottomPoint := center
+		bottomPoint[c.axis] = c.bottom
+		top := ms.absolutePointFrom(bottomPoint, Move{AXIS_NAMES[c.axis]: k})[c.axis]
+
+		addPathComponent(offset, ms, ms.travelFeedRate, RapidSpeedTag)
+		cycleMove(ms, c.axis, c.bottom, RapidSpeedTag)
+		addPathComponent(bottomPoint, ms, ms.travelFeedRate, RapidSpeedTag)
+		cycleMove(ms, c.axis, top, NormalSpeedTag)
+		cycleDwell(ms, c)
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		offset[c.axis] = c.bottom
+		addPathComponent(offset, ms, ms.travelFeedRate, RapidSpeedTag)
+		cycleMove(ms, c.axis, c.clear, RapidSpeedTag)
+		center[c.axis] = c.clear
+		addPathComponent(center, ms, ms.travelFeedRate, RapidSpeedTag)
+	})
+}
+
+// G88, boring, spindle stop, manual retract. The operator jogs out, which
+// is simulated as a rapid retract
+func moveG88(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		cycleDwell(ms, c)
+	})
+}
+
+// G89, boring, dwell, feed out
+func moveG89(line []parser.Expr, ms *MachineState) {
+	cannedCycle(line, ms, func(ms *MachineState, c *drillCycle) {
+		cycleMove(ms, c.axis, c.bottom, NormalSpeedTag)
+		cycleDwell(ms, c)
+		cycleMove(ms, c.axis, c.r, NormalSpeedTag)
+	})
+}
+
+// G76, LinuxCNC lathe threading cycle in the XZ plane. The current position
+// is the drive line; I is the thread peak offset, J the first cut depth,
+// K the full depth, R the depth degression, Q the compound angle and H the
+// number of spring passes. The tapers (E, L) are not simulated
+func moveG76(line []parser.Expr, ms *MachineState) {
+	// G76 stays the motion mode, blocks without axis words do not thread
+	if len(detectMove(line)) == 0 {
+		return
+	}
+	lineMap, _ := mapify(line)
+	for _, word := range []string{"P", "Z", "I", "J", "K"} {
+		if _, ok := lineMap[word]; !ok {
+			ms.report(parser.SeverityError, CodeCannedCycle, "G76 needs %s", word)
+			return
+		}
+	}
+	j := lineMap["J"]
+	k := lineMap["K"]
+	if j <= 0 || k <= 0 {
+		ms.report(parser.SeverityError, CodeCannedCycle, "G76 J and K must be positive")
+		return
+	}
+	degression := float32(1.0)
+	if r, ok := lineMap["R"]; ok && r >= 1 {
+		degression = r
+	}
+	compound := float32(math.Tan(degToRad(float64(lineMap["Q"]))))
+	springPasses := int(lineMap["H"])
+
+	start := ms.position
+	end := ms.absolutePoint(Move{"Z": lineMap["Z"]})[2]
+	peak := start[0] + lineMap["I"]
+	depthDir := float32(1.0)
+	if lineMap["I"] < 0 {
+		depthDir = -1
+	}
+	zDir := float32(1.0)
+	if end < start[2] {
+		zDir = -1
+	}
+	pass := func(depth float32) {
+		shift := zDir * depth * compound
+		cycleMove(ms, 2, start[2]+shift, RapidSpeedTag)
+		cycleMove(ms, 0, peak+depthDir*depth, RapidSpeedTag)
+		cycleMove(ms, 2, end+shift, NormalSpeedTag)
+		cycleMove(ms, 0, start[0], RapidSpeedTag)
+		cycleMove(ms, 2, start[2], RapidSpeedTag)
+	}
+	depth := j
+	for n := 1; depth < k && n < maxLoopIterations; n++ {
+		pass(depth)
+		depth = j * float32(math.Pow(float64(n+1), 1/float64(degression)))
+	}
+	for i := 0; i <= springPasses; i++ {
+		pass(k)
+	}
+}
+
 func absoluteDistanceMode(pos mgl32.Vec3, move Move) mgl32.Vec3 {
 	if val, ok := move["X"]; ok {
 		pos[0] = val
@@ -397,18 +882,25 @@ func incrementalDistanceMode(pos mgl32.Vec3, move Move) mgl32.Vec3 {
 
 type SimFragment struct {
 	Vertices []mgl32.Vec3
+	// simulated time the tool reaches every vertex at, in seconds
+	Times    []float32
 	SpeedTag SpeedTagType
 }
 
+// SimMap is the simulated path of a line, a polyline. The consecutive
+// fragments of a line share one entry
 type SimMap struct {
 	Segments []mgl32.Vec3
 	LineNo   int
 }
 
 type Accumulator struct {
+	// the point the rotary axes turn about, in machine coordinates
+	RotaryCenter mgl32.Vec3
 	Simulation   []*SimFragment
 	currSpeedTag SpeedTagType
 	CurrPath     []mgl32.Vec3
+	CurrTimes    []float32
 	SegmentMap   []SimMap
 	handler      func(*SimFragment)
 }
@@ -417,6 +909,7 @@ func NewAccumulator() *Accumulator {
 	return &Accumulator{
 		Simulation: make([]*SimFragment, 0),
 		CurrPath:   make([]mgl32.Vec3, 0),
+		CurrTimes:  make([]float32, 0),
 	}
 }
 
@@ -424,103 +917,312 @@ func (a *Accumulator) Close() {
 	if len(a.CurrPath) > 0 {
 		tmp := &SimFragment{
 			Vertices: make([]mgl32.Vec3, len(a.CurrPath)),
+			Times:    make([]float32, len(a.CurrTimes)),
 			SpeedTag: a.currSpeedTag,
 		}
-		for i := range a.CurrPath {
-			tmp.Vertices[i] = a.CurrPath[i]
+		copy(tmp.Vertices, a.CurrPath)
+		copy(tmp.Times, a.CurrTimes)
+		// a streaming consumer owns the vertices
+		if a.handler == nil {
+			a.Simulation = append(a.Simulation, tmp)
 		}
-		a.Simulation = append(a.Simulation, tmp)
 		lastPoint := a.CurrPath[len(a.CurrPath)-1]
+		lastTime := a.CurrTimes[len(a.CurrTimes)-1]
 		a.CurrPath = []mgl32.Vec3{lastPoint}
+		a.CurrTimes = []float32{lastTime}
 		if a.handler != nil {
 			a.handler(tmp)
 		}
 	}
 }
 
-func (a *Accumulator) Accumulate(p mgl32.Vec3, speedTag SpeedTagType) {
+func (a *Accumulator) Accumulate(p mgl32.Vec3, t float32, speedTag SpeedTagType) {
 	if a.currSpeedTag != speedTag || len(a.CurrPath) >= 10000 {
 		a.Close()
 		a.currSpeedTag = speedTag
 	}
 	a.CurrPath = append(a.CurrPath, p)
+	a.CurrTimes = append(a.CurrTimes, t)
 }
 
 func (a *Accumulator) Empty() bool {
 	return len(a.Simulation) == 0 && len(a.CurrPath) == 0
 }
 
+// FragmentListener adds the points of a fragment. The times are only known
+// once the speed of the fragment is planned and simulated
 func (a *Accumulator) FragmentListener(f *Fragment) {
 	if a.Empty() {
-		a.Accumulate(f.from, f.speedTag)
+		a.Accumulate(f.partPointAtRatio(0, a.RotaryCenter), f.startTime, f.speedTag)
 	}
-	if f.tp == LineFragmentType {
-		tmp := SimMap{
-			Segments: []mgl32.Vec3{f.from, f.to},
-			LineNo:   f.lineNo,
-		}
-		a.SegmentMap = append(a.SegmentMap, tmp)
-		a.Accumulate(f.to, f.speedTag)
+	rotarySteps := f.rotarySteps()
+	if f.tp == LineFragmentType && rotarySteps == 0 {
+		to := f.partPointAtRatio(1, a.RotaryCenter)
+		a.addSegments(f.lineNo, []mgl32.Vec3{f.partPointAtRatio(0, a.RotaryCenter), to})
+		a.Accumulate(to, f.startTime+f.duration, f.speedTag)
 	} else {
-		tolerance := float32(0.0001)
-		steps := mgl32.Ceil(
-			math.Pi /
-				mgl32.Acos(1-tolerance/f.radius) * mgl32.Abs(f.angularDistance) /
-				(math.Pi * 2),
-		)
-		tmp := SimMap{
-			Segments: []mgl32.Vec3{},
-			LineNo:   f.lineNo,
+		steps := float32(rotarySteps)
+		if f.tp == ArcFragmentType {
+			tolerance := float32(0.0001)
+			steps = mgl32.Max(steps, mgl32.Ceil(
+				math.Pi/
+					mgl32.Acos(1-tolerance/f.radius)*mgl32.Abs(f.angularDistance)/
+					(math.Pi*2),
+			))
 		}
 		points := []mgl32.Vec3{}
 		for j := 0; j <= int(steps); j++ {
-			point := COMPONENT_TYPES[ArcFragmentType].PointAtRatio(f, float32(j)/steps)
+			ratio := float32(j) / steps
+			point := f.partPointAtRatio(ratio, a.RotaryCenter)
 			points = append(points, point)
-			a.Accumulate(point, f.speedTag)
+			_, t := dataForRatio(f, ratio)
+			a.Accumulate(point, f.startTime+t, f.speedTag)
+		}
+		a.addSegments(f.lineNo, points)
+	}
+	if f.dwell > 0 {
+		// the tool stays at the end of the fragment
+		a.Accumulate(a.CurrPath[len(a.CurrPath)-1], f.startTime+f.duration+f.dwell, f.speedTag)
+	}
+}
+
+// addSegments adds the path of a fragment to the segment map, to the entry
+// of its line if the path goes on from it
+func (a *Accumulator) addSegments(lineNo int, points []mgl32.Vec3) {
+	if n := len(a.SegmentMap); n > 0 {
+		last := &a.SegmentMap[n-1]
+		if last.LineNo == lineNo && last.Segments[len(last.Segments)-1] == points[0] {
+			last.Segments = append(last.Segments, points[1:]...)
+			return
 		}
-		tmp.Segments = append(tmp.Segments, points...)
-		a.SegmentMap = append(a.SegmentMap, tmp)
 	}
+	a.SegmentMap = append(a.SegmentMap, SimMap{Segments: points, LineNo: lineNo})
+}
+
+func (ms *MachineState) executeLine(line parser.LineAst) {
+	ms.lineNo = line.LineNo
+	ast, err := ms.params.resolveLine(line.Ast)
+	if err != nil {
+		ms.report(parser.SeverityError, CodeExpression, "%v", err)
+		return
+	}
+	b, err := newBlock(ast)
+	if err != nil {
+		ms.report(parser.SeverityError, CodeModalGroup, "%v", err)
+		return
+	}
+	ms.executeBlock(b)
 }
 
-func Evaluate(in string, feedRate float32, travelFeedRate float32, initialPosition mgl32.Vec3) ([]*Fragment, *Accumulator) {
+// executeBlock runs a block in the RS274NGC order of execution
+func (ms *MachineState) executeBlock(b *block) {
+	for _, code := range b.unknown {
+		ms.report(parser.SeverityWarning, CodeUnsupported, "G%s is not simulated", code)
+	}
+	// the units of a block apply to its own words
+	if code, ok := b.g[GroupUnits]; ok {
+		ms.transition(code, nil)
+	}
+	words := ms.toMachineUnits(b.words, b)
+	wordsMap, _ := mapify(words)
+	if f, ok := wordsMap["F"]; ok {
+		ms.feedRate = f
+	}
+	if t, ok := wordsMap["T"]; ok {
+		ms.preparedTool = int(t)
+	}
+	if b.hasM(GroupToolChange, 6) {
+		ms.tool = ms.preparedTool
+	}
+	if q, ok := wordsMap["Q"]; ok && b.hasM(GroupToolChange, 61) {
+		ms.tool = int(q)
+		ms.preparedTool = ms.tool
+	}
+	if b.g[GroupNonModal] == "4" {
+		ms.transition("4", wordsMap)
+	}
+	for _, group := range G_EXECUTION_ORDER {
+		code, ok := b.g[group]
+		if !ok || code == "4" {
+			continue
+		}
+		ms.transition(code, wordsMap)
+		if code == "43.1" {
+			// the axis words of G43.1 are the offset, not a move
+			words = withoutWords(words, "Z")
+		}
+	}
+	if ms.machineCoordinates && !ms.checkG53() {
+		ms.machineCoordinates = false
+		return
+	}
+	if !b.usesAxisWords() {
+		ms.motionMode(words, ms)
+	}
+	ms.machineCoordinates = false
+	if b.hasM(GroupStopping, 2) || b.hasM(GroupStopping, 30) {
+		ms.programEnd = true
+	}
+}
+
+// transition applies the GROUPS_TRANSITIONS entry of a G code
+func (ms *MachineState) transition(code string, words map[string]float32) {
+	rawTrans, ok := GROUPS_TRANSITIONS[code]
+	if !ok {
+		ms.report(parser.SeverityWarning, CodeUnsupported, "G%s is not simulated", code)
+		if G_MODAL_GROUPS[code] == GroupMotion {
+			// its moves are not drawn as moves of the previous mode
+			ms.modes[GroupMotion] = code
+			ms.cycleWords = make(map[string]float32)
+			ms.motionMode = moveG80
+		}
+		return
+	}
+	if group := G_MODAL_GROUPS[code]; group != GroupNonModal {
+		if group == GroupMotion && ms.modes[group] != code {
+			// the sticky words of a canned cycle do not carry to another
+			// cycle, R must be given again
+			ms.cycleWords = make(map[string]float32)
+		}
+		ms.modes[group] = code
+	}
+	trans, ok := rawTrans.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range trans {
+		switch k {
+		case "motionMode":
+			ms.motionMode = v.(func([]parser.Expr, *MachineState))
+		case "currentOrigin":
+			ms.selectOrigin(v.(int))
+		case "planeMode":
+			ms.planeMode = v.(Plane)
+		case "pathControl":
+			ms.pathControl = v.(string)
+			ms.blendTolerance = words["P"]
+		case "distanceMode":
+			ms.distanceMode = v.(func(mgl32.Vec3, Move) mgl32.Vec3)
+		case "retractMode":
+			ms.retractMode = v.(string)
+		case "compensation":
+			ms.setCompensation(v.(string), words)
+		case "toolLengthOffset":
+			ms.setToolLengthOffset(v.(string), words)
+		case "units":
+			ms.programUnits = v.(Units)
+		case "dwell":
+			ms.dwell(words)
+		case "offsets":
+			ms.setOffsets(v.(string), words)
+		case "machineCoordinates":
+			ms.machineCoordinates = true
+		}
+	}
+}
+
+func (ms *MachineState) dwell(words map[string]float32) {
+	if p, ok := words["P"]; ok && p > 0 && ms.lastFragment != nil {
+		ms.lastFragment.dwell += p
+	}
+}
+
+func (ms *MachineState) setToolLengthOffset(mode string, words map[string]float32) {
+	switch mode {
+	case "49":
+		ms.toolLengthOffset = 0
+	case "43.1":
+		ms.toolLengthOffset = words["Z"]
+	default:
+		toolNo := ms.tool
+		if h, ok := words["H"]; ok {
+			toolNo = int(h)
+		}
+		tool, ok := ms.tools[toolNo]
+		if !ok && toolNo != 0 {
+			ms.report(parser.SeverityWarning, CodeTool, "tool %d is not in the tool table, no length offset", toolNo)
+		}
+		ms.toolLengthOffset = tool.Length
+	}
+}
+
+func (ms *MachineState) setCompensation(mode string, words map[string]float32) {
+	side := CompensationLeft
+	switch mode {
+	case "40":
+		ms.compensation.Stop()
+		return
+	case "42", "42.1":
+		side = CompensationRight
+	}
+	var radius float32
+	d, hasD := words["D"]
+	if mode == "41.1" || mode == "42.1" {
+		// dynamic compensation, D is the tool diameter
+		radius = d / 2
+	} else {
+		toolNo := ms.tool
+		if hasD {
+			toolNo = int(d)
+		}
+		tool, ok := ms.tools[toolNo]
+		if !ok && toolNo != 0 {
+			ms.report(parser.SeverityWarning, CodeTool, "tool %d is not in the tool table, no radius compensation", toolNo)
+		}
+		radius = tool.Diameter / 2
+	}
+	ms.compensation.Start(side, radius, ms.planeMode)
+}
+
+func Evaluate(in string, feedRate float32, travelFeedRate float32, initialPosition mgl32.Vec3, tools ToolTable, units Units, offsets *WorkOffsets, rotaryCenter mgl32.Vec3) ([]*Fragment, *Accumulator, Units, []parser.Diagnostic) {
+	ms := newEvaluationState(feedRate, travelFeedRate, initialPosition, tools, units, offsets, rotaryCenter)
+	diagnostics := ms.evaluate(in)
+	return ms.path, ms.accumulator, ms.programUnits, diagnostics
+}
+
+// evaluate runs a whole program
+func (ms *MachineState) evaluate(in string) []parser.Diagnostic {
+	parsed, diagnostics := parser.ParseAll(in)
+	prog, errs := newProgram(parsed)
+	for _, err := range errs {
+		ms.reportControlError(err)
+	}
+	ms.reportControlError(prog.run(ms))
+	return ms.finish(diagnostics)
+}
+
+func newEvaluationState(feedRate float32, travelFeedRate float32, initialPosition mgl32.Vec3, tools ToolTable, units Units, offsets *WorkOffsets, rotaryCenter mgl32.Vec3) *MachineState {
 	ms := NewMachineState(feedRate, travelFeedRate, initialPosition)
-	parsed, _ := parser.ParseAll(in)
-	for _, line := range parsed {
-		lineNo := line.LineNo
-		ms.lineNo = lineNo
-		ast := line.Ast
-		astMap, astMapString := mapify(ast)
-		if f, ok := astMap["F"]; ok {
-			ms.feedRate = f
-		}
-		if g, ok := astMapString["G"]; ok {
-			if rawTrans, ok := GROUPS_TRANSITIONS[g]; ok && rawTrans != nil {
-				switch trans := rawTrans.(type) {
-				case string:
-					// unsupported
-				case map[string]interface{}:
-					for k, v := range trans {
-						switch k {
-						case "motionMode":
-							ms.motionMode = v.(func([]parser.Expr, *MachineState))
-						case "currentOrigin":
-							ms.currentOrigin = v.(int)
-						case "planeMode":
-							ms.planeMode = v.(Plane)
-						case "pathControl":
-							ms.pathControl = v.(string)
-						case "distanceMode":
-							ms.distanceMode = v.(func(mgl32.Vec3, Move) mgl32.Vec3)
-
-						}
-					}
-				}
-			}
+	if tools != nil {
+		ms.tools = tools
+	}
+	if offsets != nil {
+		ms.seedOffsets(offsets)
+	}
+	ms.accumulator.RotaryCenter = rotaryCenter
+	ms.machineUnits = units
+	ms.programUnits = units
+	return ms
+}
+
+func (ms *MachineState) reportControlError(err error) {
+	if ce, ok := err.(*controlError); ok {
+		ms.reportAt(ce.lineNo, parser.SeverityError, CodeControlFlow, "%s", ce.msg)
+	}
+}
+
+// finish ends the program: the last compensated move is flushed and the
+// diagnostics of the parser and of the simulation are merged
+func (ms *MachineState) finish(diagnostics []parser.Diagnostic) []parser.Diagnostic {
+	ms.compensation.Stop()
+	for _, err := range ms.compensation.Errors {
+		if ge, ok := err.(*GougeError); ok {
+			ms.reportAt(ge.LineNo, parser.SeverityError, CodeCompensation, "%s", ge.Msg)
 		}
-		ms.motionMode(line.Ast, ms)
 	}
-	return ms.path, ms.accumulator
+	diagnostics = append(diagnostics, ms.diagnostics...)
+	sortDiagnostics(diagnostics)
+	return diagnostics
 }
 
 func Simulate(in string) {
@@ -528,7 +1230,7 @@ func Simulate(in string) {
 
 type ComponentType interface {
 	Length(f *Fragment) float32
-	Speed(f *Fragment, accel float32) (float32, float32)
+	Speed(f *Fragment, limits *MachineLimits) (float32, float32)
 	EntryDir(f *Fragment) mgl32.Vec3
 	ExitDir(f *Fragment) mgl32.Vec3
 	PointAtRatio(f *Fragment, ratio float32) mgl32.Vec3
@@ -538,17 +1240,35 @@ type ComponentType interface {
 type LineComponent struct {
 }
 
+// Length is the distance the feed rate applies to: X, Y, Z if they move,
+// else U, V, W, else the degrees of A, B, C
 func (l *LineComponent) Length(f *Fragment) float32 {
-	return f.to.Sub(f.from).Len()
+	if length := f.to.Sub(f.from).Len(); length > 0 {
+		return length
+	}
+	if length := f.toExtra.Linear().Sub(f.fromExtra.Linear()).Len(); length > 0 {
+		return length
+	}
+	return f.toExtra.Rotary().Sub(f.fromExtra.Rotary()).Len()
 }
-func (l *LineComponent) Speed(f *Fragment, accel float32) (float32, float32) {
-	return f.feedRate / 60.0, accel
+func (l *LineComponent) Speed(f *Fragment, limits *MachineLimits) (float32, float32) {
+	vel, accel := limits.pathLimits(f)
+	return lowerLimit(limits.feedSpeed(f), vel), accel
 }
 func (l *LineComponent) EntryDir(f *Fragment) mgl32.Vec3 {
-	return f.to.Sub(f.from).Normalize()
+	return lineDir(f)
 }
 func (l *LineComponent) ExitDir(f *Fragment) mgl32.Vec3 {
-	return f.to.Sub(f.from).Normalize()
+	return lineDir(f)
+}
+
+// lineDir is the direction of a straight move, 0 if only the extra axes move
+func lineDir(f *Fragment) mgl32.Vec3 {
+	d := f.to.Sub(f.from)
+	if d.Len() == 0 {
+		return d
+	}
+	return d.Normalize()
 }
 func (l *LineComponent) PointAtRatio(f *Fragment, ratio float32) mgl32.Vec3 {
 	return f.from.Add(f.to.Sub(f.from).Mul(ratio))
@@ -569,9 +1289,10 @@ func (l *ArcComponent) Length(f *Fragment) float32 {
 	)
 }
 
-func (l *ArcComponent) Speed(f *Fragment, accel float32) (float32, float32) {
+func (l *ArcComponent) Speed(f *Fragment, limits *MachineLimits) (float32, float32) {
 	radius := f.radius
-	speed := f.feedRate / 60.0
+	vel, accel := limits.pathLimits(f)
+	speed := lowerLimit(limits.feedSpeed(f), vel)
 	maxRadialAccel := mgl32.Pow(speed, 2) / radius
 	reductionFactor := float32(0.8)
 	if maxRadialAccel > accel*reductionFactor {
@@ -583,9 +1304,9 @@ func (l *ArcComponent) Speed(f *Fragment, accel float32) (float32, float32) {
 	return speed, maxTangentialAccel
 }
 
+// getArcSpeedDir is the direction of the arc at angle from its start
 func getArcSpeedDir(f *Fragment, angle float32) mgl32.Vec3 {
 	p := f.plane
-	angle = 0
 	rx := mgl32.Cos(f.fromAngle + angle)
 	ry := mgl32.Sin(f.fromAngle + angle)
 	dz := (f.to[p.lastCoord] - f.from[p.lastCoord]) / mgl32.Abs(f.angularDistance) / f.radius
@@ -596,7 +1317,11 @@ func getArcSpeedDir(f *Fragment, angle float32) mgl32.Vec3 {
 	} else {
 		dir = -1.0
 	}
-	return mgl32.Vec3{-dir * ry / lgt, dir * rx / lgt, dz / lgt}
+	out := mgl32.Vec3{}
+	out[p.firstCoord] = -dir * ry / lgt
+	out[p.secondCoord] = dir * rx / lgt
+	out[p.lastCoord] = dz / lgt
+	return out
 }
 
 func (l *ArcComponent) EntryDir(f *Fragment) mgl32.Vec3 {
@@ -623,7 +1348,11 @@ func (l *ArcComponent) PointAtRatio(f *Fragment, ratio float32) mgl32.Vec3 {
 }
 
 func (l *ArcComponent) SimSteps(f *Fragment) int {
-	return int(mgl32.Round(mgl32.Abs(f.angularDistance)/(2*math.Pi)*50, 3))
+	steps := int(mgl32.Round(mgl32.Abs(f.angularDistance)/(2*math.Pi)*50, 3))
+	if steps < 1 {
+		return 1
+	}
+	return steps
 }
 
 var COMPONENT_TYPES = map[FragmentType]ComponentType{
@@ -631,31 +1360,23 @@ var COMPONENT_TYPES = map[FragmentType]ComponentType{
 	ArcFragmentType:  &ArcComponent{},
 }
 
-func groupConnectedComponents(path []*Fragment, accel float32) [][]*Fragment {
-	groups := make([][]*Fragment, 0)
-	lastExitDir := mgl32.Vec3{0, 0, 0}
-	var currentGroup []*Fragment
-	for _, component := range path {
-		trait := COMPONENT_TYPES[component.tp]
-		// are mostly continuous
-		cutOff := float32(1.95)
-		if lastExitDir.Add(trait.EntryDir(component)).LenSqr() >= cutOff*cutOff {
-			currentGroup = append(currentGroup, component)
-		} else {
-			currentGroup := []*Fragment{component}
-			groups = append(groups, currentGroup)
-		}
-		lastExitDir = trait.ExitDir(component)
-		sp, acc := trait.Speed(component, accel)
-		component.length = trait.Length(component)
-		component.squaredSpeed, component.maxAccel = mgl32.Pow(sp, 2), acc
-	}
-	return groups
-}
-
 type SimulateStats struct {
 	TotalTime float32
 	Bbox      *glutil.BoundingBox
+	// units of the simulated coordinates and feeds
+	Units Units
+	// program units active at the end of the program
+	ProgramUnits Units
+	// when the lines start running, in the order they run
+	Lines []LineTime
+}
+
+// LineTime is the time in seconds a line of the program starts running at,
+// and the tool in the spindle while it runs
+type LineTime struct {
+	LineNo int
+	Tool   int
+	Time   float32
 }
 
 func (s *SimulateStats) Push(x, y, z, t float32) {
@@ -663,19 +1384,58 @@ func (s *SimulateStats) Push(x, y, z, t float32) {
 	s.Bbox.PushCoord(x, y, z)
 }
 
+func (s *SimulateStats) startLine(lineNo, tool int, t float32) {
+	if n := len(s.Lines); n > 0 && s.Lines[n-1].LineNo == lineNo && s.Lines[n-1].Tool == tool {
+		return
+	}
+	s.Lines = append(s.Lines, LineTime{LineNo: lineNo, Tool: tool, Time: t})
+}
+
+// lineTimeAt is the index in Lines of the line running at time t, -1 before
+// the first move
+func (s *SimulateStats) lineTimeAt(t float32) int {
+	return sort.Search(len(s.Lines), func(i int) bool {
+		return s.Lines[i].Time > t
+	}) - 1
+}
+
+// LineAt is the line running at time t, -1 before the first move
+func (s *SimulateStats) LineAt(t float32) int {
+	i := s.lineTimeAt(t)
+	if i < 0 {
+		return -1
+	}
+	return s.Lines[i].LineNo
+}
+
+// ToolAt is the tool in the spindle at time t, 0 if none
+func (s *SimulateStats) ToolAt(t float32) int {
+	i := s.lineTimeAt(t)
+	if i < 0 {
+		return 0
+	}
+	return s.Lines[i].Tool
+}
+
 type Simulation struct {
 	lastPos     mgl32.Vec3
 	CurrentTime float32
-	stats       SimulateStats
+	Tools       ToolTable
+	Units       Units
+	Offsets     *WorkOffsets
+	Limits      *MachineLimits
+	// the point the rotary axes turn about, in machine coordinates
+	RotaryCenter mgl32.Vec3
+	stats        SimulateStats
 }
 
 var FRAGMENT_EQUATIONS map[SpeedType](func(*RunFragment, float32, float32) (float32, float32)) = map[SpeedType]func(*RunFragment, float32, float32) (float32, float32){
 	SpeedAccel: func(rf *RunFragment, ratio float32, accel float32) (float32, float32) {
-		x2 := 2*rf.fragment.RunFragments[SpeedAccel].length + rf.length*ratio
+		x2 := 2 * (rf.fragment.RunData[SpeedAccel].length + rf.length*ratio)
 		return mgl32.Sqrt(accel * x2), mgl32.Sqrt(x2/accel) - mgl32.Sqrt(rf.fromSqSpeed)/accel
 	},
 	SpeedDecel: func(rf *RunFragment, ratio float32, accel float32) (float32, float32) {
-		x2 := 2 * (rf.fragment.RunFragments[SpeedDecel].length + rf.length*(1-ratio))
+		x2 := 2 * (rf.fragment.RunData[SpeedDecel].length + rf.length*(1-ratio))
 		return mgl32.Sqrt(accel * x2), mgl32.Sqrt(rf.toSqSpeed)/accel + rf.duration - mgl32.Sqrt(x2/accel)
 	},
 	SpeedConst: func(rf *RunFragment, ratio float32, accel float32) (float32, float32) {
@@ -688,9 +1448,12 @@ func dataForRatio(f *Fragment, ratio float32) (float32, float32) {
 	x := f.length * ratio
 	timeOffset := float32(0.0)
 	xOffset := float32(0.0)
+	if len(f.RunFragments) == 0 {
+		return 0, 0
+	}
 	fragmentIndex := 0
 	fragment := f.RunFragments[fragmentIndex]
-	for fragment.stopX < x {
+	for fragment.stopX < x && fragmentIndex < len(f.RunFragments)-1 {
 		timeOffset += fragment.duration
 		xOffset += fragment.length
 		fragmentIndex++
@@ -704,12 +1467,15 @@ func (s *Simulation) Discretize(f *Fragment) {
 	trait := COMPONENT_TYPES[f.tp]
 	steps := trait.SimSteps(f)
 	startTime := s.CurrentTime
+	f.startTime = startTime
+	s.stats.startLine(f.lineNo, f.tool, startTime)
 	for j := 1; j <= steps; j++ {
-		ratio := float32(j / steps)
+		ratio := float32(j) / float32(steps)
 		_, time := dataForRatio(f, ratio)
 		s.CurrentTime = startTime + time
-		s.Push(trait.PointAtRatio(f, ratio), f)
+		s.Push(f.partPointAtRatio(ratio, s.RotaryCenter), f)
 	}
+	s.CurrentTime += f.dwell
 }
 
 func (s *Simulation) Push(p mgl32.Vec3, f *Fragment) {
@@ -717,175 +1483,209 @@ func (s *Simulation) Push(p mgl32.Vec3, f *Fragment) {
 	s.stats.Push(p[0], p[1], p[2], s.CurrentTime)
 }
 
-func reverse(s interface{}) {
-	n := reflect.ValueOf(s).Len()
-	swap := reflect.Swapper(s)
-	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
-		swap(i, j)
+// planSpeed plans the speed along a group of fragments the machine runs
+// without stopping. The group is entered at the squared speed start and
+// ends at rest, every fragment is entered at most at its blend speed and the
+// speed changes at most at the acceleration of the fragment: a fragment
+// accelerates, runs at its speed and decelerates
+func planSpeed(group []*Fragment, start float32) {
+	entry := plannedEntries(group, start)
+	for i, fragment := range group {
+		planFragment(fragment, entry[i], entry[i+1])
 	}
 }
 
-func limitSpeed(fragments []*Fragment, direction SpeedType) {
-	for i := 0; i < len(fragments); i++ {
-		fragment := fragments[i]
-		acceleration := fragment.maxAccel
-		previousSquaredSpeed := float32(0.0)
-		if i > 0 {
-			previousSquaredSpeed = fragments[i-1].squaredSpeed
-		}
-		accelerationLength := previousSquaredSpeed / (2 * acceleration)
-		maxSquaredSpeed := 2 * acceleration * (accelerationLength + fragment.length)
-		fragment.squaredSpeed = mgl32.Min(fragment.squaredSpeed, maxSquaredSpeed)
-		fragment.RunData[direction] = SpeedData{
-			length: previousSquaredSpeed / (2 * acceleration),
-		}
+// plannedEntries are the squared speeds at the start of every fragment of a
+// group and at its end, where the machine stops. The group is entered at
+// start
+func plannedEntries(group []*Fragment, start float32) []float32 {
+	entry := make([]float32, len(group)+1)
+	entry[0] = start
+	for i := 1; i < len(group); i++ {
+		entry[i] = mgl32.Min(group[i].blendSqSpeed, mgl32.Min(group[i-1].squaredSpeed, group[i].squaredSpeed))
+	}
+	for i := len(group) - 1; i >= 0; i-- {
+		entry[i] = mgl32.Min(entry[i], entry[i+1]+2*group[i].maxAccel*group[i].length)
+	}
+	for i := 0; i < len(group); i++ {
+		entry[i+1] = mgl32.Min(entry[i+1], entry[i]+2*group[i].maxAccel*group[i].length)
 	}
+	return entry
 }
 
-func planSpeed(group []*Fragment) {
-	limitSpeed(group, SpeedAccel)
-	reverse(group)
-	limitSpeed(group, SpeedDecel)
-	reverse(group)
-	for i := 0; i < len(group); i++ {
-		var nextSquaredSpeed float32
-		if i < len(group)-1 {
-			nextSquaredSpeed = group[i+1].squaredSpeed
-		} else {
-			nextSquaredSpeed = 0
-		}
-		var previousSquaredSpeed float32
-		if i >= 1 {
-			previousSquaredSpeed = group[i-1].squaredSpeed
-		} else {
-			previousSquaredSpeed = 0
-		}
-		fragment := group[i]
-		fragment.RunFragments = make([]*RunFragment, 0)
-		acceleration := fragment.maxAccel
-		accelerationLength := fragment.RunData[SpeedAccel].length
-		decelerationLength := fragment.RunData[SpeedDecel].length
-		meetingPoint := (decelerationLength + fragment.length - accelerationLength) / 2
-		meetingSquaredSpeed := 2 * acceleration * (accelerationLength + meetingPoint)
-		endAccelerationPoint := (fragment.squaredSpeed - 2*acceleration*accelerationLength) / (2 * acceleration)
-		startDecelerationPoint := (2*acceleration*(decelerationLength+fragment.length) - fragment.squaredSpeed) / (2 * acceleration)
-		maxSquaredSpeed := fragment.squaredSpeed
-		if meetingPoint >= 0 && meetingPoint <= fragment.length && meetingSquaredSpeed <= fragment.squaredSpeed {
-			maxSquaredSpeed = meetingSquaredSpeed
-			endAccelerationPoint = meetingPoint
-			startDecelerationPoint = meetingPoint
-			fragment.squaredSpeed = meetingSquaredSpeed
-		}
-		hasAcceleration := endAccelerationPoint > 0 && endAccelerationPoint <= fragment.length
-		hasDeceleration := startDecelerationPoint >= 0 && startDecelerationPoint < fragment.length
-		if hasAcceleration {
-			runFragment := &RunFragment{
-				tp:          SpeedAccel,
-				fragment:    fragment,
-				fromSqSpeed: previousSquaredSpeed,
-				toSqSpeed:   maxSquaredSpeed,
-				startX:      0,
-				stopX:       endAccelerationPoint,
-			}
-			fragment.RunFragments = append(fragment.RunFragments, runFragment)
-		}
-		var constantSpeedStart float32
-		if hasAcceleration {
-			constantSpeedStart = endAccelerationPoint
-		} else {
-			constantSpeedStart = 0
+// planFragment plans the speed along a fragment entered and left at the
+// given squared speeds
+func planFragment(fragment *Fragment, previousSquaredSpeed, nextSquaredSpeed float32) {
+	fragment.RunFragments = make([]*RunFragment, 0)
+	acceleration := fragment.maxAccel
+	if fragment.length == 0 {
+		fragment.duration = 0
+		return
+	}
+	// distances to accelerate from rest to the entry speed and to
+	// decelerate from the exit speed to rest
+	accelerationLength := previousSquaredSpeed / (2 * acceleration)
+	decelerationLength := nextSquaredSpeed / (2 * acceleration)
+	fragment.RunData[SpeedAccel] = SpeedData{length: accelerationLength}
+	fragment.RunData[SpeedDecel] = SpeedData{length: decelerationLength}
+	meetingPoint := mgl32.Clamp((decelerationLength+fragment.length-accelerationLength)/2, 0, fragment.length)
+	meetingSquaredSpeed := 2 * acceleration * (accelerationLength + meetingPoint)
+	endAccelerationPoint := (fragment.squaredSpeed - 2*acceleration*accelerationLength) / (2 * acceleration)
+	startDecelerationPoint := (2*acceleration*(decelerationLength+fragment.length) - fragment.squaredSpeed) / (2 * acceleration)
+	maxSquaredSpeed := fragment.squaredSpeed
+	if meetingSquaredSpeed <= fragment.squaredSpeed {
+		maxSquaredSpeed = meetingSquaredSpeed
+		endAccelerationPoint = meetingPoint
+		startDecelerationPoint = meetingPoint
+		fragment.squaredSpeed = meetingSquaredSpeed
+	}
+	hasAcceleration := endAccelerationPoint > 0 && endAccelerationPoint <= fragment.length
+	hasDeceleration := startDecelerationPoint >= 0 && startDecelerationPoint < fragment.length
+	if hasAcceleration {
+		runFragment := &RunFragment{
+			tp:          SpeedAccel,
+			fragment:    fragment,
+			fromSqSpeed: previousSquaredSpeed,
+			toSqSpeed:   maxSquaredSpeed,
+			startX:      0,
+			stopX:       endAccelerationPoint,
 		}
-		var constantSpeedStop float32
-		if hasDeceleration {
-			constantSpeedStop = startDecelerationPoint
+		fragment.RunFragments = append(fragment.RunFragments, runFragment)
+	}
+	var constantSpeedStart float32
+	if hasAcceleration {
+		constantSpeedStart = endAccelerationPoint
+	} else {
+		constantSpeedStart = 0
+	}
+	var constantSpeedStop float32
+	if hasDeceleration {
+		constantSpeedStop = startDecelerationPoint
+	} else {
+		constantSpeedStop = fragment.length
+	}
+	if constantSpeedStart != constantSpeedStop {
+		fragment.RunFragments = append(fragment.RunFragments, &RunFragment{
+			tp:           SpeedConst,
+			fragment:     fragment,
+			squaredSpeed: maxSquaredSpeed,
+			startX:       constantSpeedStart,
+			stopX:        constantSpeedStop,
+		})
+	}
+	if hasDeceleration {
+		fragment.RunFragments = append(fragment.RunFragments, &RunFragment{
+			tp:          SpeedDecel,
+			fragment:    fragment,
+			fromSqSpeed: maxSquaredSpeed,
+			toSqSpeed:   nextSquaredSpeed,
+			startX:      startDecelerationPoint,
+			stopX:       fragment.length,
+		})
+	}
+	fragment.duration = 0.0
+	for _, rf := range fragment.RunFragments {
+		rf.length = rf.stopX - rf.startX
+		if rf.tp == SpeedConst {
+			rf.duration = rf.length / mgl32.Sqrt(rf.squaredSpeed)
 		} else {
-			constantSpeedStop = fragment.length
-		}
-		if constantSpeedStart != constantSpeedStop {
-			fragment.RunFragments = append(fragment.RunFragments, &RunFragment{
-				tp:           SpeedConst,
-				fragment:     fragment,
-				squaredSpeed: maxSquaredSpeed,
-				startX:       constantSpeedStart,
-				stopX:        constantSpeedStop,
-			})
-		}
-		if hasDeceleration {
-			fragment.RunFragments = append(fragment.RunFragments, &RunFragment{
-				tp:          SpeedDecel,
-				fragment:    fragment,
-				fromSqSpeed: maxSquaredSpeed,
-				toSqSpeed:   nextSquaredSpeed,
-				startX:      startDecelerationPoint,
-				stopX:       fragment.length,
-			})
-		}
-		fragment.duration = 0.0
-		for _, rf := range fragment.RunFragments {
-			rf.length = rf.stopX - rf.startX
-			if rf.tp == SpeedConst {
-				rf.duration = fragment.length / mgl32.Sqrt(fragment.squaredSpeed)
-			} else {
-				rf.duration = mgl32.Abs(
-					mgl32.Sqrt(rf.fromSqSpeed)-mgl32.Sqrt(rf.toSqSpeed),
-				) / acceleration
-			}
-			fragment.duration += rf.duration
+			rf.duration = mgl32.Abs(
+				mgl32.Sqrt(rf.fromSqSpeed)-mgl32.Sqrt(rf.toSqSpeed),
+			) / acceleration
 		}
+		fragment.duration += rf.duration
 	}
 }
 
-func (s *Simulation) Simulate(in string, feedRate float32, travelFeedRate float32, pos mgl32.Vec3) ([][]*Fragment, *Accumulator, SimulateStats) {
+func (s *Simulation) Simulate(in string, feedRate float32, travelFeedRate float32, pos mgl32.Vec3) ([][]*Fragment, *Accumulator, SimulateStats, []parser.Diagnostic) {
 	s.stats = SimulateStats{
 		TotalTime: 0.0,
 		Bbox:      glutil.NewBoundingBox(),
+		Units:     s.Units,
 	}
 
-	toolPath, accumulator := Evaluate(in, feedRate, travelFeedRate, pos)
-	accumulator.Close()
+	ms := newEvaluationState(feedRate, travelFeedRate, pos, s.Tools, s.Units, s.Offsets, s.RotaryCenter)
+	// the accumulator gets the fragments once they are simulated
+	ms.fragmentCb = nil
+	diagnostics := ms.evaluate(in)
+	toolPath, accumulator, programUnits := ms.path, ms.accumulator, ms.programUnits
+	s.stats.ProgramUnits = programUnits
 
 	if len(toolPath) > 0 {
-		accel := float32(1000.0) // mm/s^2
-		groups := groupConnectedComponents(toolPath, accel)
+		groups := newPlanner(s.limits()).plan(toolPath)
 		s.CurrentTime = 0.0
 		s.lastPos = mgl32.Vec3{0, 0, 0}
 		for _, group := range groups {
-			planSpeed(group)
-			for _, fragment := range group {
-				s.Discretize(fragment)
-			}
-			for i := 0; i < 10; i++ {
-				s.CurrentTime += 0.001
-				s.Push(s.lastPos, group[len(group)-1])
-			}
+			s.run(group, accumulator)
 		}
-		return groups, accumulator, s.stats
+		accumulator.Close()
+		return groups, accumulator, s.stats, diagnostics
 	}
-	return nil, nil, SimulateStats{}
+	return nil, nil, SimulateStats{Units: s.Units, ProgramUnits: programUnits}, diagnostics
 
 }
 
-func SimulateGCode(in string) ([][]*Fragment, *Accumulator, SimulateStats) {
+// run adds the time of planned fragments to the simulation and their points
+// to the accumulator
+func (s *Simulation) run(group []*Fragment, accumulator *Accumulator) {
+	for _, fragment := range group {
+		s.Discretize(fragment)
+		accumulator.FragmentListener(fragment)
+	}
+}
+
+func (s *Simulation) limits() *MachineLimits {
+	if s.Limits == nil {
+		return DefaultLimits(s.Units)
+	}
+	return s.Limits
+}
+
+// SimulateGCode simulates a program on a machine with the given limits, the
+// default ones if nil
+func SimulateGCode(in string, tools ToolTable, units Units, offsets *WorkOffsets, limits *MachineLimits) ([][]*Fragment, *Accumulator, SimulateStats, []parser.Diagnostic) {
 	sim := Simulation{
 		CurrentTime: 0.0,
+		Tools:       tools,
+		Units:       units,
+		Offsets:     offsets,
+		Limits:      limits,
 	}
-	groups, accumulator, stats := sim.Simulate(in, 15*60, 15*60, mgl32.Vec3{0, 0, 0})
-	return groups, accumulator, stats
+	l := sim.limits()
+	return sim.Simulate(in, l.feedRate(), l.rapidFeedRate(), mgl32.Vec3{0, 0, 0})
 }
 
 func BuildVertexData(fragments [][]*Fragment, accumulator *Accumulator, stats SimulateStats, prepare bool) ([]float32, *glutil.BoundingBox) {
 	tmp := make([]float32, 0)
 
 	for _, sf := range accumulator.Simulation {
-		for _, vertex := range sf.Vertices {
-			if prepare {
-				tmp = append(tmp, vertex[0], vertex[2], -vertex[1])
-			} else {
-				tmp = append(tmp, vertex[0], vertex[1], vertex[2])
-			}
-			tmp = append(tmp, float32(sf.SpeedTag))
-		}
-
+		tmp = AppendVertexData(tmp, sf, prepare)
 	}
 	return tmp, stats.Bbox
 }
+
+// floats of every vertex in the vertex buffer data
+const VertexFloats = 5
+
+// AppendVertexData adds the vertices of a simulated fragment to the vertex
+// buffer data: x, y, z, the speed tag and the time of every vertex. prepare
+// swaps the axes to the Y-up coordinates of the preview
+func AppendVertexData(out []float32, sf *SimFragment, prepare bool) []float32 {
+	for i, vertex := range sf.Vertices {
+		if prepare {
+			vertex = PreviewPoint(vertex)
+		}
+		out = append(out, vertex[0], vertex[1], vertex[2], float32(sf.SpeedTag), sf.Times[i])
+	}
+	return out
+}
+
+// PreviewPoint is a point in the Y-up coordinates of the preview
+func PreviewPoint(p mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{p[0], p[2], -p[1]}
+}
+
+// MachinePoint is a point of the preview back in machine coordinates
+func MachinePoint(v mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{v[0], -v[2], v[1]}
+}
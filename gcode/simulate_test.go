@@ -0,0 +1,75 @@
+package gcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func evaluate(program string) ([]*Fragment, []parser.Diagnostic) {
+	path, _, _, diagnostics := Evaluate(program, 6000, 6000, mgl32.Vec3{}, nil, UnitsMM, nil, mgl32.Vec3{})
+	return path, diagnostics
+}
+
+func hasDiagnostic(diagnostics []parser.Diagnostic, line int, severity parser.Severity) bool {
+	for _, d := range diagnostics {
+		if d.Line == line && d.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUnsupportedMotionDoesNotMove(t *testing.T) {
+	path, diagnostics := evaluate("G1 X1 F100\nG5 X10 Y10 I1 J1 P2 Q2\nX20")
+	for _, f := range path {
+		if f.to.X() > 1 {
+			t.Errorf("line %d moves to %v in an unsupported motion mode", f.lineNo, f.to)
+		}
+	}
+	if !hasDiagnostic(diagnostics, 2, parser.SeverityWarning) {
+		t.Errorf("no warning for G5: %v", diagnostics)
+	}
+}
+
+func TestBrokenControlFlow(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		line    int
+		reach   float32
+	}{
+		{"endif without if", "G1 X1 F100\nO100 endif\nX2", 2, 2},
+		{"while never closed", "G1 X1 F100\nX2\nO100 while [1]\nX3", 3, 2},
+		{"break outside of a loop", "G1 X1 F100\nO100 break\nX2", 2, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, diagnostics := evaluate(test.program)
+			if !hasDiagnostic(diagnostics, test.line, parser.SeverityError) {
+				t.Errorf("no error at line %d: %v", test.line, diagnostics)
+			}
+			if len(path) == 0 || path[len(path)-1].to.X() != test.reach {
+				t.Errorf("the program does not run up to X%v", test.reach)
+			}
+			_, _, diagnostics, err := SimulateGCodeStream(strings.NewReader(test.program), nil, UnitsMM, nil, testLimits(), mgl32.Vec3{}, nil, nil)
+			if err != nil || !hasDiagnostic(diagnostics, test.line, parser.SeverityError) {
+				t.Errorf("no error at line %d of the stream: %v %v", test.line, diagnostics, err)
+			}
+		})
+	}
+}
+
+func TestArcWithoutCenter(t *testing.T) {
+	path, diagnostics := evaluate("G1 X1 F100\nG2 X2 Y1\nG1 X3")
+	if !hasDiagnostic(diagnostics, 2, parser.SeverityError) {
+		t.Errorf("no error for the arc without a center: %v", diagnostics)
+	}
+	for _, f := range path {
+		if f.tp == ArcFragmentType {
+			t.Errorf("an arc without a center is drawn")
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package gcode
+
+import (
+	"io"
+	"math"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// cells along the longest side of the stock when the cell size is not given
+const defaultStockCells = 400
+
+// most cells along a side of the stock
+const maxStockCells = 2000
+
+// most pieces a move is cut in, to follow the shape of arcs and the slope
+// of ramps
+const maxCutPieces = 4096
+
+// Stock is a rectangular block of material, in machine coordinates, as a
+// height map: the top of the material over a grid of cells. The tool only
+// cuts from above, rotary axes are not simulated
+type Stock struct {
+	Min, Max mgl32.Vec3
+	// side of a cell
+	Cell       float32
+	Cols, Rows int
+	// top of the material at the center of every cell, row by row from Min
+	Heights []float32
+}
+
+// NewStock is an uncut block between min and max. A cell size of 0 splits
+// the longest side in defaultStockCells
+func NewStock(min, max mgl32.Vec3, cell float32) *Stock {
+	size := max.Sub(min)
+	longest := mgl32.Max(size[0], size[1])
+	if cell <= 0 {
+		cell = longest / defaultStockCells
+	}
+	cell = mgl32.Max(cell, longest/maxStockCells)
+	s := &Stock{
+		Min:  min,
+		Max:  max,
+		Cell: cell,
+		Cols: int(mgl32.Max(1, float32(math.Ceil(float64(size[0]/cell))))),
+		Rows: int(mgl32.Max(1, float32(math.Ceil(float64(size[1]/cell))))),
+	}
+	s.Heights = make([]float32, s.Cols*s.Rows)
+	s.Reset()
+	return s
+}
+
+// Reset puts back the material removed
+func (s *Stock) Reset() {
+	for i := range s.Heights {
+		s.Heights[i] = s.Max[2]
+	}
+}
+
+// cellCenter is the X, Y of the center of a cell
+func (s *Stock) cellCenter(col, row int) mgl32.Vec2 {
+	return mgl32.Vec2{
+		s.Min[0] + (float32(col)+0.5)*s.Cell,
+		s.Min[1] + (float32(row)+0.5)*s.Cell,
+	}
+}
+
+// toolHeight is how far above the tip the bottom of the tool is, at a
+// distance from its axis within its radius
+func toolHeight(tool Tool, radius float32, distance float32) float32 {
+	switch tool.Shape {
+	case ToolBall:
+		return radius - mgl32.Sqrt(mgl32.Max(0, radius*radius-distance*distance))
+	case ToolVBit:
+		angle := tool.Angle
+		if angle <= 0 || angle >= 180 {
+			angle = 90
+		}
+		return distance / float32(math.Tan(float64(mgl32.DegToRad(angle/2))))
+	}
+	return 0
+}
+
+// CutPath removes the material the tools cut along a path
+func (s *Stock) CutPath(path []*Fragment, tools ToolTable) {
+	for _, f := range path {
+		s.CutFragment(f, tools[f.tool])
+	}
+}
+
+// CutFragment removes the material tool cuts along f. A tool of unknown
+// diameter cuts the cells under its tip
+func (s *Stock) CutFragment(f *Fragment, tool Tool) {
+	trait := COMPONENT_TYPES[f.tp]
+	steps := 1
+	if f.tp == ArcFragmentType {
+		steps = int(math.Ceil(float64(trait.Length(f) / s.Cell)))
+		if simSteps := trait.SimSteps(f); simSteps > steps {
+			steps = simSteps
+		}
+		if steps > maxCutPieces {
+			steps = maxCutPieces
+		}
+	}
+	from := f.from
+	for j := 1; j <= steps; j++ {
+		to := trait.PointAtRatio(f, float32(j)/float32(steps))
+		s.Cut(from, to, tool)
+		from = to
+	}
+}
+
+// Cut removes the material tool cuts when its tip moves straight from
+// from to to
+func (s *Stock) Cut(from, to mgl32.Vec3, tool Tool) {
+	if mgl32.Min(from[2], to[2]) >= s.Max[2] {
+		return
+	}
+	radius := mgl32.Max(tool.Diameter/2, s.Cell/2)
+	// the depth is taken where the tool passes closest to a cell, the
+	// pieces are short enough in Z for that to be close to the lowest
+	pieces := int(math.Ceil(float64(mgl32.Abs(to[2]-from[2]) / (s.Cell / 4))))
+	if pieces < 1 {
+		pieces = 1
+	} else if pieces > maxCutPieces {
+		pieces = maxCutPieces
+	}
+	for j := 0; j < pieces; j++ {
+		a := from.Add(to.Sub(from).Mul(float32(j) / float32(pieces)))
+		b := from.Add(to.Sub(from).Mul(float32(j+1) / float32(pieces)))
+		s.sweep(a, b, tool, radius)
+	}
+}
+
+// sweep cuts the cells within radius of the straight move from a to b,
+// seen from above
+func (s *Stock) sweep(a, b mgl32.Vec3, tool Tool, radius float32) {
+	a2, b2 := a.Vec2(), b.Vec2()
+	d := b2.Sub(a2)
+	l2 := d.Dot(d)
+	minCol, maxCol := s.colRange(mgl32.Min(a[0], b[0])-radius, mgl32.Max(a[0], b[0])+radius)
+	minRow, maxRow := s.rowRange(mgl32.Min(a[1], b[1])-radius, mgl32.Max(a[1], b[1])+radius)
+	for row := minRow; row <= maxRow; row++ {
+		for col := minCol; col <= maxCol; col++ {
+			c := s.cellCenter(col, row)
+			var t float32
+			if l2 > 0 {
+				t = mgl32.Clamp(c.Sub(a2).Dot(d)/l2, 0, 1)
+			} else if b[2] < a[2] {
+				// plunge
+				t = 1
+			}
+			distance := c.Sub(a2.Add(d.Mul(t))).Len()
+			if distance > radius {
+				continue
+			}
+			z := a[2] + (b[2]-a[2])*t + toolHeight(tool, radius, distance)
+			i := row*s.Cols + col
+			if z < s.Heights[i] {
+				s.Heights[i] = mgl32.Max(z, s.Min[2])
+			}
+		}
+	}
+}
+
+// colRange are the columns of the cells with their centers between x0, x1
+func (s *Stock) colRange(x0, x1 float32) (int, int) {
+	return cellRange(x0-s.Min[0], x1-s.Min[0], s.Cell, s.Cols)
+}
+
+func (s *Stock) rowRange(y0, y1 float32) (int, int) {
+	return cellRange(y0-s.Min[1], y1-s.Min[1], s.Cell, s.Rows)
+}
+
+func cellRange(from, to float32, cell float32, count int) (int, int) {
+	first := int(math.Ceil(float64(from/cell - 0.5)))
+	last := int(math.Floor(float64(to/cell - 0.5)))
+	if first < 0 {
+		first = 0
+	}
+	if last > count-1 {
+		last = count - 1
+	}
+	return first, last
+}
+
+// floats of every vertex of the stock mesh
+const StockVertexFloats = 6
+
+// Mesh are the triangles of the surface of the stock: the vertices, with
+// their position and normal, and the indices of the triangles. prepare
+// swaps the axes to the Y-up coordinates of the preview
+func (s *Stock) Mesh(prepare bool) ([]float32, []uint32) {
+	vertices := make([]float32, 0, (s.Cols*s.Rows+4*(s.Cols+s.Rows)+4)*StockVertexFloats)
+	indices := make([]uint32, 0, 6*(s.Cols*s.Rows+2*(s.Cols+s.Rows)+1))
+	vertex := func(p, n mgl32.Vec3) uint32 {
+		if prepare {
+			p, n = PreviewPoint(p), PreviewPoint(n)
+		}
+		vertices = append(vertices, p[0], p[1], p[2], n[0], n[1], n[2])
+		return uint32(len(vertices)/StockVertexFloats - 1)
+	}
+	quad := func(a, b, c, d uint32) {
+		indices = append(indices, a, b, c, a, c, d)
+	}
+	height := func(col, row int) float32 {
+		col = clampInt(col, 0, s.Cols-1)
+		row = clampInt(row, 0, s.Rows-1)
+		return s.Heights[row*s.Cols+col]
+	}
+	point := func(col, row int) mgl32.Vec3 {
+		c := s.cellCenter(col, row)
+		return mgl32.Vec3{c[0], c[1], height(col, row)}
+	}
+
+	// top, the normals from the slope around every cell
+	for row := 0; row < s.Rows; row++ {
+		for col := 0; col < s.Cols; col++ {
+			dx := (height(col+1, row) - height(col-1, row)) / (2 * s.Cell)
+			dy := (height(col, row+1) - height(col, row-1)) / (2 * s.Cell)
+			vertex(point(col, row), mgl32.Vec3{-dx, -dy, 1}.Normalize())
+		}
+	}
+	for row := 0; row+1 < s.Rows; row++ {
+		for col := 0; col+1 < s.Cols; col++ {
+			i := uint32(row*s.Cols + col)
+			quad(i, i+1, i+1+uint32(s.Cols), i+uint32(s.Cols))
+		}
+	}
+
+	// sides, from the edge of the top down to the bottom
+	side := func(count int, at func(int) (int, int), normal mgl32.Vec3) {
+		for k := 0; k+1 < count; k++ {
+			top0, top1 := point(at(k)), point(at(k+1))
+			bottom0, bottom1 := top0, top1
+			bottom0[2], bottom1[2] = s.Min[2], s.Min[2]
+			quad(vertex(bottom0, normal), vertex(bottom1, normal), vertex(top1, normal), vertex(top0, normal))
+		}
+	}
+	side(s.Cols, func(k int) (int, int) { return k, 0 }, mgl32.Vec3{0, -1, 0})
+	side(s.Rows, func(k int) (int, int) { return s.Cols - 1, k }, mgl32.Vec3{1, 0, 0})
+	side(s.Cols, func(k int) (int, int) { return s.Cols - 1 - k, s.Rows - 1 }, mgl32.Vec3{0, 1, 0})
+	side(s.Rows, func(k int) (int, int) { return 0, s.Rows - 1 - k }, mgl32.Vec3{-1, 0, 0})
+
+	// bottom
+	down := mgl32.Vec3{0, 0, -1}
+	corner := func(col, row int) uint32 {
+		p := point(col, row)
+		p[2] = s.Min[2]
+		return vertex(p, down)
+	}
+	quad(corner(0, 0), corner(0, s.Rows-1), corner(s.Cols-1, s.Rows-1), corner(s.Cols-1, 0))
+	return vertices, indices
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// CutStockStream removes from stock the material cut by the program read
+// from r, with the tools of the tool table. progress, if not nil, gets the
+// number of bytes read so far
+func CutStockStream(r io.Reader, stock *Stock, tools ToolTable, units Units, offsets *WorkOffsets, progress func(int64)) ([]parser.Diagnostic, error) {
+	l := DefaultLimits(units)
+	ms := newEvaluationState(l.feedRate(), l.rapidFeedRate(), mgl32.Vec3{0, 0, 0}, tools, units, offsets, mgl32.Vec3{})
+	ms.keepPath = false
+	ms.fragmentCb = func(f *Fragment) {
+		stock.CutFragment(f, ms.tools[f.tool])
+	}
+	return ms.evaluateStream(r, progress)
+}
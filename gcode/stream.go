@@ -0,0 +1,188 @@
+package gcode
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/adragomir/linuxcncgo/gcode/parser"
+	"github.com/adragomir/linuxcncgo/ui/glutil"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// longest line accepted by the streaming reader
+const maxStreamLine = 1024 * 1024
+
+// the connected fragments are planned once this many wait, the ones the
+// machine can stop after within the rest are run
+const maxStreamGroup = 1000
+
+// progress is reported every this many lines
+const streamProgressLines = 10000
+
+// streamPlanner groups the fragments as they are produced and runs the
+// speed planning on every group as soon as it is complete. A long group is
+// run in parts, its fragments wait only until the machine could stop
+// before the last one
+type streamPlanner struct {
+	sim         *Simulation
+	accumulator *Accumulator
+	planner     *planner
+	group       []*Fragment
+	// squared speed the group is entered at, the exit speed of the part
+	// run before
+	entry float32
+}
+
+func (p *streamPlanner) push(f *Fragment) {
+	var previous *Fragment
+	if len(p.group) > 0 {
+		previous = p.group[len(p.group)-1]
+	}
+	if !p.planner.connect(previous, f) {
+		p.flush()
+	}
+	p.group = append(p.group, f)
+	if len(p.group) >= maxStreamGroup {
+		p.runAhead()
+	}
+}
+
+// runAhead runs the start of the group, up to where the rest of the group
+// is long enough to stop from any speed of the group. The speeds there do
+// not depend on what comes after the group, so they are the ones planned
+// over the whole run
+func (p *streamPlanner) runAhead() {
+	maxSqSpeed := p.entry
+	for _, f := range p.group {
+		maxSqSpeed = mgl32.Max(maxSqSpeed, f.squaredSpeed)
+	}
+	// squared speed the machine can stop from within group[n:]
+	stop := float32(0)
+	n := len(p.group)
+	for n > 0 && stop < maxSqSpeed {
+		n--
+		stop += 2 * p.group[n].maxAccel * p.group[n].length
+	}
+	if n == 0 {
+		return
+	}
+	entry := plannedEntries(p.group, p.entry)
+	for i, f := range p.group[:n] {
+		planFragment(f, entry[i], entry[i+1])
+	}
+	p.sim.run(p.group[:n], p.accumulator)
+	p.entry = entry[n]
+	p.group = append(make([]*Fragment, 0, maxStreamGroup), p.group[n:]...)
+}
+
+// flush runs the group to its end, where the machine stops
+func (p *streamPlanner) flush() {
+	if len(p.group) == 0 {
+		return
+	}
+	planSpeed(p.group, p.entry)
+	p.sim.run(p.group, p.accumulator)
+	p.group = make([]*Fragment, 0, len(p.group))
+	p.entry = 0
+}
+
+// countingReader counts the bytes read, for the progress
+type countingReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// SimulateStream reads, evaluates and simulates a program line by line.
+// The path is not kept: the simulated vertices go to vertices as they are
+// produced and only the segment map stays in the returned accumulator.
+// progress, if not nil, gets the number of bytes read so far.
+//
+// The lines, their fragments and the speed planning take bounded memory.
+// What stays O(program) is the segment map, a polyline per line, the start
+// time of every line in the stats, and what vertices keeps
+func (s *Simulation) SimulateStream(r io.Reader, feedRate float32, travelFeedRate float32, pos mgl32.Vec3, vertices func(*SimFragment), progress func(int64)) (*Accumulator, SimulateStats, []parser.Diagnostic, error) {
+	s.stats = SimulateStats{
+		TotalTime: 0.0,
+		Bbox:      glutil.NewBoundingBox(),
+		Units:     s.Units,
+	}
+	s.CurrentTime = 0.0
+	s.lastPos = mgl32.Vec3{0, 0, 0}
+
+	ms := newEvaluationState(feedRate, travelFeedRate, pos, s.Tools, s.Units, s.Offsets, s.RotaryCenter)
+	ms.keepPath = false
+	ms.accumulator.handler = vertices
+	planner := &streamPlanner{
+		sim:         s,
+		accumulator: ms.accumulator,
+		planner:     newPlanner(s.limits()),
+		group:       make([]*Fragment, 0),
+	}
+	ms.fragmentCb = planner.push
+
+	diagnostics, err := ms.evaluateStream(r, progress)
+	if err != nil {
+		return ms.accumulator, s.stats, diagnostics, err
+	}
+	planner.flush()
+	ms.accumulator.Close()
+	s.stats.ProgramUnits = ms.programUnits
+	return ms.accumulator, s.stats, diagnostics, nil
+}
+
+// evaluateStream reads and evaluates a program line by line, the fragments
+// go to the fragment callback. progress, if not nil, gets the number of
+// bytes read so far
+func (ms *MachineState) evaluateStream(r io.Reader, progress func(int64)) ([]parser.Diagnostic, error) {
+	counter := &countingReader{r: r}
+	scanner := bufio.NewScanner(counter)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	diagnostics := make([]parser.Diagnostic, 0)
+	prog := &streamProgram{}
+	lineNo := 0
+	for ; !ms.programEnd && scanner.Scan(); lineNo++ {
+		line, diagnostic := parser.ParseLine(lineNo, strings.TrimSuffix(scanner.Text(), "\r"))
+		if diagnostic != nil {
+			diagnostics = append(diagnostics, *diagnostic)
+		} else if line != nil {
+			if err := prog.push(*line, ms); err != nil {
+				ms.reportControlError(err)
+				break
+			}
+		}
+		if progress != nil && lineNo%streamProgressLines == 0 {
+			progress(counter.read)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ms.finish(diagnostics), err
+	}
+	prog.finish(ms)
+	diagnostics = ms.finish(diagnostics)
+	if progress != nil {
+		progress(counter.read)
+	}
+	return diagnostics, nil
+}
+
+// SimulateGCodeStream is SimulateGCode for a program read from r, the
+// rotary axes turn about rotaryCenter
+func SimulateGCodeStream(r io.Reader, tools ToolTable, units Units, offsets *WorkOffsets, limits *MachineLimits, rotaryCenter mgl32.Vec3, vertices func(*SimFragment), progress func(int64)) (*Accumulator, SimulateStats, []parser.Diagnostic, error) {
+	sim := Simulation{
+		CurrentTime:  0.0,
+		Tools:        tools,
+		Units:        units,
+		Offsets:      offsets,
+		Limits:       limits,
+		RotaryCenter: rotaryCenter,
+	}
+	l := sim.limits()
+	return sim.SimulateStream(r, l.feedRate(), l.rapidFeedRate(), mgl32.Vec3{0, 0, 0}, vertices, progress)
+}
@@ -0,0 +1,52 @@
+package gcode
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// ToolShape is the shape of the cutting end of a tool
+type ToolShape int
+
+const (
+	ToolFlat ToolShape = iota
+	ToolBall
+	ToolVBit
+)
+
+// Tool is the geometry of a tool table entry, as needed by the simulator
+type Tool struct {
+	Number   int
+	Diameter float32
+	Length   float32
+	Shape    ToolShape
+	// included angle of a V-bit, in degrees
+	Angle float32
+}
+
+type ToolTable map[int]Tool
+
+var (
+	ballToolRe  = regexp.MustCompile(`(?i)\bball`)
+	vBitToolRe  = regexp.MustCompile(`(?i)\bv-?bit|\bv-?cutter|\bchamfer|\bengrav`)
+	toolAngleRe = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(?:°|deg)`)
+)
+
+// ParseToolShape guesses the shape of a tool from its comment in the tool
+// table, which has no field for it: "6mm ball", "V-bit 60deg". A V-bit
+// without an angle is 90 degrees, a tool with neither word is flat
+func ParseToolShape(comment string) (ToolShape, float32) {
+	switch {
+	case ballToolRe.MatchString(comment):
+		return ToolBall, 0
+	case vBitToolRe.MatchString(comment):
+		angle := float32(90)
+		if m := toolAngleRe.FindStringSubmatch(comment); m != nil {
+			if val, err := strconv.ParseFloat(m[1], 32); err == nil && val > 0 && val < 180 {
+				angle = float32(val)
+			}
+		}
+		return ToolVBit, angle
+	}
+	return ToolFlat, 0
+}
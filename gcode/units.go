@@ -0,0 +1,31 @@
+package gcode
+
+// Units are the linear units of a program or of the machine
+type Units int
+
+const (
+	UnitsMM Units = iota
+	UnitsInch
+	UnitsCM
+)
+
+// Millimeters returns the length of one unit in mm
+func (u Units) Millimeters() float32 {
+	switch u {
+	case UnitsInch:
+		return 25.4
+	case UnitsCM:
+		return 10.0
+	}
+	return 1.0
+}
+
+func (u Units) String() string {
+	switch u {
+	case UnitsInch:
+		return "in"
+	case UnitsCM:
+		return "cm"
+	}
+	return "mm"
+}
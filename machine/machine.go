@@ -153,6 +153,15 @@ type Machine struct {
 
 	JogDistance float64
 	JogVelocity float64
+
+	// actual positions, as the status reports them
+	Trail *PositionTrail
+
+	// errors the controller reported, counted to tell the commands waited
+	// for that failed
+	errorLock  sync.Mutex
+	errorCount int
+	lastError  string
 }
 
 func buildIncrements(tmp string) []float64 {
@@ -186,6 +195,12 @@ func (m *Machine) TryBuilding(s *Services) {
 	m.err.ErrorChannel.SocketUri = m.Dsn["error"]
 	m.err.OnErrorMsgReceived = append(m.err.OnErrorMsgReceived, func(rx *pb.Container, rest ...interface{}) {
 		log.Printf("COMMAND ERROR %s", prototext.Format(rx))
+		if t := rx.GetType(); t == pb.ContainerType_MT_EMC_NML_ERROR || t == pb.ContainerType_MT_EMC_OPERATOR_ERROR {
+			m.errorLock.Lock()
+			m.errorCount++
+			m.lastError = strings.Join(rx.GetNote(), " ")
+			m.errorLock.Unlock()
+		}
 	})
 	m.err.Start()
 
@@ -196,6 +211,7 @@ func (m *Machine) TryBuilding(s *Services) {
 	})
 	m.config.Start()
 
+	m.Trail = NewPositionTrail(trailCapacity, trailMinDistance)
 	m.status = application.NewStatusBase(0, "status")
 	m.status.AddStatusTopic("io")
 	m.status.AddStatusTopic("task")
@@ -213,6 +229,7 @@ func (m *Machine) TryBuilding(s *Services) {
 			case "motion":
 				m.MotionState = rx.GetEmcStatusMotion()
 				m.syncedChannels |= MotionChannel
+				m.pushTrail()
 			case "io":
 				m.IoState = rx.GetEmcStatusIo()
 				m.syncedChannels |= IoChannel
@@ -241,6 +258,7 @@ func (m *Machine) TryBuilding(s *Services) {
 			case "motion":
 				if m.MotionState != nil {
 					proto.Merge(m.MotionState, rx.GetEmcStatusMotion())
+					m.pushTrail()
 				}
 			case "io":
 				if m.IoState != nil {
@@ -304,6 +322,13 @@ func (m *Machine) Running() bool {
 	return false
 }
 
+// Paused is true when a program is paused, by the operator or by M0 and M1
+func (m *Machine) Paused() bool {
+	return m.TaskState != nil && m.InterpState != nil &&
+		m.TaskState.GetTaskMode() == pb.EmcTaskModeType_EMC_TASK_MODE_AUTO &&
+		m.InterpState.GetInterpState() == pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED
+}
+
 func (m *Machine) Files() []network.FileEntry {
 	m.fMutex.RLock()
 	defer m.fMutex.RUnlock()
@@ -356,6 +381,11 @@ func (m *Machine) GetPosition() []float64 {
 	}
 }
 
+func (m *Machine) pushTrail() {
+	pos := m.MotionState.GetActualPosition()
+	m.Trail.Push([3]float64{pos.GetX(), pos.GetY(), pos.GetZ()})
+}
+
 func (m *Machine) GetDtg() []float64 {
 	if m.MotionState == nil {
 		return []float64{}
@@ -392,6 +422,14 @@ func (m *Machine) GetG5XOffset() []float64 {
 	}
 }
 
+// GetG5XIndex is the active coordinate system, 1 is G54 .. 9 is G59.3
+func (m *Machine) GetG5XIndex() int {
+	if m.MotionState == nil {
+		return 0
+	}
+	return int(m.MotionState.GetG5XIndex())
+}
+
 func (m *Machine) DownloadRemoteFile(p string) ([]byte, error) {
 	m.ftp.Ensure(false)
 	buf, err := m.ftp.Retr(p)
@@ -505,7 +543,11 @@ func (m *Machine) setTaskMode(interp string, taskMode pb.EmcTaskModeType) {
 
 func (m *Machine) ExecuteMdi(interp string, mdiCommand string) {
 	log.Printf("Execute command: '%s'", mdiCommand)
-	m.setTaskMode("execute", pb.EmcTaskModeType_EMC_TASK_MODE_MDI)
+	// a paused program takes MDI in auto mode, the mode cannot change
+	// before it is aborted
+	if !m.Paused() {
+		m.setTaskMode("execute", pb.EmcTaskModeType_EMC_TASK_MODE_MDI)
+	}
 	msg := &pb.Container{
 		EmcCommandParams: &pb.EmcCommandParameters{
 			Command: util.S(mdiCommand),
@@ -594,6 +636,9 @@ func (m *Machine) StepProgram(interp string) {
 	m.command.SendEmcTaskPlanStep(msg)
 }
 
+// coordinate systems, in the order of their indexes
+var LCS_NAMES = []string{"G54", "G55", "G56", "G57", "G58", "G59", "G59.1", "G59.2", "G59.3"}
+
 var lcsNameToIndex = map[string]int{
 	"G54":   1,
 	"G55":   2,
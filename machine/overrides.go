@@ -0,0 +1,78 @@
+package machine
+
+import (
+	"github.com/adragomir/linuxcncgo/util"
+	pb "github.com/machinekit/machinetalk_protobuf_go"
+)
+
+// Overrides are the scales of the feed, the rapids and the spindle speed, 1
+// is as programmed, and if the program can turn them off
+type Overrides struct {
+	Feed    float64
+	Rapid   float64
+	Spindle float64
+
+	FeedOverrideEnabled    bool
+	SpindleOverrideEnabled bool
+	FeedHoldEnabled        bool
+}
+
+func (m *Machine) GetOverrides() Overrides {
+	if m.MotionState == nil {
+		return Overrides{Feed: 1, Rapid: 1, Spindle: 1}
+	}
+	return Overrides{
+		Feed:                   m.MotionState.GetFeedrate(),
+		Rapid:                  m.MotionState.GetRapidrate(),
+		Spindle:                m.MotionState.GetSpindlerate(),
+		FeedOverrideEnabled:    m.MotionState.GetFeedOverrideEnabled(),
+		SpindleOverrideEnabled: m.MotionState.GetSpindleOverrideEnabled(),
+		FeedHoldEnabled:        m.MotionState.GetFeedHoldEnabled(),
+	}
+}
+
+func scaleMsg(scale float64) *pb.Container {
+	return &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{
+			Scale: util.F64(scale),
+		},
+	}
+}
+
+func enableMsg(enable bool) *pb.Container {
+	return &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{
+			Enable: util.B(enable),
+		},
+	}
+}
+
+// SetFeedOverride scales the feed of the moves, also while a program runs
+func (m *Machine) SetFeedOverride(scale float64) {
+	m.command.SendEmcTrajSetScale(scaleMsg(scale))
+}
+
+// SetRapidOverride scales the rapids, up to 1
+func (m *Machine) SetRapidOverride(scale float64) {
+	m.command.SendEmcTrajSetRapidScale(scaleMsg(scale))
+}
+
+// SetSpindleOverride scales the speed of the spindle
+func (m *Machine) SetSpindleOverride(scale float64) {
+	m.command.SendEmcTrajSetSpindleScale(scaleMsg(scale))
+}
+
+// SetFeedOverrideEnable lets the feed override apply, M48 and M49 switch it
+// from programs
+func (m *Machine) SetFeedOverrideEnable(enable bool) {
+	m.command.SendEmcTrajSetFoEnable(enableMsg(enable))
+}
+
+func (m *Machine) SetSpindleOverrideEnable(enable bool) {
+	m.command.SendEmcTrajSetSoEnable(enableMsg(enable))
+}
+
+// SetFeedHoldEnable lets the feed hold input stop the moves
+func (m *Machine) SetFeedHoldEnable(enable bool) {
+	m.command.SendEmcTrajSetFhEnable(enableMsg(enable))
+}
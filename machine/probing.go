@@ -0,0 +1,412 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	pb "github.com/machinekit/machinetalk_protobuf_go"
+)
+
+// ProbeRoutine is a measure made with the probe from where it is
+type ProbeRoutine int
+
+const (
+	// an edge along X, probed toward Direction[0]
+	ProbeEdgeX ProbeRoutine = iota
+	// an edge along Y, probed toward Direction[1]
+	ProbeEdgeY
+	// a corner, its X and Y edges probed toward Direction
+	ProbeCorner
+	// the center of a hole, from inside it
+	ProbeBore
+	// the center of a boss, from above it
+	ProbeBoss
+	// the Z of a surface under the probe
+	ProbeSurface
+)
+
+var PROBE_ROUTINE_NAMES = []string{
+	ProbeEdgeX:   "Edge X",
+	ProbeEdgeY:   "Edge Y",
+	ProbeCorner:  "Corner",
+	ProbeBore:    "Bore center",
+	ProbeBoss:    "Boss center",
+	ProbeSurface: "Surface Z",
+}
+
+// ProbeSettings are how a routine probes, in machine units
+type ProbeSettings struct {
+	// diameter of the tip of the probe, the edges found are corrected by its
+	// radius
+	TipDiameter float64
+	// how far contact is searched for, and how far the probe backs off for
+	// the second, slower touch
+	Search  float64
+	Retract float64
+	// feeds of the first and the second touch, per minute
+	Feed      float64
+	LatchFeed float64
+	// toward the edges of an edge or a corner, -1 or 1 on X and Y
+	Direction [2]float64
+	// sideways move along the other axis before each edge of a corner, to
+	// reach the faces of an outside corner. 0 probes an inside corner
+	Offset float64
+	// about the diameter of a bore or a boss
+	Diameter float64
+	// how far down beside a boss its sides are probed
+	Depth float64
+}
+
+// ProbeResult is what a routine found, in machine coordinates
+type ProbeResult struct {
+	Routine ProbeRoutine
+	// where the probe touched
+	Points [][3]float64
+	// the edge, corner, center or surface found, on the axes set in Axes
+	Position [3]float64
+	Axes     [3]bool
+	// diameter of a bore or a boss
+	Diameter float64
+}
+
+func (r ProbeResult) String() string {
+	parts := make([]string, 0, 4)
+	for axis, name := range []string{"X", "Y", "Z"} {
+		if r.Axes[axis] {
+			parts = append(parts, fmt.Sprintf("%s%.4f", name, r.Position[axis]))
+		}
+	}
+	if r.Routine == ProbeBore || r.Routine == ProbeBoss {
+		parts = append(parts, fmt.Sprintf("D%.4f", r.Diameter))
+	}
+	return strings.Join(parts, " ")
+}
+
+// CircleCenter is the center and the radius of the circle through three
+// points
+func CircleCenter(a, b, c [2]float64) ([2]float64, float64, error) {
+	// the center is on the bisectors of ab and ac
+	bx, by := b[0]-a[0], b[1]-a[1]
+	cx, cy := c[0]-a[0], c[1]-a[1]
+	d := 2 * (bx*cy - by*cx)
+	if math.Abs(d) < 1e-12 {
+		return [2]float64{}, 0, errors.New("the points are on a line")
+	}
+	b2, c2 := bx*bx+by*by, cx*cx+cy*cy
+	ux := (cy*b2 - by*c2) / d
+	uy := (bx*c2 - cx*b2) / d
+	return [2]float64{a[0] + ux, a[1] + uy}, math.Hypot(ux, uy), nil
+}
+
+// longest a move of a routine is waited for, and a probe move is waited
+// for to start
+const (
+	probeMoveTimeout  = time.Minute
+	probeStartTimeout = 2 * time.Second
+)
+
+// how long the status of the motion may come after the one of the
+// interpreter
+const statusSettle = 100 * time.Millisecond
+
+// ErrStopped is returned by the routines stopped before they were done
+var ErrStopped = errors.New("stopped")
+
+// errorsSeen is how many errors the controller reported until now
+func (m *Machine) errorsSeen() int {
+	m.errorLock.Lock()
+	defer m.errorLock.Unlock()
+	return m.errorCount
+}
+
+// errorSince is the last error the controller reported, if there were more
+// than count
+func (m *Machine) errorSince(count int) error {
+	m.errorLock.Lock()
+	defer m.errorLock.Unlock()
+	if m.errorCount == count {
+		return nil
+	}
+	return fmt.Errorf("the machine reported an error: %s", m.lastError)
+}
+
+// waitMdi waits for a MDI command sent to be done. A probe move has to be
+// seen running, else the probe status read after it would be the one of the
+// move before. Another command too short to be seen running is taken as
+// done after a while. It fails when the controller reports an error past
+// errorCount or when ctx is done
+func (m *Machine) waitMdi(ctx context.Context, errorCount int, probe bool) error {
+	start := time.Now()
+	seen := false
+	for time.Since(start) < probeMoveTimeout {
+		if err := m.errorSince(errorCount); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ErrStopped
+		}
+		if m.mdiBusy() {
+			seen = true
+		} else if seen {
+			time.Sleep(statusSettle)
+			return m.errorSince(errorCount)
+		} else if probe && time.Since(start) > probeStartTimeout {
+			return errors.New("the probe move did not start")
+		} else if !probe && time.Since(start) > time.Second {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return errors.New("the machine did not finish moving")
+}
+
+// mdiBusy is true while a MDI command runs. The interpreter of a paused
+// program stays paused during the MDI, the task is seen executing it
+func (m *Machine) mdiBusy() bool {
+	if m.Paused() {
+		return m.TaskState.GetExecState() != pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE
+	}
+	return m.Running()
+}
+
+// runMdi sends a command and waits for it
+func (m *Machine) runMdi(ctx context.Context, command string, probe bool) error {
+	if ctx.Err() != nil {
+		return ErrStopped
+	}
+	count := m.errorsSeen()
+	m.ExecuteMdi("execute", command)
+	return m.waitMdi(ctx, count, probe)
+}
+
+// mdi runs commands one after the other, each waited for
+func (m *Machine) mdi(ctx context.Context, commands ...string) error {
+	for _, command := range commands {
+		if err := m.runMdi(ctx, command, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probeMove runs a G38.2 move, and is where the probe tripped, in machine
+// coordinates
+func (m *Machine) probeMove(ctx context.Context, command string) ([3]float64, error) {
+	if err := m.runMdi(ctx, command, true); err != nil {
+		return [3]float64{}, err
+	}
+	if m.MotionState == nil || !m.MotionState.GetProbeTripped() {
+		return [3]float64{}, errors.New("the probe found no contact")
+	}
+	probed := m.MotionState.GetProbedPosition()
+	return [3]float64{probed.GetX(), probed.GetY(), probed.GetZ()}, nil
+}
+
+// relativeMove are the words of a move by distance along dir
+func relativeMove(dir [3]float64, distance float64) string {
+	words := make([]string, 0, 3)
+	for axis, name := range []string{"X", "Y", "Z"} {
+		if dir[axis] != 0 {
+			words = append(words, fmt.Sprintf("%s%.4f", name, dir[axis]*distance))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// touch probes along dir, relative to where the probe is: a fast G38.2 to
+// find the contact, a back off and a slow G38.2 to measure it. The probe is
+// left backed off from the contact
+func (m *Machine) touch(ctx context.Context, dir [3]float64, distance float64, s ProbeSettings) ([3]float64, error) {
+	if _, err := m.probeMove(ctx, fmt.Sprintf("G91 G38.2 %s F%.1f", relativeMove(dir, distance), s.Feed)); err != nil {
+		return [3]float64{}, err
+	}
+	if err := m.mdi(ctx, fmt.Sprintf("G91 G0 %s", relativeMove(dir, -s.Retract))); err != nil {
+		return [3]float64{}, err
+	}
+	out, err := m.probeMove(ctx, fmt.Sprintf("G91 G38.2 %s F%.1f", relativeMove(dir, 2*s.Retract), s.LatchFeed))
+	if err != nil {
+		return [3]float64{}, fmt.Errorf("second touch: %v", err)
+	}
+	return out, m.mdi(ctx, fmt.Sprintf("G91 G0 %s", relativeMove(dir, -s.Retract)))
+}
+
+// probeStep is a touch of a routine: the moves to where it starts from,
+// relative to the start of the routine, and its direction and distance
+type probeStep struct {
+	before   []string
+	dir      [3]float64
+	distance float64
+	// the probe goes up before it goes back to the start
+	raise float64
+}
+
+// probeSteps are the touches of a routine
+func probeSteps(routine ProbeRoutine, s ProbeSettings) ([]probeStep, error) {
+	x, y := [3]float64{s.Direction[0], 0, 0}, [3]float64{0, s.Direction[1], 0}
+	switch routine {
+	case ProbeEdgeX, ProbeEdgeY:
+		dir := x
+		if routine == ProbeEdgeY {
+			dir = y
+		}
+		if dir == ([3]float64{}) {
+			return nil, errors.New("the direction of the edge is not set")
+		}
+		return []probeStep{{dir: dir, distance: s.Search}}, nil
+	case ProbeCorner:
+		if x[0] == 0 || y[1] == 0 {
+			return nil, errors.New("the direction of the corner is not set")
+		}
+		steps := []probeStep{{dir: x, distance: s.Search}, {dir: y, distance: s.Search}}
+		if s.Offset > 0 {
+			steps[0].before = []string{fmt.Sprintf("G91 G0 %s", relativeMove(y, s.Offset))}
+			steps[1].before = []string{fmt.Sprintf("G91 G0 %s", relativeMove(x, s.Offset))}
+		}
+		return steps, nil
+	case ProbeBore, ProbeBoss:
+		if s.Diameter <= 0 {
+			return nil, errors.New("the diameter is not set")
+		}
+		steps := make([]probeStep, 0, 3)
+		for i := 0; i < 3; i++ {
+			a := float64(i) * 2 * math.Pi / 3
+			out := [3]float64{math.Cos(a), math.Sin(a), 0}
+			if routine == ProbeBore {
+				steps = append(steps, probeStep{dir: out, distance: s.Diameter/2 + s.Search})
+				continue
+			}
+			// out beside the boss, down, and in toward its center
+			steps = append(steps, probeStep{
+				before: []string{
+					fmt.Sprintf("G91 G0 %s", relativeMove(out, s.Diameter/2+s.Search)),
+					fmt.Sprintf("G91 G0 Z%.4f", -s.Depth),
+				},
+				dir:      [3]float64{-out[0], -out[1], 0},
+				distance: 2 * s.Search,
+				raise:    s.Depth,
+			})
+		}
+		return steps, nil
+	case ProbeSurface:
+		return []probeStep{{dir: [3]float64{0, 0, -1}, distance: s.Search}}, nil
+	}
+	return nil, errors.New("unknown probe routine")
+}
+
+// probeResult computes what a routine found from where it touched
+func probeResult(routine ProbeRoutine, s ProbeSettings, points [][3]float64) (ProbeResult, error) {
+	r := ProbeResult{Routine: routine, Points: points}
+	tip := s.TipDiameter / 2
+	switch routine {
+	case ProbeEdgeX, ProbeCorner:
+		r.Position[0] = points[0][0] + s.Direction[0]*tip
+		r.Axes[0] = true
+		if routine == ProbeCorner {
+			r.Position[1] = points[1][1] + s.Direction[1]*tip
+			r.Axes[1] = true
+		}
+	case ProbeEdgeY:
+		r.Position[1] = points[0][1] + s.Direction[1]*tip
+		r.Axes[1] = true
+	case ProbeBore, ProbeBoss:
+		center, radius, err := CircleCenter(
+			[2]float64{points[0][0], points[0][1]},
+			[2]float64{points[1][0], points[1][1]},
+			[2]float64{points[2][0], points[2][1]},
+		)
+		if err != nil {
+			return r, err
+		}
+		r.Position = [3]float64{center[0], center[1], 0}
+		r.Axes = [3]bool{true, true, false}
+		if routine == ProbeBore {
+			r.Diameter = 2 * (radius + tip)
+		} else {
+			r.Diameter = 2 * (radius - tip)
+		}
+	case ProbeSurface:
+		r.Position[2] = points[0][2]
+		r.Axes[2] = true
+	}
+	return r, nil
+}
+
+// RunProbe runs a probe routine from where the probe is, with MDI, and
+// computes what it found. The probe goes back to where it started after
+// every touch. It waits for the machine, progress is called after every
+// touch. Done with ctx, it stops before the next command with ErrStopped,
+// the moves sent are to be aborted by the caller
+func (m *Machine) RunProbe(ctx context.Context, routine ProbeRoutine, s ProbeSettings, progress func(done, total int)) (ProbeResult, error) {
+	if m.Running() {
+		return ProbeResult{}, errors.New("cannot probe while running")
+	}
+	steps, err := probeSteps(routine, s)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	start := m.GetPosition()
+	if len(start) < 3 {
+		return ProbeResult{}, errors.New("the position of the machine is not known")
+	}
+	back := fmt.Sprintf("G90 G53 G0 X%.4f Y%.4f", start[0], start[1])
+	if routine == ProbeSurface {
+		back = fmt.Sprintf("G90 G53 G0 Z%.4f", start[2])
+	}
+	// the moves of the routine are relative, the programs are not
+	defer m.ExecuteMdi("execute", "G90")
+
+	points := make([][3]float64, 0, len(steps))
+	for i, step := range steps {
+		if err := m.mdi(ctx, step.before...); err != nil {
+			return ProbeResult{}, err
+		}
+		point, err := m.touch(ctx, step.dir, step.distance, s)
+		if err != nil {
+			return ProbeResult{}, err
+		}
+		points = append(points, point)
+		if step.raise != 0 {
+			if err := m.mdi(ctx, fmt.Sprintf("G91 G0 Z%.4f", step.raise)); err != nil {
+				return ProbeResult{}, err
+			}
+		}
+		if err := m.mdi(ctx, back); err != nil {
+			return ProbeResult{}, err
+		}
+		if progress != nil {
+			progress(i+1, len(steps))
+		}
+	}
+	return probeResult(routine, s, points)
+}
+
+// SetWorkOffset moves the origin of a coordinate system, G54 .. G59.3, to a
+// position in machine coordinates, on the axes set. It is set with G10 L20
+// relative to where the machine is, which keeps the tool length offset and
+// the G92 offset out of the origin
+func (m *Machine) SetWorkOffset(lcs string, position [3]float64, axes [3]bool) error {
+	index, ok := lcsNameToIndex[lcs]
+	if !ok {
+		return fmt.Errorf("unknown coordinate system %s", lcs)
+	}
+	if m.MotionState == nil {
+		return errors.New("the position of the machine is not known")
+	}
+	at := m.MotionState.GetPosition()
+	current := [3]float64{at.GetX(), at.GetY(), at.GetZ()}
+	words := make([]string, 0, 3)
+	for axis, name := range []string{"X", "Y", "Z"} {
+		if axes[axis] {
+			words = append(words, fmt.Sprintf("%s%.4f", name, current[axis]-position[axis]))
+		}
+	}
+	if len(words) == 0 {
+		return errors.New("no axis to set")
+	}
+	m.ExecuteMdi("execute", fmt.Sprintf("G10 L20 P%d %s", index, strings.Join(words, " ")))
+	return nil
+}
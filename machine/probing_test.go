@@ -0,0 +1,179 @@
+package machine
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func nearly(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCircleCenter(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b, c [2]float64
+		center  [2]float64
+		radius  float64
+		err     bool
+	}{
+		{"unit circle", [2]float64{1, 0}, [2]float64{0, 1}, [2]float64{-1, 0}, [2]float64{0, 0}, 1, false},
+		{"off the origin", [2]float64{15, 5}, [2]float64{5, 15}, [2]float64{5, -5}, [2]float64{5, 5}, 10, false},
+		{"points on a line", [2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 2}, [2]float64{}, 0, true},
+		{"same point twice", [2]float64{1, 2}, [2]float64{1, 2}, [2]float64{3, 4}, [2]float64{}, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			center, radius, err := CircleCenter(test.a, test.b, test.c)
+			if (err != nil) != test.err {
+				t.Fatalf("error %v, want an error %v", err, test.err)
+			}
+			if test.err {
+				return
+			}
+			if !nearly(center[0], test.center[0]) || !nearly(center[1], test.center[1]) || !nearly(radius, test.radius) {
+				t.Errorf("got %v radius %f, want %v radius %f", center, radius, test.center, test.radius)
+			}
+		})
+	}
+}
+
+func TestProbeSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		routine   ProbeRoutine
+		direction [2]float64
+		offset    float64
+		dirs      [][3]float64
+		before    [][]string
+		err       bool
+	}{
+		{"edge X toward -X", ProbeEdgeX, [2]float64{-1, 1}, 0, [][3]float64{{-1, 0, 0}}, [][]string{nil}, false},
+		{"edge Y toward +Y", ProbeEdgeY, [2]float64{-1, 1}, 0, [][3]float64{{0, 1, 0}}, [][]string{nil}, false},
+		{"edge without a direction", ProbeEdgeY, [2]float64{1, 0}, 0, nil, nil, true},
+		{"inside corner", ProbeCorner, [2]float64{1, 1}, 0, [][3]float64{{1, 0, 0}, {0, 1, 0}}, [][]string{nil, nil}, false},
+		{
+			"outside corner toward -X +Y", ProbeCorner, [2]float64{-1, 1}, 5,
+			[][3]float64{{-1, 0, 0}, {0, 1, 0}},
+			[][]string{{"G91 G0 Y5.0000"}, {"G91 G0 X-5.0000"}},
+			false,
+		},
+		{
+			"outside corner toward +X -Y", ProbeCorner, [2]float64{1, -1}, 5,
+			[][3]float64{{1, 0, 0}, {0, -1, 0}},
+			[][]string{{"G91 G0 Y-5.0000"}, {"G91 G0 X5.0000"}},
+			false,
+		},
+		{"corner without a direction", ProbeCorner, [2]float64{1, 0}, 0, nil, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			steps, err := probeSteps(test.routine, ProbeSettings{Search: 10, Direction: test.direction, Offset: test.offset})
+			if (err != nil) != test.err {
+				t.Fatalf("error %v, want an error %v", err, test.err)
+			}
+			if len(steps) != len(test.dirs) {
+				t.Fatalf("got %d steps, want %d", len(steps), len(test.dirs))
+			}
+			for i, step := range steps {
+				if step.dir != test.dirs[i] || step.distance != 10 {
+					t.Errorf("step %d: toward %v for %f, want %v for 10", i, step.dir, step.distance, test.dirs[i])
+				}
+				if !reflect.DeepEqual(step.before, test.before[i]) {
+					t.Errorf("step %d: moves %q before, want %q", i, step.before, test.before[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProbeStepsCircle(t *testing.T) {
+	if _, err := probeSteps(ProbeBore, ProbeSettings{Search: 2}); err == nil {
+		t.Errorf("a bore was probed without its diameter")
+	}
+	s := ProbeSettings{Search: 2, Diameter: 20, Depth: 3}
+	for _, routine := range []ProbeRoutine{ProbeBore, ProbeBoss} {
+		steps, err := probeSteps(routine, s)
+		if err != nil || len(steps) != 3 {
+			t.Fatalf("%s: got %d steps, error %v", PROBE_ROUTINE_NAMES[routine], len(steps), err)
+		}
+		for i, step := range steps {
+			// a bore is probed out from its center, a boss in toward it
+			a := float64(i) * 2 * math.Pi / 3
+			out := math.Cos(a)*step.dir[0] + math.Sin(a)*step.dir[1]
+			if routine == ProbeBore && (!nearly(out, 1) || step.distance != 12 || step.raise != 0) {
+				t.Errorf("bore step %d: toward %v for %f", i, step.dir, step.distance)
+			}
+			if routine == ProbeBoss && (!nearly(out, -1) || step.distance != 4 || step.raise != 3) {
+				t.Errorf("boss step %d: toward %v for %f, raised %f", i, step.dir, step.distance, step.raise)
+			}
+		}
+	}
+}
+
+// onCircle are three points at 0, 120 and 240 degrees of a circle
+func onCircle(x, y, radius float64) [][3]float64 {
+	points := make([][3]float64, 3)
+	for i := range points {
+		a := float64(i) * 2 * math.Pi / 3
+		points[i] = [3]float64{x + radius*math.Cos(a), y + radius*math.Sin(a), 0}
+	}
+	return points
+}
+
+func TestProbeResult(t *testing.T) {
+	// the tip has a radius of 1
+	tests := []struct {
+		name      string
+		routine   ProbeRoutine
+		direction [2]float64
+		points    [][3]float64
+		position  [3]float64
+		axes      [3]bool
+		diameter  float64
+	}{
+		{"edge toward +X", ProbeEdgeX, [2]float64{1, 0}, [][3]float64{{10, 0, 0}}, [3]float64{11, 0, 0}, [3]bool{true, false, false}, 0},
+		{"edge toward -X", ProbeEdgeX, [2]float64{-1, 0}, [][3]float64{{10, 0, 0}}, [3]float64{9, 0, 0}, [3]bool{true, false, false}, 0},
+		{"edge toward +Y", ProbeEdgeY, [2]float64{0, 1}, [][3]float64{{0, 10, 0}}, [3]float64{0, 11, 0}, [3]bool{false, true, false}, 0},
+		{"edge toward -Y", ProbeEdgeY, [2]float64{0, -1}, [][3]float64{{0, 10, 0}}, [3]float64{0, 9, 0}, [3]bool{false, true, false}, 0},
+		{
+			"corner toward -X +Y", ProbeCorner, [2]float64{-1, 1}, [][3]float64{{10, 0, 0}, {0, 20, 0}},
+			[3]float64{9, 21, 0}, [3]bool{true, true, false}, 0,
+		},
+		{
+			"corner toward +X -Y", ProbeCorner, [2]float64{1, -1}, [][3]float64{{10, 0, 0}, {0, 20, 0}},
+			[3]float64{11, 19, 0}, [3]bool{true, true, false}, 0,
+		},
+		{"bore", ProbeBore, [2]float64{}, onCircle(5, -5, 9), [3]float64{5, -5, 0}, [3]bool{true, true, false}, 20},
+		{"boss", ProbeBoss, [2]float64{}, onCircle(5, -5, 11), [3]float64{5, -5, 0}, [3]bool{true, true, false}, 20},
+		{"surface", ProbeSurface, [2]float64{}, [][3]float64{{1, 2, -3}}, [3]float64{0, 0, -3}, [3]bool{false, false, true}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := probeResult(test.routine, ProbeSettings{TipDiameter: 2, Direction: test.direction}, test.points)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Axes != test.axes {
+				t.Errorf("axes %v, want %v", r.Axes, test.axes)
+			}
+			for axis := range r.Position {
+				if !nearly(r.Position[axis], test.position[axis]) {
+					t.Errorf("position %v, want %v", r.Position, test.position)
+					break
+				}
+			}
+			if !nearly(r.Diameter, test.diameter) {
+				t.Errorf("diameter %f, want %f", r.Diameter, test.diameter)
+			}
+		})
+	}
+}
+
+func TestProbeResultOnALine(t *testing.T) {
+	points := [][3]float64{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}}
+	if _, err := probeResult(ProbeBore, ProbeSettings{}, points); err == nil {
+		t.Errorf("a bore was found through points on a line")
+	}
+}
@@ -0,0 +1,173 @@
+package machine
+
+import (
+	"log"
+
+	"github.com/adragomir/linuxcncgo/util"
+	pb "github.com/machinekit/machinetalk_protobuf_go"
+)
+
+// SpindleDirection is the way the spindle turns, as the motion status
+// reports it
+type SpindleDirection int32
+
+const (
+	SpindleReverse SpindleDirection = -1
+	SpindleStopped SpindleDirection = 0
+	SpindleForward SpindleDirection = 1
+)
+
+func (d SpindleDirection) String() string {
+	switch d {
+	case SpindleForward:
+		return "CW"
+	case SpindleReverse:
+		return "CCW"
+	}
+	return "stopped"
+}
+
+// SpindleState is the spindle and the coolant, as the status reports them
+type SpindleState struct {
+	// commanded speed, in rpm
+	Speed     float64
+	Direction SpindleDirection
+	Enabled   bool
+	Brake     bool
+	Flood     bool
+	Mist      bool
+}
+
+func (m *Machine) GetSpindleState() SpindleState {
+	out := SpindleState{}
+	if m.MotionState != nil {
+		out.Speed = m.MotionState.GetSpindleSpeed()
+		out.Direction = SpindleDirection(m.MotionState.GetSpindleDirection())
+		out.Enabled = m.MotionState.GetSpindleEnabled()
+		out.Brake = m.MotionState.GetSpindleBrake()
+	}
+	if m.IoState != nil {
+		out.Flood = m.IoState.GetFlood()
+		out.Mist = m.IoState.GetMist()
+	}
+	return out
+}
+
+// DefaultSpindleSpeed is the speed the spindle is started at when none is
+// given, in rpm
+func (m *Machine) DefaultSpindleSpeed() float64 {
+	if m.ConfigState == nil {
+		return 0
+	}
+	return m.ConfigState.GetDefaultSpindleSpeed()
+}
+
+// manualMode switches to manual mode for the spindle and coolant commands,
+// which are refused while a program or an MDI command runs
+func (m *Machine) manualMode(what string) bool {
+	if m.Running() {
+		log.Printf("Cannot %s while running", what)
+		return false
+	}
+	m.setTaskMode("execute", pb.EmcTaskModeType_EMC_TASK_MODE_MANUAL)
+	return true
+}
+
+// SpindleOn starts the spindle at rpm in a direction, at the default speed
+// of the machine if rpm is not positive
+func (m *Machine) SpindleOn(rpm float64, dir SpindleDirection) {
+	if dir == SpindleStopped {
+		m.SpindleOff()
+		return
+	}
+	if !m.manualMode("start the spindle") {
+		return
+	}
+	if rpm <= 0 {
+		rpm = m.DefaultSpindleSpeed()
+	}
+	// the direction is the sign of the speed
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{
+			Velocity: util.F64(rpm * float64(dir)),
+		},
+	}
+	m.command.SendEmcSpindleOn(msg)
+}
+
+func (m *Machine) SpindleOff() {
+	if !m.manualMode("stop the spindle") {
+		return
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	m.command.SendEmcSpindleOff(msg)
+}
+
+// SpindleFaster speeds the turning spindle up by one step of the machine
+func (m *Machine) SpindleFaster() {
+	if !m.manualMode("change the spindle speed") {
+		return
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	m.command.SendEmcSpindleIncrease(msg)
+}
+
+// SpindleSlower slows the turning spindle down by one step of the machine
+func (m *Machine) SpindleSlower() {
+	if !m.manualMode("change the spindle speed") {
+		return
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	m.command.SendEmcSpindleDecrease(msg)
+}
+
+// SpindleBrake engages or releases the brake of the spindle
+func (m *Machine) SpindleBrake(engage bool) {
+	if !m.manualMode("change the spindle brake") {
+		return
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	if engage {
+		m.command.SendEmcSpindleBrakeEngage(msg)
+	} else {
+		m.command.SendEmcSpindleBrakeRelease(msg)
+	}
+}
+
+// Flood turns the flood coolant on or off
+func (m *Machine) Flood(on bool) {
+	if !m.manualMode("change the coolant") {
+		return
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	if on {
+		m.command.SendEmcCoolantFloodOn(msg)
+	} else {
+		m.command.SendEmcCoolantFloodOff(msg)
+	}
+}
+
+// Mist turns the mist coolant on or off
+func (m *Machine) Mist(on bool) {
+	if !m.manualMode("change the coolant") {
+		return
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	if on {
+		m.command.SendEmcCoolantMistOn(msg)
+	} else {
+		m.command.SendEmcCoolantMistOff(msg)
+	}
+}
@@ -0,0 +1,165 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pb "github.com/machinekit/machinetalk_protobuf_go"
+)
+
+// ToolSetter is a fixed probe the tools are measured on, in machine
+// coordinates
+type ToolSetter struct {
+	X, Y float64
+	// Z of the spindle nose, a tool of length 0, when it trips the setter
+	Z float64
+	// Z the tool is brought over the setter at, above the longest tool
+	SafeZ float64
+	// how far down from SafeZ the setter is searched for
+	Search float64
+	// feed of the search, in units per minute
+	Feed float64
+}
+
+// PendingToolChange is the tool a program waits for the operator to load:
+// the program stopped at an M6 and the tool prepared by the T word is not
+// the one in the spindle. A prepared tool alone is no tool change, the
+// posts of most CAM select the next tool long before its M6. The program
+// waits for the tool changed signal of the IO, or it is paused with the M6
+// active: the controller takes the MDI that measures the tool only from a
+// paused program, so for the tool to be measured in a program the M6 of
+// the machine is remapped to pause with M0 before the change, in the ini:
+//
+//	[RS274NGC]
+//	REMAP=M6 modalgroup=6 ngc=manual_change
+//
+// with manual_change.ngc a subroutine that runs M0 then M6, the M6 of a
+// remap being the one of the controller. Resuming it finishes the change
+func (m *Machine) PendingToolChange() (ToolEntry, bool) {
+	if m.TaskState == nil || m.IoState == nil || m.InterpState == nil {
+		return ToolEntry{}, false
+	}
+	execState := m.TaskState.GetExecState()
+	waiting := execState == pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_IO ||
+		execState == pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_MOTION_AND_IO
+	remapped := m.Paused() && m.activeMcode(6)
+	if !waiting && !remapped {
+		return ToolEntry{}, false
+	}
+	// the pocket prepared is the index of the tool in the table
+	prepped := m.IoState.GetPocketPrepped()
+	if prepped <= 0 {
+		return ToolEntry{}, false
+	}
+	for _, tool := range m.IoState.GetToolTable() {
+		if tool.GetIndex() != prepped {
+			continue
+		}
+		number := int(tool.GetId())
+		if number <= 0 || number == m.ToolInSpindle() {
+			return ToolEntry{}, false
+		}
+		table := m.GetToolTable()
+		if i, ok := table.Find(number); ok {
+			return table[i], true
+		}
+		return ToolEntry{Number: number}, true
+	}
+	return ToolEntry{}, false
+}
+
+// activeMcode is true when the M code is one of the active M codes the
+// interpreter reports
+func (m *Machine) activeMcode(code int32) bool {
+	for _, mcode := range m.InterpState.GetMcodes() {
+		if mcode.GetValue() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// activeGcode is the same for a G code, given times 10: 430 is G43
+func (m *Machine) activeGcode(code int32) bool {
+	for _, gcode := range m.InterpState.GetGcodes() {
+		if gcode.GetValue() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// programModes are the commands that set back the distance mode and the
+// tool length offset of the interpreter. A dynamic offset, G43.1, is not
+// known and stays canceled
+func (m *Machine) programModes() string {
+	modes := "G90"
+	if m.activeGcode(910) {
+		modes = "G91"
+	}
+	if m.activeGcode(430) {
+		modes += " G43"
+	}
+	return modes
+}
+
+// ProbeToolLength brings the tool in the spindle over the setter and probes
+// down onto it with G38.2, then back up and to where the machine was,
+// waiting for every move. It is the Z the spindle nose was at when the tool
+// tripped the setter, in machine coordinates, the length offset is
+// canceled to measure it. A program has to be paused in its M6, remapped
+// as told by PendingToolChange, its distance mode and length offset are
+// set back after
+func (m *Machine) ProbeToolLength(ctx context.Context, setter ToolSetter) (float64, error) {
+	if m.Running() && !m.Paused() {
+		return 0, errors.New("cannot measure a tool while a program runs")
+	}
+	if m.Paused() && !m.activeMcode(6) {
+		return 0, errors.New("the program is not paused at a tool change")
+	}
+	start := m.GetPosition()
+	if len(start) < 3 {
+		return 0, errors.New("the position of the machine is not known")
+	}
+	// the search is relative and without the length offset, the program
+	// goes on with its own modes
+	defer m.ExecuteMdi("execute", m.programModes())
+	if err := m.mdi(ctx,
+		"G49",
+		fmt.Sprintf("G53 G0 Z%.4f", setter.SafeZ),
+		fmt.Sprintf("G53 G0 X%.4f Y%.4f", setter.X, setter.Y),
+	); err != nil {
+		return 0, err
+	}
+	probed, err := m.probeMove(ctx, fmt.Sprintf("G91 G38.2 Z%.4f F%.1f", -setter.Search, setter.Feed))
+	if err != nil {
+		return 0, err
+	}
+	return probed[2], m.mdi(ctx,
+		"G90",
+		fmt.Sprintf("G53 G0 Z%.4f", setter.SafeZ),
+		fmt.Sprintf("G53 G0 X%.4f Y%.4f", start[0], start[1]),
+		fmt.Sprintf("G53 G0 Z%.4f", start[2]),
+	)
+}
+
+// SetToolLength sets the length offset of a tool with G10 L1, and applies
+// it with G43 if the tool is in the spindle. A program has to be paused for
+// it
+func (m *Machine) SetToolLength(tool ToolEntry, length float64) error {
+	if m.Running() && !m.Paused() {
+		return errors.New("cannot set a tool length while a program runs")
+	}
+	m.ExecuteMdi("execute", fmt.Sprintf("G10 L1 P%d Z%.4f", tool.Number, length))
+	if tool.Number == m.ToolInSpindle() {
+		m.ExecuteMdi("execute", "G43")
+	}
+	return nil
+}
+
+// LoadTool tells the machine a tool was put in the spindle by hand, without
+// a tool change
+func (m *Machine) LoadTool(number int) {
+	m.ExecuteMdi("execute", fmt.Sprintf("M61 Q%d", number))
+}
@@ -0,0 +1,128 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adragomir/linuxcncgo/util"
+	pb "github.com/machinekit/machinetalk_protobuf_go"
+)
+
+// atToolChange is a machine with T1 in the spindle and T5 prepared, in a
+// program in the interpreter state given, with the M codes given active
+func atToolChange(interp pb.EmcInterpStateType, exec pb.EmcTaskExecStateType, mcodes ...int32) *Machine {
+	active := make([]*pb.EmcStatusMCode, len(mcodes))
+	for i, code := range mcodes {
+		active[i] = &pb.EmcStatusMCode{Index: util.I32(int32(i)), Value: util.I32(code)}
+	}
+	return &Machine{
+		TaskState: &pb.EmcStatusTask{
+			TaskMode:  pb.EmcTaskModeType_EMC_TASK_MODE_AUTO.Enum(),
+			ExecState: exec.Enum(),
+		},
+		InterpState: &pb.EmcStatusInterp{InterpState: interp.Enum(), Mcodes: active},
+		IoState: &pb.EmcStatusIo{
+			ToolInSpindle: util.I32(1),
+			PocketPrepped: util.I32(2),
+			ToolTable: []*pb.EmcToolData{
+				{Index: util.I32(0), Id: util.I32(1)},
+				{Index: util.I32(1), Id: util.I32(1), Pocket: util.I32(1)},
+				{Index: util.I32(2), Id: util.I32(5), Pocket: util.I32(5), Comment: util.S("6mm ball")},
+			},
+		},
+	}
+}
+
+func TestPendingToolChangeInProgram(t *testing.T) {
+	tests := []struct {
+		name    string
+		interp  pb.EmcInterpStateType
+		exec    pb.EmcTaskExecStateType
+		mcodes  []int32
+		pending bool
+		paused  bool
+	}{
+		{"paused by the M0 of the M6", pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED, pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE, []int32{0, 6}, true, true},
+		{"paused with the next tool selected", pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED, pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE, []int32{0}, false, true},
+		{"waiting for the IO", pb.EmcInterpStateType_EMC_TASK_INTERP_WAITING, pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_IO, nil, true, false},
+		{"waiting for motion and the IO", pb.EmcInterpStateType_EMC_TASK_INTERP_WAITING, pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_MOTION_AND_IO, nil, true, false},
+		{"running", pb.EmcInterpStateType_EMC_TASK_INTERP_READING, pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_MOTION, []int32{6}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := atToolChange(test.interp, test.exec, test.mcodes...)
+			tool, pending := m.PendingToolChange()
+			if pending != test.pending {
+				t.Fatalf("pending %v, want %v", pending, test.pending)
+			}
+			if pending && (tool.Number != 5 || tool.Comment != "6mm ball") {
+				t.Errorf("pending tool %+v, want T5", tool)
+			}
+			if !m.Running() {
+				t.Errorf("a program at a tool change is running")
+			}
+			if m.Paused() != test.paused {
+				t.Errorf("paused %v, want %v", m.Paused(), test.paused)
+			}
+		})
+	}
+}
+
+func TestPendingToolChangeLoaded(t *testing.T) {
+	m := atToolChange(pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED, pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE, 0, 6)
+	m.IoState.ToolInSpindle = util.I32(5)
+	if _, pending := m.PendingToolChange(); pending {
+		t.Errorf("the tool prepared is in the spindle, nothing to change")
+	}
+}
+
+func TestMdiBusyWhilePaused(t *testing.T) {
+	// the interpreter stays paused during the MDI, the task tells it runs
+	m := atToolChange(pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED, pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_MOTION)
+	if !m.mdiBusy() {
+		t.Errorf("a probe move of a paused program is not seen running")
+	}
+	m.TaskState.ExecState = pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE.Enum()
+	if m.mdiBusy() {
+		t.Errorf("a paused program with its MDI done is seen running")
+	}
+
+	m = atToolChange(pb.EmcInterpStateType_EMC_TASK_INTERP_IDLE, pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE)
+	m.TaskState.TaskMode = pb.EmcTaskModeType_EMC_TASK_MODE_MDI.Enum()
+	if m.mdiBusy() {
+		t.Errorf("an idle machine is seen running")
+	}
+	m.InterpState.InterpState = pb.EmcInterpStateType_EMC_TASK_INTERP_READING.Enum()
+	if !m.mdiBusy() {
+		t.Errorf("a MDI command is not seen running")
+	}
+}
+
+func TestToolLengthNeedsPause(t *testing.T) {
+	// refused before any command is sent
+	m := atToolChange(pb.EmcInterpStateType_EMC_TASK_INTERP_WAITING, pb.EmcTaskExecStateType_EMC_TASK_EXEC_WAITING_FOR_IO)
+	if err := m.SetToolLength(ToolEntry{Number: 5}, 42); err == nil {
+		t.Errorf("a tool length was set while the program runs")
+	}
+	if _, err := m.ProbeToolLength(context.Background(), ToolSetter{}); err == nil {
+		t.Errorf("a tool was measured while the program runs")
+	}
+	m = atToolChange(pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED, pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE, 0)
+	if _, err := m.ProbeToolLength(context.Background(), ToolSetter{}); err == nil {
+		t.Errorf("a tool was measured in a program paused outside of its M6")
+	}
+}
+
+func TestProgramModes(t *testing.T) {
+	m := atToolChange(pb.EmcInterpStateType_EMC_TASK_INTERP_PAUSED, pb.EmcTaskExecStateType_EMC_TASK_EXEC_DONE, 0, 6)
+	if modes := m.programModes(); modes != "G90" {
+		t.Errorf("modes %q, want G90", modes)
+	}
+	m.InterpState.Gcodes = []*pb.EmcStatusGCode{
+		{Index: util.I32(0), Value: util.I32(910)},
+		{Index: util.I32(1), Value: util.I32(430)},
+	}
+	if modes := m.programModes(); modes != "G91 G43" {
+		t.Errorf("modes %q, want G91 G43", modes)
+	}
+}
@@ -0,0 +1,211 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/adragomir/linuxcncgo/util"
+	pb "github.com/machinekit/machinetalk_protobuf_go"
+	"google.golang.org/protobuf/proto"
+)
+
+// ToolEntry is a tool of the tool table of the controller
+type ToolEntry struct {
+	Number   int
+	Pocket   int
+	Diameter float64
+	// length offset, along Z
+	Length  float64
+	Comment string
+
+	// as the controller sent it, for what is not edited here: the index in
+	// its table, the offsets of the other axes and the lathe angles
+	raw *pb.EmcToolData
+}
+
+// ToolTable is the tool table of the controller, in the order of its
+// indexes
+type ToolTable []ToolEntry
+
+// NewToolTable is the table the controller reports, without its empty
+// slots. The first slot is the tool in the spindle, a copy of its entry, and
+// is left out too
+func NewToolTable(in []*pb.EmcToolData) ToolTable {
+	sorted := append([]*pb.EmcToolData(nil), in...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetIndex() < sorted[j].GetIndex()
+	})
+	out := make(ToolTable, 0, len(sorted))
+	for _, tool := range sorted {
+		if tool.GetId() <= 0 {
+			continue
+		}
+		entry := ToolEntry{
+			Number:   int(tool.GetId()),
+			Pocket:   int(tool.GetPocket()),
+			Diameter: tool.GetDiameter(),
+			Length:   tool.GetOffset().GetZ(),
+			Comment:  tool.GetComment(),
+			raw:      tool,
+		}
+		if i, ok := out.Find(entry.Number); ok {
+			out[i] = entry
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// Clone is a copy of the table that can be edited
+func (t ToolTable) Clone() ToolTable {
+	return append(ToolTable(nil), t...)
+}
+
+// Find is the position in the table of a tool by its number
+func (t ToolTable) Find(number int) (int, bool) {
+	for i, tool := range t {
+		if tool.Number == number {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Equal is true if both tables have the same tools with the same values
+func (t ToolTable) Equal(other ToolTable) bool {
+	if len(t) != len(other) {
+		return false
+	}
+	for i := range t {
+		a, b := t[i], other[i]
+		if a.Number != b.Number || a.Pocket != b.Pocket || a.Diameter != b.Diameter || a.Length != b.Length || a.Comment != b.Comment {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the numbers and pockets are positive and unique
+func (t ToolTable) Validate() error {
+	numbers := make(map[int]bool)
+	pockets := make(map[int]bool)
+	for _, tool := range t {
+		if tool.Number <= 0 {
+			return errors.New("tool numbers must be positive")
+		}
+		if tool.Pocket <= 0 {
+			return errors.New("pockets must be positive")
+		}
+		if tool.Diameter < 0 {
+			return errors.New("diameters cannot be negative")
+		}
+		if numbers[tool.Number] {
+			return errors.New("tool numbers must be unique")
+		}
+		if pockets[tool.Pocket] {
+			return errors.New("pockets must be unique")
+		}
+		numbers[tool.Number] = true
+		pockets[tool.Pocket] = true
+	}
+	return nil
+}
+
+// toolData is the entry as the controller takes it, index is its position
+// in the table
+func (e ToolEntry) toolData(index int) *pb.EmcToolData {
+	out := &pb.EmcToolData{}
+	if e.raw != nil {
+		out = proto.Clone(e.raw).(*pb.EmcToolData)
+	}
+	if out.Offset == nil {
+		out.Offset = &pb.Position{}
+	}
+	out.Index = util.I32(int32(index))
+	out.Id = util.I32(int32(e.Number))
+	out.Pocket = util.I32(int32(e.Pocket))
+	out.Diameter = util.F64(e.Diameter)
+	out.Offset.Z = util.F64(e.Length)
+	out.Comment = util.S(e.Comment)
+	return out
+}
+
+// GetToolTable is the tool table as the controller reports it
+func (m *Machine) GetToolTable() ToolTable {
+	if m.IoState == nil {
+		return ToolTable{}
+	}
+	return NewToolTable(m.IoState.GetToolTable())
+}
+
+// ToolInSpindle is the number of the tool in the spindle, 0 if none
+func (m *Machine) ToolInSpindle() int {
+	if m.IoState == nil {
+		return 0
+	}
+	return int(m.IoState.GetToolInSpindle())
+}
+
+// UpdateToolTable replaces the tool table of the controller, which saves it
+// to its tool table file and loads it
+func (m *Machine) UpdateToolTable(table ToolTable) error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+	if m.Running() {
+		return errors.New("cannot change the tool table while running")
+	}
+	tools := make([]*pb.EmcToolData, 0, len(table))
+	for i, tool := range table {
+		// after the slot of the spindle
+		tools = append(tools, tool.toolData(i+1))
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{
+			ToolTable: tools,
+		},
+	}
+	m.command.SendEmcToolUpdateToolTable(msg)
+	return nil
+}
+
+// toolIndex is the index of a tool in the table of the controller, past the
+// slot of the spindle, as NewToolTable keeps it
+func (m *Machine) toolIndex(number int) (int, bool) {
+	if m.IoState == nil {
+		return 0, false
+	}
+	index, ok := 0, false
+	for _, tool := range m.IoState.GetToolTable() {
+		if int(tool.GetId()) == number && tool.GetIndex() > 0 {
+			index, ok = int(tool.GetIndex()), true
+		}
+	}
+	return index, ok
+}
+
+// SetToolOffset sets the length and the diameter of a tool of the table of
+// the controller, found by its number
+func (m *Machine) SetToolOffset(tool ToolEntry) error {
+	index, ok := m.toolIndex(tool.Number)
+	if !ok {
+		return fmt.Errorf("tool %d is not in the tool table of the controller", tool.Number)
+	}
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{
+			ToolData: tool.toolData(index),
+		},
+	}
+	m.command.SendEmcToolSetOffset(msg)
+	return nil
+}
+
+// ReloadToolTable makes the controller load its tool table file again
+func (m *Machine) ReloadToolTable() {
+	msg := &pb.Container{
+		EmcCommandParams: &pb.EmcCommandParameters{},
+	}
+	m.command.SendEmcToolLoadToolTable(msg)
+}
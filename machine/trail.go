@@ -0,0 +1,76 @@
+package machine
+
+import (
+	"math"
+	"sync"
+)
+
+// positions kept in the trail of the machine
+const trailCapacity = 100000
+
+// a position closer than this to the last one kept is dropped, in machine
+// units
+const trailMinDistance = 0.01
+
+// PositionTrail is the recent history of the actual position of the
+// machine. Positions that barely moved are dropped, and the oldest ones are
+// overwritten once the trail is full
+type PositionTrail struct {
+	lock        sync.RWMutex
+	points      [][3]float64
+	start       int
+	minDistance float64
+	// changes every time a position is kept
+	version int
+}
+
+func NewPositionTrail(capacity int, minDistance float64) *PositionTrail {
+	return &PositionTrail{
+		points:      make([][3]float64, 0, capacity),
+		minDistance: minDistance,
+	}
+}
+
+// Push adds a position, unless it is too close to the last one
+func (t *PositionTrail) Push(p [3]float64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if n := len(t.points); n > 0 {
+		last := t.points[(t.start+n-1)%n]
+		dx, dy, dz := p[0]-last[0], p[1]-last[1], p[2]-last[2]
+		if math.Sqrt(dx*dx+dy*dy+dz*dz) < t.minDistance {
+			return
+		}
+	}
+	if len(t.points) < cap(t.points) {
+		t.points = append(t.points, p)
+	} else {
+		t.points[t.start] = p
+		t.start = (t.start + 1) % len(t.points)
+	}
+	t.version++
+}
+
+// Points are the positions kept, oldest first
+func (t *PositionTrail) Points() [][3]float64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	out := make([][3]float64, 0, len(t.points))
+	out = append(out, t.points[t.start:]...)
+	return append(out, t.points[:t.start]...)
+}
+
+// Version changes every time the trail changes
+func (t *PositionTrail) Version() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.version
+}
+
+func (t *PositionTrail) Clear() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.points = t.points[:0]
+	t.start = 0
+	t.version++
+}
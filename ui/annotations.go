@@ -0,0 +1,161 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/adragomir/linuxcncgo/gcode"
+	"github.com/adragomir/linuxcncgo/ui/glutil"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// about this many grid cells along the longest side of the program
+const gridCells = 10
+
+// every this many grid lines is a major one, labeled along the edges
+const gridMajorEvery = 5
+
+// height of the labels of the preview, in pixels
+const (
+	rulerLabelSize     = 13
+	dimensionLabelSize = 15
+	triadLabelSize     = 14
+)
+
+// gridStep is a round spacing of the grid, 1, 2 or 5 times a power of ten,
+// for about gridCells cells over size
+func gridStep(size float32) float32 {
+	if size <= 0 {
+		return 1
+	}
+	raw := float64(size) / gridCells
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*magnitude {
+			return float32(m * magnitude)
+		}
+	}
+	return float32(10 * magnitude)
+}
+
+// appendLine appends a line between two points in machine coordinates
+func appendLine(out []float32, from, to mgl32.Vec3, lineType float32) []float32 {
+	a, b := gcode.PreviewPoint(from), gcode.PreviewPoint(to)
+	return append(out,
+		a[0], a[1], a[2], lineType, 0,
+		b[0], b[1], b[2], lineType, 0,
+	)
+}
+
+// formatLength is a length with its units, as many decimals as the units
+// are usually given with
+func formatLength(length float32, units gcode.Units) string {
+	if units == gcode.UnitsInch {
+		return fmt.Sprintf("%.4f %s", length, units)
+	}
+	return fmt.Sprintf("%.3f %s", length, units)
+}
+
+// gridLines is a grid on the XY plane under the program, around it, and
+// the labels of its major lines along the front and the left edges
+func gridLines(bbox *glutil.BoundingBox, units gcode.Units) ([]float32, []glutil.Label) {
+	min, max := bbox.Min(), bbox.Max()
+	step := gridStep(mgl32.Max(max[0]-min[0], max[1]-min[1]))
+	first := func(v float32) int { return int(math.Floor(float64(v/step))) - 1 }
+	last := func(v float32) int { return int(math.Ceil(float64(v/step))) + 1 }
+	x0, x1 := first(min[0]), last(max[0])
+	y0, y1 := first(min[1]), last(max[1])
+	z := min[2]
+
+	vertices := make([]float32, 0)
+	labels := make([]glutil.Label, 0)
+	lineType := func(i int) float32 {
+		if i%gridMajorEvery == 0 {
+			return lineGridMajor
+		}
+		return lineGrid
+	}
+	for i := x0; i <= x1; i++ {
+		x := float32(i) * step
+		vertices = appendLine(vertices, mgl32.Vec3{x, float32(y0) * step, z}, mgl32.Vec3{x, float32(y1) * step, z}, lineType(i))
+		if i%gridMajorEvery == 0 {
+			labels = append(labels, glutil.Label{
+				Text:     fmt.Sprintf("%g", x),
+				Position: gcode.PreviewPoint(mgl32.Vec3{x, float32(y0) * step, z}),
+				Size:     rulerLabelSize,
+				Centered: true,
+			})
+		}
+	}
+	for i := y0; i <= y1; i++ {
+		y := float32(i) * step
+		vertices = appendLine(vertices, mgl32.Vec3{float32(x0) * step, y, z}, mgl32.Vec3{float32(x1) * step, y, z}, lineType(i))
+		if i%gridMajorEvery == 0 {
+			labels = append(labels, glutil.Label{
+				Text:     fmt.Sprintf("%g", y),
+				Position: gcode.PreviewPoint(mgl32.Vec3{float32(x0) * step, y, z}),
+				Size:     rulerLabelSize,
+				Centered: true,
+			})
+		}
+	}
+	labels = append(labels, glutil.Label{
+		Text:     fmt.Sprintf("grid %s", formatLength(step, units)),
+		Position: gcode.PreviewPoint(mgl32.Vec3{float32(x1) * step, float32(y0) * step, z}),
+		Size:     rulerLabelSize,
+	})
+	return vertices, labels
+}
+
+// dimensionLines are the extents of the program along X, Y and Z, drawn
+// outside of it with extension lines and labeled with their lengths
+func dimensionLines(bbox *glutil.BoundingBox, units gcode.Units) ([]float32, []glutil.Label) {
+	min, max := bbox.Min(), bbox.Max()
+	gap := gridStep(mgl32.Max(max[0]-min[0], max[1]-min[1]))
+	vertices := make([]float32, 0)
+	labels := make([]glutil.Label, 0)
+
+	// dimension draws the line between from and to, pushed away by offset,
+	// with extension lines back to the measured points
+	dimension := func(from, to, offset mgl32.Vec3, length float32) {
+		a, b := from.Add(offset), to.Add(offset)
+		overshoot := offset.Normalize().Mul(gap / 4)
+		vertices = appendLine(vertices, a, b, lineDimension)
+		vertices = appendLine(vertices, from, a.Add(overshoot), lineDimension)
+		vertices = appendLine(vertices, to, b.Add(overshoot), lineDimension)
+		labels = append(labels, glutil.Label{
+			Text:     formatLength(length, units),
+			Position: gcode.PreviewPoint(a.Add(b).Mul(0.5).Add(overshoot.Mul(2))),
+			Size:     dimensionLabelSize,
+			Centered: true,
+		})
+	}
+	if max[0] > min[0] {
+		dimension(mgl32.Vec3{min[0], min[1], min[2]}, mgl32.Vec3{max[0], min[1], min[2]}, mgl32.Vec3{0, -gap, 0}, max[0]-min[0])
+	}
+	if max[1] > min[1] {
+		dimension(mgl32.Vec3{max[0], min[1], min[2]}, mgl32.Vec3{max[0], max[1], min[2]}, mgl32.Vec3{gap, 0, 0}, max[1]-min[1])
+	}
+	if max[2] > min[2] {
+		dimension(mgl32.Vec3{min[0], max[1], min[2]}, mgl32.Vec3{min[0], max[1], max[2]}, mgl32.Vec3{-gap, gap, 0}, max[2]-min[2])
+	}
+	return vertices, labels
+}
+
+// triadLines are the X, Y and Z axes of unit length at the origin, for the
+// corner of the preview
+func triadLines() ([]float32, []glutil.Label) {
+	vertices := originGizmo(make([]float32, 0), mgl32.Vec3{}, 1)
+	labels := make([]glutil.Label, 0, len(gcode.ENVELOPE_AXES))
+	for axis, name := range gcode.ENVELOPE_AXES {
+		d := mgl32.Vec3{}
+		d[axis] = 1.3
+		labels = append(labels, glutil.Label{
+			Text:     name,
+			Position: gcode.PreviewPoint(d),
+			Size:     triadLabelSize,
+			Centered: true,
+		})
+	}
+	return vertices, labels
+}
@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// taskProgress is the fraction of a background task done, set by the task
+// and read by the frame loop
+type taskProgress struct {
+	bits uint32
+}
+
+func (p *taskProgress) Set(v float32) {
+	atomic.StoreUint32(&p.bits, math.Float32bits(v))
+}
+
+func (p *taskProgress) Get() float32 {
+	return math.Float32frombits(atomic.LoadUint32(&p.bits))
+}
+
+// post hands the result of a background task to the frame loop, apply runs
+// there before the next layout. The state of the ui is only changed there
+func (ui *Ui) post(apply func()) {
+	ui.results <- apply
+}
+
+// applyResults runs the results posted since the last frame
+func (ui *Ui) applyResults() {
+	for {
+		select {
+		case apply := <-ui.results:
+			apply()
+		default:
+			return
+		}
+	}
+}
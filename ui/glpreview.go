@@ -2,9 +2,14 @@ package ui
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"math"
+	"sort"
 	"sync"
 
+	"github.com/adragomir/linuxcncgo/fonts"
+	"github.com/adragomir/linuxcncgo/gcode"
 	"github.com/adragomir/linuxcncgo/ui/glutil"
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -23,14 +28,59 @@ type GlPreview struct {
 	cameraChanged bool
 
 	// draw state
-	lock          sync.RWMutex
-	hasModel      bool
-	modelVertices []float32
-	modelBbox     *glutil.BoundingBox
+	lock      sync.RWMutex
+	hasModel  bool
+	path      lineBuffer
+	modelBbox *glutil.BoundingBox
+
+	// playback: the path run before playTime is drawn as done and the tool
+	// is drawn where it is at playTime. Off if playTime < 0
+	playTime    float32
+	playTool    gcode.Tool
+	playChanged bool
+	playback    lineBuffer
+
+	// actual positions of the machine, drawn over the path
+	trail lineBuffer
+
+	// tool and spindle at the actual position of the machine, machine and
+	// work origins
+	machinePos, workOrigin mgl32.Vec3
+	machineTool            gcode.Tool
+	machine                lineBuffer
+
+	// pieces of the path past the soft limits of the machine
+	limits lineBuffer
+
+	// picking a line of the program by clicking its path: the simulated path
+	// by line and the 0-based line picked, -1 if none
+	segmentMap  []gcode.SimMap
+	pressPos    [2]float64
+	pickedLine  int
+	pickChanged bool
+	picked      lineBuffer
+
+	// stock left after the program ran
+	stock         *glutil.Mesh
+	stockVertices []float32
+	stockIndices  []uint32
+	stockChanged  bool
+
+	// grid under the program, its extents and the axes in the corner, labeled
+	// in the units of the machine
+	units            gcode.Units
+	annotations      lineBuffer
+	annotationLabels []glutil.Label
+	labelsChanged    bool
+	labels           *glutil.TextRenderer
+	triad            lineBuffer
+	triadLabels      *glutil.TextRenderer
 
 	// uniforms, camera
 	camera        *glutil.Camera //mgl32.Mat4
 	cameraControl *glutil.CameraControl
+	animation     *viewAnimation
+	orthographic  bool
 
 	model mgl32.Mat4
 
@@ -46,10 +96,12 @@ func (p *GlPreview) buildPrograms() error {
 
 	in vec3 vert;
 	in float inLineType;
+	in float inTime;
 
 	flat out vec3 startPos;
 	out vec3 vertPos;
 	out float lineType; 
+	out float vertTime;
 
 	void main() {
 		vec4 pos = projection * view * model * vec4(vert.xyz, 1.0);
@@ -57,21 +109,25 @@ func (p *GlPreview) buildPrograms() error {
 		vertPos = pos.xyz / pos.w;
 		startPos = vertPos;
 		lineType = inLineType;
+		vertTime = inTime;
 	}
 	` + "\x00"
 
-	var fragmentShader = `
+	var fragmentShader = fmt.Sprintf(`
 	#version 330
 
 	flat in vec3 startPos;
 	in vec3 vertPos;
 	in float lineType;
+	in float vertTime;
 
 	out vec4 outputColor;
 
 	uniform vec2 resolution;
 	uniform float dashSize;
 	uniform float gapSize;
+	uniform float playTime;
+	uniform vec4 colors[%d];
 
 	void main() {
     	// vec2  dir  = (vertPos.xy-startPos.xy) * resolution/2.0;
@@ -79,13 +135,13 @@ func (p *GlPreview) buildPrograms() error {
 
     	// if (fract(dist / (dashSize + gapSize)) > dashSize/(dashSize + gapSize))
         	// discard; 
-        if (lineType < 0.5) {
-			outputColor = vec4(1.0, 0.0, 0.0, 1.0);
-        } else {
-			outputColor = vec4(0.0, 1.0, 0.0, 1.0);
-        }
+		int t = int(lineType + 0.5);
+		if (t <= %d && playTime >= 0.0 && vertTime <= playTime) {
+			t = %d;
+		}
+		outputColor = colors[t];
 	}
-	` + "\x00"
+	`, lineTypes, lineRapid, lineDone) + "\x00"
 
 	// Configure the vertex and fragment shaders
 	shader, err := glutil.NewShaderString(vertexShader, fragmentShader, "")
@@ -112,39 +168,352 @@ func (p *GlPreview) InitGL() error {
 
 	gl.BindFragDataLocation(p.Shader.Program, 0, gl.Str("outputColor\x00"))
 
-	// Configure the vertex data
-	gl.GenVertexArrays(1, &p.Vao)
-	gl.GenBuffers(1, &p.Vbo)
+	gl.Uniform4fv(p.Shader.Uniforms["colors[0]"], lineTypes, &LINE_COLORS[0][0])
+
+	p.path.init(p, gl.LINE_STRIP)
+	p.playback.init(p, gl.LINES)
+	p.trail.init(p, gl.LINE_STRIP)
+	p.machine.init(p, gl.LINES)
+	p.limits.init(p, gl.LINES)
+	p.picked.init(p, gl.LINES)
+	p.annotations.init(p, gl.LINES)
+	p.triad.init(p, gl.LINES)
+
+	p.stock, err = glutil.NewMesh(mgl32.Vec4{0.55, 0.6, 0.65, 1})
+	if err != nil {
+		log.Printf("Error building stock shader: %+v", err)
+		return err
+	}
+
+	triadVertices, triadLabels := triadLines()
+	p.triad.set(triadVertices)
+
+	font := glutil.NewFont(fonts.GoMonoJson, fonts.GoMonoImage)
+	p.labels, err = glutil.NewTextRenderer(font, mgl32.Vec4{0.9, 0.9, 0.9, 1})
+	if err != nil {
+		log.Printf("Error building label shader: %+v", err)
+		return err
+	}
+	p.triadLabels, err = glutil.NewTextRenderer(font, mgl32.Vec4{0.9, 0.9, 0.9, 1})
+	if err != nil {
+		log.Printf("Error building label shader: %+v", err)
+		return err
+	}
+	p.triadLabels.SetLabels(triadLabels)
 
-	gl.BindVertexArray(p.Vao)
-	gl.BindBuffer(gl.ARRAY_BUFFER, p.Vbo)
+	p.playTime = -1
+	p.pickedLine = -1
+	return nil
+}
 
+func (p *GlPreview) setupAttributes() {
+	stride := int32(gcode.VertexFloats * 4)
 	gl.EnableVertexAttribArray(p.Shader.Attributes["vert"])
-	gl.VertexAttribPointer(p.Shader.Attributes["vert"], 3, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+	gl.VertexAttribPointer(p.Shader.Attributes["vert"], 3, gl.FLOAT, false, stride, gl.PtrOffset(0))
 
 	gl.EnableVertexAttribArray(p.Shader.Attributes["inLineType"])
-	gl.VertexAttribPointer(p.Shader.Attributes["inLineType"], 1, gl.FLOAT, false, 4*4, gl.PtrOffset(3*4))
+	gl.VertexAttribPointer(p.Shader.Attributes["inLineType"], 1, gl.FLOAT, false, stride, gl.PtrOffset(3*4))
 
-	return nil
+	gl.EnableVertexAttribArray(p.Shader.Attributes["inTime"])
+	gl.VertexAttribPointer(p.Shader.Attributes["inTime"], 1, gl.FLOAT, false, stride, gl.PtrOffset(4*4))
+}
+
+// lineBuffer are vertices drawn with the shader of the preview. They are
+// set with the lock held and uploaded when drawn next
+type lineBuffer struct {
+	vao, vbo uint32
+	mode     uint32
+	vertices []float32
+	changed  bool
+}
+
+// init builds the vertex array, drawn as mode
+func (b *lineBuffer) init(p *GlPreview, mode uint32) {
+	b.mode = mode
+	gl.GenVertexArrays(1, &b.vao)
+	gl.GenBuffers(1, &b.vbo)
+	gl.BindVertexArray(b.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+	p.setupAttributes()
+	gl.BindVertexArray(0)
+}
+
+func (b *lineBuffer) set(vertices []float32) {
+	b.vertices = vertices
+	b.changed = true
+}
+
+// draw uploads the vertices if they changed and draws them
+func (b *lineBuffer) draw() {
+	if len(b.vertices) == 0 {
+		return
+	}
+	gl.BindVertexArray(b.vao)
+	if b.changed {
+		gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+		gl.BufferData(gl.ARRAY_BUFFER, len(b.vertices)*4, gl.Ptr(b.vertices), gl.DYNAMIC_DRAW)
+		b.changed = false
+	}
+	gl.DrawArrays(b.mode, 0, int32(len(b.vertices)/gcode.VertexFloats))
 }
 
 func (p *GlPreview) NoData() {
-	p.modelVertices = nil
+	p.lock.Lock()
+	p.path.set(nil)
 	p.modelBbox = nil
 	p.hasModel = false
+	p.lock.Unlock()
+	p.SetPlayTime(-1, gcode.Tool{})
+	p.SetStock(nil, nil)
+	p.SetOutOfLimits(nil)
+	p.SetSegmentMap(nil)
 }
 
 func (p *GlPreview) SetData(vertices []float32, bbox *glutil.BoundingBox) {
 	p.lock.Lock()
-	p.modelVertices = vertices
+	p.path.set(vertices)
 	p.modelBbox = bbox
 	p.modelChanged = true
 	p.hasModel = true
+	// sized after the model
+	p.machine.set(nil)
+	p.buildAnnotations()
 	p.lock.Unlock()
 	p.cameraChanged = true
 	p.sizeChanged = true
 }
 
+// SetUnits sets the units the lengths of the preview are labeled in, the
+// linear units of the machine
+func (p *GlPreview) SetUnits(units gcode.Units) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if units == p.units {
+		return
+	}
+	p.units = units
+	p.buildAnnotations()
+}
+
+// buildAnnotations builds the grid and the dimensions around the program,
+// with the lock held
+func (p *GlPreview) buildAnnotations() {
+	p.annotations.set(nil)
+	p.annotationLabels = nil
+	p.labelsChanged = true
+	if p.modelBbox == nil {
+		return
+	}
+	grid, gridLabels := gridLines(p.modelBbox, p.units)
+	dimensions, dimensionLabels := dimensionLines(p.modelBbox, p.units)
+	p.annotations.set(append(grid, dimensions...))
+	p.annotationLabels = append(gridLabels, dimensionLabels...)
+}
+
+// SetPlayTime shows the program as run until t seconds with tool in the
+// spindle, a negative t turns the playback off
+func (p *GlPreview) SetPlayTime(t float32, tool gcode.Tool) {
+	if t == p.playTime && tool == p.playTool {
+		return
+	}
+	p.playTime, p.playTool = t, tool
+	p.playChanged = true
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if t < 0 {
+		p.playback.set(nil)
+		return
+	}
+	pos := gcode.MachinePoint(p.positionAt(t))
+	p.playback.set(toolOutline(pos, tool, p.toolSize()/2, linePlayback))
+}
+
+// toolSize scales the tool and the origins after the model, with the lock
+// held
+func (p *GlPreview) toolSize() float32 {
+	if p.modelBbox == nil {
+		return 10
+	}
+	return mgl32.Max(p.modelBbox.SphereRadius()/10, 1)
+}
+
+// SetTrail sets the actual positions of the machine, in machine coordinates
+func (p *GlPreview) SetTrail(points [][3]float64) {
+	vertices := make([]float32, 0, len(points)*gcode.VertexFloats)
+	for _, point := range points {
+		v := gcode.PreviewPoint(mgl32.Vec3{float32(point[0]), float32(point[1]), float32(point[2])})
+		vertices = append(vertices, v[0], v[1], v[2], lineTrail, 0)
+	}
+	p.lock.Lock()
+	p.trail.set(vertices)
+	p.lock.Unlock()
+}
+
+// SetMachine places the tool in the spindle at the actual position of the
+// machine and the work origin, both in machine coordinates
+func (p *GlPreview) SetMachine(pos mgl32.Vec3, tool gcode.Tool, workOrigin mgl32.Vec3) {
+	p.lock.RLock()
+	built := p.machine.vertices != nil
+	size := p.toolSize()
+	p.lock.RUnlock()
+	if pos == p.machinePos && tool == p.machineTool && workOrigin == p.workOrigin && built {
+		return
+	}
+	p.machinePos, p.machineTool, p.workOrigin = pos, tool, workOrigin
+	vertices := toolOutline(pos, tool, size/2, lineTool)
+	vertices = originGizmo(vertices, mgl32.Vec3{}, size)
+	vertices = originGizmo(vertices, workOrigin, size/2)
+	p.lock.Lock()
+	p.machine.set(vertices)
+	p.lock.Unlock()
+}
+
+// SetOutOfLimits highlights the pieces of the path past the soft limits, in
+// machine coordinates
+func (p *GlPreview) SetOutOfLimits(segments [][2]mgl32.Vec3) {
+	vertices := make([]float32, 0, len(segments)*2*gcode.VertexFloats)
+	for _, segment := range segments {
+		for _, point := range segment {
+			v := gcode.PreviewPoint(point)
+			vertices = append(vertices, v[0], v[1], v[2], lineOutOfLimits, 0)
+		}
+	}
+	p.lock.Lock()
+	p.limits.set(vertices)
+	p.lock.Unlock()
+}
+
+// SetSegmentMap sets the simulated path of every line, for picking
+func (p *GlPreview) SetSegmentMap(segments []gcode.SimMap) {
+	p.lock.Lock()
+	p.segmentMap = segments
+	p.lock.Unlock()
+	p.SetPickedLine(-1)
+}
+
+// SetPickedLine highlights the path of a 0-based line, -1 highlights none
+func (p *GlPreview) SetPickedLine(lineNo int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.pickedLine = lineNo
+	p.pickChanged = true
+	var vertices []float32
+	for _, sm := range p.segmentMap {
+		if sm.LineNo != lineNo {
+			continue
+		}
+		for i := 1; i < len(sm.Segments); i++ {
+			for _, point := range sm.Segments[i-1 : i+1] {
+				v := gcode.PreviewPoint(point)
+				vertices = append(vertices, v[0], v[1], v[2], linePicked, 0)
+			}
+		}
+	}
+	p.picked.set(vertices)
+}
+
+// PickedLine is the 0-based line picked, -1 if none, and if it changed since
+// the last call
+func (p *GlPreview) PickedLine() (int, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	changed := p.pickChanged
+	p.pickChanged = false
+	return p.pickedLine, changed
+}
+
+// furthest a click picks a segment from, in pixels
+const pickDistance = 8
+
+// pick finds the line of the segment nearest to a click at x, y in the
+// window, on screen: the segments are projected with the matrices of the
+// camera, the nearest to the camera wins between segments as close to the
+// click
+func (p *GlPreview) pick(x, y float32) {
+	if p.camera == nil {
+		return
+	}
+	mvp := p.camera.ProjMatrix().Mul4(*p.camera.ViewMatrix()).Mul4(p.model)
+	click := mgl32.Vec2{x - p.Bounds[0], y - p.Bounds[2]}
+	toScreen := func(point mgl32.Vec3) (mgl32.Vec3, bool) {
+		clip := mvp.Mul4x1(gcode.PreviewPoint(point).Vec4(1))
+		if clip[3] <= 0 {
+			// behind the camera
+			return mgl32.Vec3{}, false
+		}
+		ndc := clip.Vec3().Mul(1 / clip[3])
+		return mgl32.Vec3{(ndc[0] + 1) / 2 * p.Size[0], (1 - ndc[1]) / 2 * p.Size[1], ndc[2]}, true
+	}
+
+	p.lock.RLock()
+	line := -1
+	bestDistance, bestDepth := float32(pickDistance), float32(1)
+	for _, sm := range p.segmentMap {
+		var from mgl32.Vec3
+		fromOk := false
+		for i, point := range sm.Segments {
+			to, toOk := toScreen(point)
+			if i > 0 && fromOk && toOk {
+				d := to.Vec2().Sub(from.Vec2())
+				t := float32(0)
+				if l2 := d.Dot(d); l2 > 0 {
+					t = mgl32.Clamp(click.Sub(from.Vec2()).Dot(d)/l2, 0, 1)
+				}
+				distance := click.Sub(from.Vec2().Add(d.Mul(t))).Len()
+				depth := from[2] + (to[2]-from[2])*t
+				if distance <= pickDistance && (distance < bestDistance-1 || (distance <= bestDistance+1 && depth < bestDepth)) {
+					line, bestDistance, bestDepth = sm.LineNo, mgl32.Min(distance, bestDistance), depth
+				}
+			}
+			from, fromOk = to, toOk
+		}
+	}
+	p.lock.RUnlock()
+	p.SetPickedLine(line)
+}
+
+// SetStock sets the mesh of the stock, in the coordinates of the preview.
+// No vertices remove it
+func (p *GlPreview) SetStock(vertices []float32, indices []uint32) {
+	p.lock.Lock()
+	p.stockVertices = vertices
+	p.stockIndices = indices
+	p.stockChanged = true
+	p.lock.Unlock()
+}
+
+// positionAt is the point of the path the tool is at at time t, between the
+// vertices around t
+func (p *GlPreview) positionAt(t float32) mgl32.Vec3 {
+	stride := gcode.VertexFloats
+	count := len(p.path.vertices) / stride
+	if count == 0 {
+		return mgl32.Vec3{}
+	}
+	vertex := func(i int) (mgl32.Vec3, float32) {
+		v := p.path.vertices[i*stride : (i+1)*stride]
+		return mgl32.Vec3{v[0], v[1], v[2]}, v[4]
+	}
+	i := sort.Search(count, func(i int) bool {
+		_, vt := vertex(i)
+		return vt > t
+	})
+	if i == 0 {
+		pos, _ := vertex(0)
+		return pos
+	}
+	if i == count {
+		pos, _ := vertex(count - 1)
+		return pos
+	}
+	from, fromTime := vertex(i - 1)
+	to, toTime := vertex(i)
+	if toTime <= fromTime {
+		return to
+	}
+	return from.Add(to.Sub(from).Mul((t - fromTime) / (toTime - fromTime)))
+}
+
 func (p *GlPreview) Reshape(pos imgui.Vec2, size imgui.Vec2) {
 	if p.Size[0] != size.X || p.Size[1] != size.Y {
 		p.Size = [2]float32{size.X, size.Y}
@@ -163,34 +532,14 @@ func (p *GlPreview) Draw() {
 	if !p.hasModel {
 		return
 	}
-	cameraChanged := false
 	if p.modelChanged {
 		// move model to origin
 		// FIXME: we should have the cnc table box there
-		translate := mgl32.Vec3{0, 0, 0}.Sub(p.modelBbox.Center())
+		translate := mgl32.Vec3{0, 0, 0}.Sub(gcode.PreviewPoint(p.modelBbox.Center()))
 		p.model = mgl32.Translate3D(translate[0], translate[1], translate[2])
+		p.resetCamera()
 
-		radius := p.modelBbox.SphereRadius()
-
-		distance := radius/mgl32.Sin(mgl32.DegToRad(45.0)) + 30
-
-		p.camera = glutil.NewPerspective(60, 1, 0.1, 1000)
-		p.camera.SetPosition(mgl32.Vec3{0, 10, distance})
-		p.camera.LookAt(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
-
-		p.cameraControl = glutil.NewCameraControl(p.camera)
-		p.cameraControl.SetTarget(mgl32.Vec3{0, 0, 0})
-		p.camera.SetAspect(p.Size[0] / p.Size[1])
-		p.cameraControl.SetAreaSize(p.Size[0], p.Size[1])
-
-		cameraChanged = true
-
-		// bind buffers
-		gl.BindVertexArray(p.Vao)
-		gl.BindBuffer(gl.ARRAY_BUFFER, p.Vbo)
-		p.lock.RLock()
-		gl.BufferData(gl.ARRAY_BUFFER, len(p.modelVertices)*4, gl.Ptr(p.modelVertices), gl.DYNAMIC_DRAW)
-		p.lock.RUnlock()
+		p.cameraChanged = true
 	}
 
 	if p.sizeChanged {
@@ -201,19 +550,22 @@ func (p *GlPreview) Draw() {
 		p.Rbo = rbo
 		p.camera.SetAspect(p.Size[0] / p.Size[1])
 		p.cameraControl.SetAreaSize(p.Size[0], p.Size[1])
-		cameraChanged = true
+		p.cameraChanged = true
 	}
 
 	// Here, we have a framebuffer
 	gl.BindFramebuffer(gl.FRAMEBUFFER, p.Fbo)
 
+	p.animate()
+
+	// set every frame, the axes in the corner are drawn with others
 	gl.UseProgram(p.Shader.Program)
-	if cameraChanged || p.cameraChanged {
-		gl.UniformMatrix4fv(p.Shader.Uniforms["projection"], 1, false, p.camera.ProjMatrixGl())
-		gl.UniformMatrix4fv(p.Shader.Uniforms["view"], 1, false, p.camera.ViewMatrixGl())
-	}
-	if p.modelChanged {
-		gl.UniformMatrix4fv(p.Shader.Uniforms["model"], 1, false, &p.model[0])
+	gl.UniformMatrix4fv(p.Shader.Uniforms["projection"], 1, false, p.camera.ProjMatrixGl())
+	gl.UniformMatrix4fv(p.Shader.Uniforms["view"], 1, false, p.camera.ViewMatrixGl())
+	gl.UniformMatrix4fv(p.Shader.Uniforms["model"], 1, false, &p.model[0])
+	if p.playChanged {
+		gl.Uniform1f(p.Shader.Uniforms["playTime"], p.playTime)
+		p.playChanged = false
 	}
 	p.modelChanged = false
 	p.cameraChanged = false
@@ -228,10 +580,39 @@ func (p *GlPreview) Draw() {
 	gl.Enable(gl.DEPTH_TEST)
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
-	gl.BindVertexArray(p.Vao)
-	p.lock.RLock()
-	gl.DrawArrays(gl.LINE_STRIP, 0, int32(len(p.modelVertices)/4))
-	p.lock.RUnlock()
+	p.lock.Lock()
+	p.annotations.draw()
+	if p.labelsChanged {
+		p.labels.SetLabels(p.annotationLabels)
+		p.labelsChanged = false
+	}
+	if p.stockChanged {
+		p.stock.SetData(p.stockVertices, p.stockIndices)
+		p.stockChanged = false
+	}
+	p.lock.Unlock()
+	if !p.stock.Empty() {
+		// behind the path cut on its surface
+		gl.Enable(gl.POLYGON_OFFSET_FILL)
+		gl.PolygonOffset(1, 1)
+		p.stock.Draw(p.camera.ProjMatrix(), p.camera.ViewMatrix(), &p.model)
+		gl.Disable(gl.POLYGON_OFFSET_FILL)
+		gl.UseProgram(p.Shader.Program)
+	}
+
+	p.lock.Lock()
+	p.path.draw()
+	p.trail.draw()
+	p.machine.draw()
+	// over the rest
+	gl.Disable(gl.DEPTH_TEST)
+	p.limits.draw()
+	p.picked.draw()
+	p.playback.draw()
+	p.lock.Unlock()
+
+	p.labels.Draw(p.camera.ProjMatrix(), p.camera.ViewMatrix(), &p.model, p.Size)
+	p.drawTriad()
 
 	gl.Disable(gl.DEPTH_TEST)
 	gl.Disable(gl.SCISSOR_TEST)
@@ -239,6 +620,29 @@ func (p *GlPreview) Draw() {
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 }
 
+// size of the axes in the corner of the preview, in pixels
+const triadSize = 90
+
+// drawTriad draws the axes in the bottom left corner, turned as the camera
+func (p *GlPreview) drawTriad() {
+	size := mgl32.Min(triadSize, mgl32.Min(p.Size[0], p.Size[1]))
+	// only the rotation of the camera, looking from a fixed distance
+	view := *p.camera.ViewMatrix()
+	view.SetCol(3, mgl32.Vec4{0, 0, -5, 1})
+	projection := mgl32.Ortho(-1.6, 1.6, -1.6, 1.6, 1, 10)
+	model := mgl32.Ident4()
+
+	gl.Viewport(0, 0, int32(size), int32(size))
+	gl.UseProgram(p.Shader.Program)
+	gl.UniformMatrix4fv(p.Shader.Uniforms["projection"], 1, false, &projection[0])
+	gl.UniformMatrix4fv(p.Shader.Uniforms["view"], 1, false, &view[0])
+	gl.UniformMatrix4fv(p.Shader.Uniforms["model"], 1, false, &model[0])
+	p.triad.draw()
+	gl.BindVertexArray(0)
+	p.triadLabels.Draw(&projection, &view, &model, [2]float32{size, size})
+	gl.Viewport(0, 0, int32(p.Size[0]), int32(p.Size[1]))
+}
+
 func (p *GlPreview) GetImage() (uint32, error) {
 	if !p.hasModel {
 		return 0, errors.New("Error: No model loaded for 3d preview")
@@ -249,21 +653,14 @@ func (p *GlPreview) GetImage() (uint32, error) {
 	return p.FboTex, nil
 }
 
+// Reset looks at the whole program from the iso view
 func (p *GlPreview) Reset() {
-	p.camera = glutil.NewPerspective(60, 1, 0.1, 1000)
-	radius := p.modelBbox.SphereRadius()
-	distance := radius/mgl32.Sin(mgl32.DegToRad(45.0)) + 30
-
-	p.camera.SetPosition(mgl32.Vec3{0, 10, distance})
-	p.camera.LookAt(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
-	p.camera.SetAspect(p.Size[0] / p.Size[1])
-
-	p.cameraControl = glutil.NewCameraControl(p.camera)
-	p.cameraControl.SetTarget(mgl32.Vec3{0, 0, 0})
-	p.cameraControl.SetAreaSize(p.Size[0], p.Size[1])
-
-	p.cameraChanged = true
-	p.sizeChanged = true
+	if p.camera == nil || p.modelBbox == nil {
+		return
+	}
+	v := p.currentView()
+	v.theta, v.phi = VIEW_PRESETS[ViewIso].Theta, VIEW_PRESETS[ViewIso].Phi
+	p.animateTo(p.fitView(v, p.modelBbox))
 }
 
 // ui interaction
@@ -278,6 +675,15 @@ func (p *GlPreview) onCursorPos(x, y float64) bool {
 
 func (p *GlPreview) onMouseButton(x, y float64, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) bool {
 	if p.In(float32(x), float32(y)) {
+		if button == glfw.MouseButtonLeft {
+			// a click picks, a drag turns the camera
+			if action == glfw.Press {
+				p.pressPos = [2]float64{x, y}
+				p.animation = nil
+			} else if action == glfw.Release && math.Abs(x-p.pressPos[0]) < 3 && math.Abs(y-p.pressPos[1]) < 3 && !imgui.IsAnyItemActive() {
+				p.pick(float32(x), float32(y))
+			}
+		}
 		p.cameraControl.OnMouseButton(button, action, mods)
 		p.cameraChanged = true
 		return true
@@ -287,6 +693,7 @@ func (p *GlPreview) onMouseButton(x, y float64, button glfw.MouseButton, action
 
 func (p *GlPreview) onScroll(x, y float64, xoff float64, yoff float64) bool {
 	if p.In(float32(x), float32(y)) {
+		p.animation = nil
 		p.cameraControl.OnMouseScroll(xoff, yoff)
 		p.cameraChanged = true
 		return true
@@ -296,6 +703,9 @@ func (p *GlPreview) onScroll(x, y float64, xoff float64, yoff float64) bool {
 
 func (p *GlPreview) onKey(x, y float64, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) bool {
 	if p.In(float32(x), float32(y)) {
+		if p.camera != nil && p.onViewKey(key, action, mods) {
+			return true
+		}
 		p.cameraControl.OnKey(key, scancode, action, mods)
 		p.cameraChanged = true
 		return true
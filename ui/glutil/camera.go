@@ -248,6 +248,41 @@ func NewOrthographic(aspect, near, far, size float32) *Camera {
 	return c
 }
 
+// Projection is how the camera projects, perspective or orthographic
+func (c *Camera) Projection() ProjectionType {
+	return c.proj
+}
+
+// SetProjection switches the projection, the orthographic size follows the
+// field of view at targetDist so that the framing is kept
+func (c *Camera) SetProjection(proj ProjectionType, targetDist float32) {
+	if proj == c.proj {
+		return
+	}
+	c.proj = proj
+	c.UpdateSize(targetDist)
+	c.projChanged = true
+}
+
+// SetClip sets the distances of the near and far clipping planes
+func (c *Camera) SetClip(near, far float32) {
+	if near == c.near && far == c.far {
+		return
+	}
+	c.near = near
+	c.far = far
+	c.projChanged = true
+}
+
+// Fov is the horizontal field of view, in degrees
+func (c *Camera) Fov() float32 {
+	return c.fov
+}
+
+func (c *Camera) Aspect() float32 {
+	return c.aspect
+}
+
 func (c *Camera) SetAspect(aspect float32) {
 	if aspect == c.aspect {
 		return
@@ -300,7 +335,7 @@ func (c *Camera) ProjMatrix() *mgl32.Mat4 {
 			var h, w float32
 			h = s / c.aspect
 			w = s
-			c.projMatrix = mgl32.Ortho(-w, w, h, -h, c.near, c.far)
+			c.projMatrix = mgl32.Ortho(-w, w, -h, h, c.near, c.far)
 		}
 		c.projChanged = false
 	}
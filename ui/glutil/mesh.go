@@ -0,0 +1,107 @@
+package glutil
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// floats of every vertex of a mesh: the position and the normal
+const MeshVertexFloats = 6
+
+const meshVertexShader = `
+#version 330
+uniform mat4 projection;
+uniform mat4 view;
+uniform mat4 model;
+
+in vec3 vert;
+in vec3 normal;
+
+out vec3 viewNormal;
+
+void main() {
+	gl_Position = projection * view * model * vec4(vert, 1.0);
+	viewNormal = mat3(view * model) * normal;
+}
+`
+
+// lit from the camera, both faces of the triangles
+const meshFragmentShader = `
+#version 330
+uniform vec4 color;
+
+in vec3 viewNormal;
+
+out vec4 outputColor;
+
+void main() {
+	vec3 light = normalize(vec3(0.3, 0.5, 1.0));
+	float diffuse = abs(dot(normalize(viewNormal), light));
+	outputColor = vec4(color.rgb * (0.3 + 0.7 * diffuse), color.a);
+}
+`
+
+// Mesh draws indexed triangles of a single color, lit from the camera
+type Mesh struct {
+	Shader Shader
+	Color  mgl32.Vec4
+
+	array *VertexArray
+}
+
+func NewMesh(color mgl32.Vec4) (*Mesh, error) {
+	shader, err := NewShaderString(meshVertexShader, meshFragmentShader, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Mesh{Shader: shader, Color: color}, nil
+}
+
+// SetData replaces the triangles, vertices are MeshVertexFloats each
+func (m *Mesh) SetData(vertices []float32, indices []uint32) {
+	if m.array != nil {
+		m.array.Delete()
+		m.array = nil
+	}
+	if len(vertices) == 0 || len(indices) == 0 {
+		return
+	}
+	attributes := NewAttributesMap()
+	attributes.Add(m.Shader.Attributes["vert"], 3, 0)
+	attributes.Add(m.Shader.Attributes["normal"], 3, 3)
+	m.array = &VertexArray{
+		Data:       vertices,
+		Indices:    indices,
+		Stride:     MeshVertexFloats,
+		DrawMode:   gl.STATIC_DRAW,
+		Attributes: attributes,
+	}
+	m.array.Setup()
+}
+
+func (m *Mesh) Empty() bool {
+	return m.array == nil
+}
+
+func (m *Mesh) Draw(projection, view, model *mgl32.Mat4) {
+	if m.array == nil {
+		return
+	}
+	gl.UseProgram(m.Shader.Program)
+	gl.UniformMatrix4fv(m.Shader.Uniforms["projection"], 1, false, &projection[0])
+	gl.UniformMatrix4fv(m.Shader.Uniforms["view"], 1, false, &view[0])
+	gl.UniformMatrix4fv(m.Shader.Uniforms["model"], 1, false, &model[0])
+	gl.Uniform4fv(m.Shader.Uniforms["color"], 1, &m.Color[0])
+
+	gl.BindVertexArray(m.array.Vao)
+	gl.DrawElements(gl.TRIANGLES, int32(len(m.array.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+	gl.BindVertexArray(0)
+}
+
+func (m *Mesh) Delete() {
+	if m.array != nil {
+		m.array.Delete()
+		m.array = nil
+	}
+	m.Shader.Delete()
+}
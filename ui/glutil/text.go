@@ -0,0 +1,178 @@
+package glutil
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// floats of every vertex of the labels: the anchor in space, the offset from
+// it on screen in pixels and the position in the atlas
+const labelVertexFloats = 7
+
+const labelVertexShader = `
+#version 330
+uniform mat4 projection;
+uniform mat4 view;
+uniform mat4 model;
+uniform vec2 viewport;
+
+in vec3 anchor;
+in vec2 offset;
+in vec2 uv;
+
+out vec2 texCoord;
+
+void main() {
+	vec4 pos = projection * view * model * vec4(anchor, 1.0);
+	pos.xy += offset * 2.0 / viewport * pos.w;
+	gl_Position = pos;
+	texCoord = uv;
+}
+`
+
+const labelFragmentShader = `
+#version 330
+uniform sampler2D msdf;
+uniform vec2 pxRange;
+uniform vec4 color;
+
+in vec2 texCoord;
+
+out vec4 outputColor;
+
+float median(float r, float g, float b) {
+	return max(min(r, g), min(max(r, g), b));
+}
+
+void main() {
+	vec3 s = texture(msdf, texCoord).rgb;
+	vec2 screenTexSize = vec2(1.0) / fwidth(texCoord);
+	float screenPxRange = max(0.5 * dot(pxRange, screenTexSize), 1.0);
+	float distance = median(s.r, s.g, s.b) - 0.5;
+	float opacity = clamp(distance * screenPxRange + 0.5, 0.0, 1.0);
+	if (opacity <= 0.0) {
+		discard;
+	}
+	outputColor = vec4(color.rgb, color.a * opacity);
+}
+`
+
+// Label is a text at a point in space
+type Label struct {
+	Text     string
+	Position mgl32.Vec3
+	// height of the text on screen, in pixels
+	Size float32
+	// centered on the point, or starting at it
+	Centered bool
+}
+
+// TextRenderer draws labels with an MSDF font. The labels face the camera
+// and keep their size on screen
+type TextRenderer struct {
+	Shader Shader
+	Font   *MsdfFont
+	Color  mgl32.Vec4
+
+	texture  uint32
+	vao, vbo uint32
+	pxRange  mgl32.Vec2
+	count    int32
+}
+
+func NewTextRenderer(font *MsdfFont, color mgl32.Vec4) (*TextRenderer, error) {
+	shader, err := NewShaderString(labelVertexShader, labelFragmentShader, "")
+	if err != nil {
+		return nil, err
+	}
+	texture, err := NewTextureRGBA(gl.CLAMP_TO_EDGE, gl.CLAMP_TO_EDGE, gl.LINEAR, gl.LINEAR, false, font.Image)
+	if err != nil {
+		return nil, err
+	}
+	t := &TextRenderer{
+		Shader:  shader,
+		Font:    font,
+		Color:   color,
+		texture: texture,
+	}
+	gl.GenVertexArrays(1, &t.vao)
+	gl.GenBuffers(1, &t.vbo)
+	gl.BindVertexArray(t.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
+	stride := int32(labelVertexFloats * GL_FLOAT32_SIZE)
+	for _, attribute := range []struct {
+		name   string
+		size   int32
+		offset int
+	}{{"anchor", 3, 0}, {"offset", 2, 3}, {"uv", 2, 5}} {
+		loc := shader.Attributes[attribute.name]
+		gl.EnableVertexAttribArray(loc)
+		gl.VertexAttribPointer(loc, attribute.size, gl.FLOAT, false, stride, gl.PtrOffset(attribute.offset*GL_FLOAT32_SIZE))
+	}
+	gl.BindVertexArray(0)
+	return t, nil
+}
+
+// SetLabels replaces the labels drawn
+func (t *TextRenderer) SetLabels(labels []Label) {
+	vertices := make([]float32, 0)
+	for _, label := range labels {
+		txRange, lineHeight, _, quads := t.Font.Render([]byte(label.Text), label.Size, 0)
+		t.pxRange = mgl32.Vec2{txRange[0], txRange[1]}
+		// the text is laid out down from 0, moved to sit around the point
+		width := float32(0)
+		for i := 0; i < len(quads); i += 4 {
+			width = mgl32.Max(width, quads[i])
+		}
+		dx, dy := float32(0), lineHeight
+		if label.Centered {
+			dx, dy = -width/2, lineHeight/2
+		}
+		for i := 0; i < len(quads); i += 4 {
+			vertices = append(vertices,
+				label.Position[0], label.Position[1], label.Position[2],
+				quads[i]+dx, quads[i+1]+dy,
+				quads[i+2], quads[i+3],
+			)
+		}
+	}
+	t.count = int32(len(vertices) / labelVertexFloats)
+	if t.count == 0 {
+		return
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*GL_FLOAT32_SIZE, gl.Ptr(vertices), gl.DYNAMIC_DRAW)
+}
+
+// Draw draws the labels over what is drawn, viewport is the size of the
+// target in pixels
+func (t *TextRenderer) Draw(projection, view, model *mgl32.Mat4, viewport [2]float32) {
+	if t.count == 0 {
+		return
+	}
+	gl.UseProgram(t.Shader.Program)
+	gl.UniformMatrix4fv(t.Shader.Uniforms["projection"], 1, false, &projection[0])
+	gl.UniformMatrix4fv(t.Shader.Uniforms["view"], 1, false, &view[0])
+	gl.UniformMatrix4fv(t.Shader.Uniforms["model"], 1, false, &model[0])
+	gl.Uniform2f(t.Shader.Uniforms["viewport"], viewport[0], viewport[1])
+	gl.Uniform2f(t.Shader.Uniforms["pxRange"], t.pxRange[0], t.pxRange[1])
+	gl.Uniform4fv(t.Shader.Uniforms["color"], 1, &t.Color[0])
+	gl.Uniform1i(t.Shader.Uniforms["msdf"], 0)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.BindVertexArray(t.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, t.count)
+	gl.BindVertexArray(0)
+	gl.Disable(gl.BLEND)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+func (t *TextRenderer) Delete() {
+	gl.DeleteVertexArrays(1, &t.vao)
+	gl.DeleteBuffers(1, &t.vbo)
+	gl.DeleteTextures(1, &t.texture)
+	t.Shader.Delete()
+}
@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"math"
+
+	"github.com/adragomir/linuxcncgo/gcode"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// line types of the vertices of the preview, they index LINE_COLORS in the
+// fragment shader
+const (
+	lineFeed = iota
+	lineRapid
+	linePlayback
+	lineTrail
+	lineTool
+	lineAxisX
+	lineAxisY
+	lineAxisZ
+	lineOutOfLimits
+	linePicked
+	lineGrid
+	lineGridMajor
+	lineDimension
+	// feeds and rapids run before the playback time, only a color
+	lineDone
+	lineTypes
+)
+
+// LINE_COLORS are the colors of the line types
+var LINE_COLORS = [lineTypes]mgl32.Vec4{
+	lineFeed:        {0.85, 0.85, 0.85, 1},
+	lineRapid:       {0, 1, 0, 1},
+	linePlayback:    {1, 1, 1, 1},
+	lineTrail:       {0, 0.8, 1, 1},
+	lineTool:        {0.5, 0.55, 0.7, 1},
+	lineAxisX:       {1, 0.4, 0.4, 1},
+	lineAxisY:       {0.4, 1, 0.4, 1},
+	lineAxisZ:       {0.3, 0.5, 1, 1},
+	lineOutOfLimits: {1, 0, 0, 1},
+	linePicked:      {1, 0.25, 1, 1},
+	lineGrid:        {0.16, 0.16, 0.16, 1},
+	lineGridMajor:   {0.3, 0.3, 0.3, 1},
+	lineDimension:   {0.9, 0.8, 0.5, 1},
+	lineDone:        {1, 0.8, 0.2, 1},
+}
+
+// sides of the tool and spindle outlines
+const outlineSegments = 16
+
+// profilePoint is a point of the outline of a tool seen from the side: its
+// radius at a height above the tip
+type profilePoint struct {
+	height float32
+	radius float32
+}
+
+// toolProfile is the side outline of a tool, from the tip up to the
+// spindle nose. An unknown diameter is drawn as fallback
+func toolProfile(tool gcode.Tool, fallback float32) []profilePoint {
+	r := tool.Diameter / 2
+	if r <= 0 {
+		r = fallback / 2
+	}
+	// the length offset is the best guess of how far the tool sticks out
+	stickout := tool.Length
+	if stickout < 2*r {
+		stickout = 6 * r
+	}
+	out := []profilePoint{{0, 0}}
+	switch tool.Shape {
+	case gcode.ToolBall:
+		for i := 1; i <= 4; i++ {
+			a := float64(i) * math.Pi / 8
+			out = append(out, profilePoint{r - r*float32(math.Cos(a)), r * float32(math.Sin(a))})
+		}
+	case gcode.ToolVBit:
+		half := float64(mgl32.DegToRad(tool.Angle / 2))
+		out = append(out, profilePoint{r / float32(math.Tan(half)), r})
+	default:
+		out = append(out, profilePoint{0, r})
+	}
+	if last := out[len(out)-1]; last.height >= stickout {
+		stickout = last.height + 2*r
+	}
+	return append(out, profilePoint{stickout, r})
+}
+
+// appendRevolved appends the wireframe of profile turned around the Z axis
+// at pos, in preview coordinates: a ring at every point of the profile and
+// lines along the sides between them
+func appendRevolved(out []float32, pos mgl32.Vec3, profile []profilePoint, lineType float32) []float32 {
+	at := func(p profilePoint, side int) mgl32.Vec3 {
+		a := float64(side) * 2 * math.Pi / outlineSegments
+		return gcode.PreviewPoint(pos.Add(mgl32.Vec3{
+			p.radius * float32(math.Cos(a)),
+			p.radius * float32(math.Sin(a)),
+			p.height,
+		}))
+	}
+	line := func(from, to mgl32.Vec3) {
+		out = append(out,
+			from[0], from[1], from[2], lineType, 0,
+			to[0], to[1], to[2], lineType, 0,
+		)
+	}
+	for i, p := range profile {
+		if p.radius > 0 {
+			for side := 0; side < outlineSegments; side++ {
+				line(at(p, side), at(p, side+1))
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		for side := 0; side < outlineSegments; side += 2 {
+			line(at(profile[i-1], side), at(p, side))
+		}
+	}
+	return out
+}
+
+// toolOutline is the wireframe of the tool with its tip at pos, in machine
+// coordinates, and of the spindle nose it sits in, in preview coordinates.
+// Without a tool only the spindle is drawn. size scales what is not known
+// about the machine
+func toolOutline(pos mgl32.Vec3, tool gcode.Tool, size float32, lineType float32) []float32 {
+	out := make([]float32, 0)
+	noseHeight := float32(0)
+	noseRadius := size
+	if tool.Number > 0 {
+		profile := toolProfile(tool, size/3)
+		out = appendRevolved(out, pos, profile, lineType)
+		top := profile[len(profile)-1]
+		noseHeight = top.height
+		noseRadius = mgl32.Max(noseRadius, 2*top.radius)
+	}
+	nose := []profilePoint{
+		{noseHeight, 0},
+		{noseHeight, noseRadius},
+		{noseHeight + 2*noseRadius, noseRadius},
+	}
+	return appendRevolved(out, pos, nose, lineType)
+}
+
+// originGizmo are the X, Y and Z axes at origin, in machine coordinates
+func originGizmo(out []float32, origin mgl32.Vec3, size float32) []float32 {
+	from := gcode.PreviewPoint(origin)
+	for axis, lineType := range []float32{lineAxisX, lineAxisY, lineAxisZ} {
+		d := mgl32.Vec3{}
+		d[axis] = size
+		to := gcode.PreviewPoint(origin.Add(d))
+		out = append(out,
+			from[0], from[1], from[2], lineType, 0,
+			to[0], to[1], to[2], lineType, 0,
+		)
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"math"
+	"time"
+
+	"github.com/adragomir/linuxcncgo/gcode"
+	"github.com/adragomir/linuxcncgo/ui/glutil"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// ViewPreset is a standard direction the preview is seen from
+type ViewPreset int
+
+const (
+	ViewIso ViewPreset = iota
+	ViewTop
+	ViewFront
+	ViewSide
+)
+
+// the camera is placed around its target at the angles of
+// glutil.CameraControl.Rotate: theta turns about the vertical from the front,
+// phi goes down from the vertical
+var VIEW_PRESETS = []struct {
+	Name  string
+	Key   glfw.Key
+	Theta float32
+	Phi   float32
+}{
+	ViewIso:   {"Iso", glfw.KeyI, math.Pi / 4, 0.9553},
+	ViewTop:   {"Top", glfw.KeyT, 0, 0.0001},
+	ViewFront: {"Front", glfw.KeyF, 0, math.Pi / 2},
+	ViewSide:  {"Side", glfw.KeyS, math.Pi / 2, math.Pi / 2},
+}
+
+// length of the animated changes of view
+const viewTransition = 300 * time.Millisecond
+
+// room left around what is fitted in the view
+const fitMargin = 1.1
+
+// cameraView is where the camera of the preview is, around its target
+type cameraView struct {
+	target   mgl32.Vec3
+	theta    float32
+	phi      float32
+	distance float32
+}
+
+type viewAnimation struct {
+	from, to cameraView
+	start    time.Time
+}
+
+// currentView is the view of the camera as it is
+func (p *GlPreview) currentView() cameraView {
+	target := p.cameraControl.Target()
+	tcam := p.camera.Position().Sub(target)
+	distance := tcam.Len()
+	if distance == 0 {
+		return cameraView{target: target, distance: 1}
+	}
+	return cameraView{
+		target:   target,
+		theta:    mgl32.Atan2(tcam.X(), tcam.Z()),
+		phi:      float32(math.Acos(float64(mgl32.Clamp(tcam.Y()/distance, -1, 1)))),
+		distance: distance,
+	}
+}
+
+// applyView moves the camera, the clipping planes follow the distance
+func (p *GlPreview) applyView(v cameraView) {
+	tcam := mgl32.Vec3{
+		v.distance * mgl32.Sin(v.phi) * mgl32.Sin(v.theta),
+		v.distance * mgl32.Cos(v.phi),
+		v.distance * mgl32.Sin(v.phi) * mgl32.Cos(v.theta),
+	}
+	p.cameraControl.SetTarget(v.target)
+	p.camera.SetPosition(v.target.Add(tcam))
+	p.camera.LookAt(v.target, mgl32.Vec3{0, 1, 0})
+	p.camera.UpdateSize(v.distance)
+	p.camera.SetClip(v.distance/1000, v.distance*100)
+	p.cameraChanged = true
+}
+
+// animateTo starts a smooth change from the current view to v
+func (p *GlPreview) animateTo(v cameraView) {
+	if p.camera == nil {
+		return
+	}
+	from := p.currentView()
+	// the short way around
+	for v.theta-from.theta > math.Pi {
+		v.theta -= 2 * math.Pi
+	}
+	for v.theta-from.theta < -math.Pi {
+		v.theta += 2 * math.Pi
+	}
+	p.animation = &viewAnimation{from: from, to: v, start: time.Now()}
+}
+
+// animate moves the camera along the running animation
+func (p *GlPreview) animate() {
+	if p.animation == nil {
+		return
+	}
+	a := p.animation
+	t := float32(time.Since(a.start)) / float32(viewTransition)
+	if t >= 1 {
+		p.applyView(a.to)
+		p.animation = nil
+		return
+	}
+	// ease in and out
+	t = t * t * (3 - 2*t)
+	lerp := func(from, to float32) float32 {
+		return from + (to-from)*t
+	}
+	p.applyView(cameraView{
+		target:   a.from.target.Add(a.to.target.Sub(a.from.target).Mul(t)),
+		theta:    lerp(a.from.theta, a.to.theta),
+		phi:      lerp(a.from.phi, a.to.phi),
+		distance: lerp(a.from.distance, a.to.distance),
+	})
+}
+
+// worldPoint is where a point in machine coordinates is drawn
+func (p *GlPreview) worldPoint(point mgl32.Vec3) mgl32.Vec3 {
+	return p.model.Mul4x1(gcode.PreviewPoint(point).Vec4(1)).Vec3()
+}
+
+// fitDistance is the distance the camera sees a sphere of radius whole from
+func (p *GlPreview) fitDistance(radius float32) float32 {
+	radius = mgl32.Max(radius, 1)
+	halfFov := mgl32.DegToRad(p.camera.Fov() / 2)
+	if aspect := p.camera.Aspect(); aspect > 1 {
+		// the field of view is horizontal
+		halfFov = float32(math.Atan(math.Tan(float64(halfFov)) / float64(aspect)))
+	}
+	return radius * fitMargin / mgl32.Sin(halfFov)
+}
+
+// fitView is v moved to see the whole box, in machine coordinates
+func (p *GlPreview) fitView(v cameraView, bbox *glutil.BoundingBox) cameraView {
+	v.target = p.worldPoint(bbox.Center())
+	v.distance = p.fitDistance(bbox.SphereRadius())
+	return v
+}
+
+// SetView turns the camera to look from a preset direction
+func (p *GlPreview) SetView(preset ViewPreset) {
+	if p.camera == nil {
+		return
+	}
+	v := p.currentView()
+	v.theta, v.phi = VIEW_PRESETS[preset].Theta, VIEW_PRESETS[preset].Phi
+	p.animateTo(v)
+}
+
+// FitBox moves the camera to see the whole box, in machine coordinates,
+// from the direction it looks from
+func (p *GlPreview) FitBox(bbox *glutil.BoundingBox) {
+	if p.camera == nil || bbox == nil {
+		return
+	}
+	p.animateTo(p.fitView(p.currentView(), bbox))
+}
+
+// FitProgram moves the camera to see the whole program
+func (p *GlPreview) FitProgram() {
+	p.FitBox(p.modelBbox)
+}
+
+func (p *GlPreview) Orthographic() bool {
+	return p.orthographic
+}
+
+// SetOrthographic switches between the perspective and the orthographic
+// projections, keeping what is seen at the target
+func (p *GlPreview) SetOrthographic(ortho bool) {
+	p.orthographic = ortho
+	if p.camera == nil {
+		return
+	}
+	proj := glutil.Perspective
+	if ortho {
+		proj = glutil.Orthographic
+	}
+	p.camera.SetProjection(proj, p.currentView().distance)
+	p.cameraChanged = true
+}
+
+// resetCamera makes a new camera, looking at the whole program from the
+// iso view
+func (p *GlPreview) resetCamera() {
+	p.camera = glutil.NewPerspective(60, p.Size[0]/p.Size[1], 0.1, 1000)
+	p.cameraControl = glutil.NewCameraControl(p.camera)
+	p.cameraControl.SetAreaSize(p.Size[0], p.Size[1])
+	p.animation = nil
+	v := cameraView{theta: VIEW_PRESETS[ViewIso].Theta, phi: VIEW_PRESETS[ViewIso].Phi}
+	p.applyView(p.fitView(v, p.modelBbox))
+	if p.orthographic {
+		p.camera.SetProjection(glutil.Orthographic, p.currentView().distance)
+	}
+}
+
+// onViewKey changes the view on the keys of the presets, O switches the
+// projection and Home fits the program
+func (p *GlPreview) onViewKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action != glfw.Press || mods != 0 {
+		return false
+	}
+	for preset, view := range VIEW_PRESETS {
+		if view.Key == key {
+			p.SetView(ViewPreset(preset))
+			return true
+		}
+	}
+	switch key {
+	case glfw.KeyO:
+		p.SetOrthographic(!p.orthographic)
+		return true
+	case glfw.KeyHome:
+		p.FitProgram()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adragomir/linuxcncgo/machine"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// runProbe runs the probe routine picked in the background, the result is
+// shown when done
+func (ui *Ui) runProbe(m *machine.Machine) {
+	ui.probeRunning = true
+	ui.probeProgress.Set(0)
+	ui.probeResult = nil
+	ui.probeError = ""
+	routine, settings := machine.ProbeRoutine(ui.probeRoutine), ui.probeSettings
+	ctx, cancel := context.WithCancel(context.Background())
+	ui.probeCancel = cancel
+	go func() {
+		result, err := m.RunProbe(ctx, routine, settings, func(done, total int) {
+			ui.probeProgress.Set(float32(done) / float32(total))
+		})
+		cancel()
+		ui.post(func() {
+			ui.probeRunning = false
+			ui.probeCancel = nil
+			if err != nil {
+				ui.probeError = err.Error()
+				return
+			}
+			ui.probeResult = &result
+		})
+	}()
+}
+
+// layoutProbing shows the probe routines in their own window: the routine
+// and its settings, its result, and where to set it as work origin
+func (ui *Ui) layoutProbing(m *machine.Machine) {
+	if !ui.showProbing || m == nil {
+		return
+	}
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 360, Y: 480}, imgui.ConditionFirstUseEver)
+	if !imgui.BeginV("Probing", &ui.showProbing, imgui.WindowFlagsNoCollapse) {
+		imgui.End()
+		return
+	}
+	// the routine may start during the frame, the disabled block is closed
+	// as it was opened
+	running := ui.probeRunning
+	if running {
+		imgui.BeginDisabled()
+	}
+	routine := machine.ProbeRoutine(ui.probeRoutine)
+	imgui.SetNextItemWidth(150)
+	if imgui.BeginCombo("Routine", machine.PROBE_ROUTINE_NAMES[routine]) {
+		for i, name := range machine.PROBE_ROUTINE_NAMES {
+			if imgui.SelectableV(name, i == int(routine), 0, imgui.Vec2{}) {
+				ui.probeRoutine = int32(i)
+				ui.probeResult = nil
+			}
+		}
+		imgui.EndCombo()
+	}
+	routine = machine.ProbeRoutine(ui.probeRoutine)
+
+	s := &ui.probeSettings
+	switch routine {
+	case machine.ProbeEdgeX, machine.ProbeEdgeY, machine.ProbeCorner:
+		for axis, name := range []string{"X", "Y"} {
+			if (routine == machine.ProbeEdgeX && axis != 0) || (routine == machine.ProbeEdgeY && axis != 1) {
+				continue
+			}
+			imgui.Text("Toward " + name)
+			imgui.SameLine()
+			if imgui.RadioButton("-##dir"+name, s.Direction[axis] < 0) {
+				s.Direction[axis] = -1
+			}
+			imgui.SameLine()
+			if imgui.RadioButton("+##dir"+name, s.Direction[axis] > 0) {
+				s.Direction[axis] = 1
+			}
+		}
+		if routine == machine.ProbeCorner {
+			DragFloat64("Offset", &s.Offset, 0.1)
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Sideways move to reach the faces of an outside corner, 0 for an inside corner")
+			}
+		}
+	case machine.ProbeBore, machine.ProbeBoss:
+		DragFloat64("Diameter", &s.Diameter, 0.1)
+		if routine == machine.ProbeBoss {
+			DragFloat64("Depth", &s.Depth, 0.1)
+		}
+	}
+	if imgui.CollapsingHeader("Probe settings") {
+		DragFloat64("Tip diameter", &s.TipDiameter, 0.01)
+		DragFloat64("Search", &s.Search, 0.1)
+		DragFloat64("Retract", &s.Retract, 0.1)
+		DragFloat64("Feed", &s.Feed, 1)
+		DragFloat64("Latch feed", &s.LatchFeed, 1)
+	}
+
+	ButtonDisabled("Probe", m.Running(), func() {
+		ui.runProbe(m)
+	})
+	if running {
+		imgui.EndDisabled()
+		imgui.SameLine()
+		if imgui.Button("Stop") && ui.probeCancel != nil {
+			// the routine sends no more moves, the one running is aborted
+			ui.probeCancel()
+			m.Abort("execute")
+		}
+		imgui.ProgressBarV(ui.probeProgress.Get(), imgui.Vec2{X: -1, Y: 0}, "Probing")
+	}
+	if ui.probeError != "" {
+		imgui.PushStyleColor(imgui.StyleColorText, RGB(255, 80, 80).V())
+		imgui.Text(ui.probeError)
+		imgui.PopStyleColor()
+	}
+
+	if result := ui.probeResult; result != nil && !running {
+		imgui.Separator()
+		imgui.Text(fmt.Sprintf("%s: %s", machine.PROBE_ROUTINE_NAMES[result.Routine], result))
+		for i, point := range result.Points {
+			imgui.Text(fmt.Sprintf("  touch %d: X%.4f Y%.4f Z%.4f", i+1, point[0], point[1], point[2]))
+		}
+		imgui.SetNextItemWidth(100)
+		if imgui.BeginCombo("##probeLcs", ui.probeLcs) {
+			for _, name := range machine.LCS_NAMES {
+				if imgui.SelectableV(name, name == ui.probeLcs, 0, imgui.Vec2{}) {
+					ui.probeLcs = name
+				}
+			}
+			imgui.EndCombo()
+		}
+		imgui.SameLine()
+		ButtonDisabled("Set origin", m.Running(), func() {
+			if err := m.SetWorkOffset(ui.probeLcs, result.Position, result.Axes); err != nil {
+				ui.probeError = err.Error()
+			}
+		})
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Move the origin of the coordinate system to what was found")
+		}
+	}
+	imgui.End()
+}
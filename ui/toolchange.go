@@ -0,0 +1,231 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adragomir/linuxcncgo/machine"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// steps of the guided tool change
+type toolChangeStep int
+
+const (
+	toolChangeNone toolChangeStep = iota
+	// waiting for the operator to load the tool
+	toolChangeLoad
+	// the length of the tool is being probed
+	toolChangeProbing
+	// the length was probed, to be applied
+	toolChangeMeasured
+)
+
+// toolChange is the state of the guided tool change: the tool to load, if
+// it was asked for by a program, and its probed length
+type toolChange struct {
+	step      toolChangeStep
+	tool      machine.ToolEntry
+	inProgram bool
+	// the dialog is to be opened
+	open bool
+	// the pending change of the program was dealt with, until the program
+	// goes on
+	handled bool
+
+	// stops the probe of the length
+	cancel context.CancelFunc
+	length float64
+	err    string
+}
+
+// startToolChange opens the guided tool change for a tool
+func (ui *Ui) startToolChange(tool machine.ToolEntry, inProgram bool) {
+	ui.toolChange = toolChange{step: toolChangeLoad, tool: tool, inProgram: inProgram, open: true}
+}
+
+// finishToolChange closes the guided tool change, a change of the program
+// is not offered again until the program goes on
+func (ui *Ui) finishToolChange() {
+	ui.toolChange = toolChange{handled: ui.toolChange.inProgram}
+	imgui.CloseCurrentPopup()
+}
+
+// measureTool probes the length of the tool in the background. A tool
+// changed by hand is set in the spindle first
+func (ui *Ui) measureTool(m *machine.Machine) {
+	tc := &ui.toolChange
+	tc.step = toolChangeProbing
+	tc.err = ""
+	ctx, cancel := context.WithCancel(context.Background())
+	tc.cancel = cancel
+	setter, number, inProgram := ui.toolSetter, tc.tool.Number, tc.inProgram
+	go func() {
+		if !inProgram {
+			m.LoadTool(number)
+		}
+		z, err := m.ProbeToolLength(ctx, setter)
+		cancel()
+		ui.post(func() {
+			tc := &ui.toolChange
+			if tc.step != toolChangeProbing || tc.tool.Number != number {
+				// stopped or closed meanwhile
+				return
+			}
+			tc.cancel = nil
+			if err != nil {
+				tc.step = toolChangeLoad
+				tc.err = err.Error()
+				return
+			}
+			tc.step = toolChangeMeasured
+			tc.length = z - setter.Z
+		})
+	}()
+}
+
+// layoutToolChange shows the guided tool change when a program waits for a
+// tool, or when it was started by hand: the tool to load, the probe of its
+// length on the tool setter and the length applied
+func (ui *Ui) layoutToolChange(m *machine.Machine) {
+	if m == nil {
+		return
+	}
+	pending, ok := m.PendingToolChange()
+	if !ok {
+		ui.toolChange.handled = false
+	} else if ui.toolChange.step == toolChangeNone && !ui.toolChange.handled {
+		ui.startToolChange(pending, true)
+	}
+	if ui.toolChange.open {
+		imgui.OpenPopup("Tool change")
+		ui.toolChange.open = false
+	}
+
+	if !imgui.BeginPopupModalV("Tool change", nil, imgui.WindowFlagsAlwaysAutoResize) {
+		return
+	}
+	tc := &ui.toolChange
+	tool := tc.tool
+	imgui.PushStyleColor(imgui.StyleColorText, RGB(255, 204, 51).V())
+	imgui.Text(fmt.Sprintf("Load tool T%d in the spindle", tool.Number))
+	imgui.PopStyleColor()
+	if tool.Comment != "" {
+		imgui.Text(tool.Comment)
+	}
+	imgui.Text(fmt.Sprintf("Diameter %.4f, length %.4f", tool.Diameter, tool.Length))
+	if tc.err != "" {
+		imgui.PushStyleColor(imgui.StyleColorText, RGB(255, 80, 80).V())
+		imgui.Text(tc.err)
+		imgui.PopStyleColor()
+	}
+	imgui.Separator()
+
+	switch tc.step {
+	case toolChangeLoad:
+		if imgui.CollapsingHeader("Tool setter") {
+			ui.layoutToolSetter()
+		}
+		// the controller takes the MDI of the probe when no program runs, or
+		// from a paused program
+		busy := m.Running() && !m.Paused()
+		ButtonDisabled("Measure length", busy, func() {
+			ui.measureTool(m)
+		})
+		if busy && imgui.IsItemHoveredV(imgui.HoveredFlagsAllowWhenDisabled) {
+			imgui.SetTooltip("The tool can be measured when the program is paused, by the M0 of the M6 of the machine")
+		}
+		imgui.SameLine()
+		ui.layoutToolChangeDone(m, "Tool loaded")
+	case toolChangeProbing:
+		imgui.Text("Measuring...")
+		if imgui.Button("Stop") && tc.cancel != nil {
+			tc.cancel()
+			tc.cancel = nil
+			m.Abort("execute")
+			tc.step = toolChangeLoad
+			tc.err = "stopped"
+		}
+		if tc.inProgram && imgui.IsItemHovered() {
+			imgui.SetTooltip("Aborts the program too")
+		}
+	case toolChangeMeasured:
+		imgui.Text(fmt.Sprintf("Measured length %.4f, was %.4f", tc.length, tool.Length))
+		if imgui.Button("Apply") {
+			if err := m.SetToolLength(tool, tc.length); err != nil {
+				tc.err = err.Error()
+			} else {
+				tc.tool.Length = tc.length
+				tc.err = ""
+			}
+			tc.step = toolChangeLoad
+		}
+		imgui.SameLine()
+		if imgui.Button("Measure again") {
+			tc.step = toolChangeLoad
+		}
+	}
+	imgui.EndPopup()
+}
+
+// layoutToolChangeDone ends the tool change: a program goes on, a tool
+// changed by hand is set in the spindle
+func (ui *Ui) layoutToolChangeDone(m *machine.Machine, label string) {
+	tc := &ui.toolChange
+	if tc.inProgram {
+		if imgui.Button(label + ", resume") {
+			m.ResumeProgram("execute")
+			ui.finishToolChange()
+		}
+		imgui.SameLine()
+		if imgui.Button("Abort program") {
+			m.Abort("execute")
+			ui.finishToolChange()
+		}
+		return
+	}
+	if imgui.Button(label) {
+		m.LoadTool(tc.tool.Number)
+		ui.finishToolChange()
+	}
+	imgui.SameLine()
+	if imgui.Button("Cancel") {
+		ui.finishToolChange()
+	}
+}
+
+// layoutToolSetter edits where the tool setter is, in machine coordinates
+func (ui *Ui) layoutToolSetter() {
+	values := []struct {
+		label string
+		value *float64
+	}{
+		{"X", &ui.toolSetter.X},
+		{"Y", &ui.toolSetter.Y},
+		{"Z of the nose", &ui.toolSetter.Z},
+		{"Safe Z", &ui.toolSetter.SafeZ},
+		{"Search", &ui.toolSetter.Search},
+		{"Feed", &ui.toolSetter.Feed},
+	}
+	for _, v := range values {
+		DragFloat64(v.label+"##toolSetter", v.value, 0.1)
+	}
+}
+
+// layoutChangeTool picks a tool of the table to change to by hand
+func (ui *Ui) layoutChangeTool(m *machine.Machine) {
+	if !imgui.BeginPopup("change tool") {
+		return
+	}
+	inSpindle := m.ToolInSpindle()
+	for _, tool := range m.GetToolTable() {
+		label := fmt.Sprintf("T%d %s", tool.Number, tool.Comment)
+		if imgui.SelectableV(label, tool.Number == inSpindle, 0, imgui.Vec2{}) {
+			imgui.CloseCurrentPopup()
+			imgui.EndPopup()
+			ui.startToolChange(tool, false)
+			return
+		}
+	}
+	imgui.EndPopup()
+}
@@ -0,0 +1,189 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adragomir/linuxcncgo/machine"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// how long the machine has to report a saved tool table
+const toolTableSaveTimeout = 5 * time.Second
+
+// nextTool is a tool number and a pocket not used in the table
+func nextTool(table machine.ToolTable) (int, int) {
+	number, pocket := 0, 0
+	for _, tool := range table {
+		if tool.Number > number {
+			number = tool.Number
+		}
+		if tool.Pocket > pocket {
+			pocket = tool.Pocket
+		}
+	}
+	return number + 1, pocket + 1
+}
+
+// layoutToolTable shows the tool table of the machine in its own window.
+// The table follows the machine until it is edited, the edits are saved to
+// the controller or reverted. A saved table is shown until the machine
+// reports its new table, it is told when the machine does not
+func (ui *Ui) layoutToolTable(m *machine.Machine) {
+	if !ui.showToolTable || m == nil {
+		return
+	}
+	live := m.GetToolTable()
+	if ui.toolTableSaving && !live.Equal(ui.toolTableBase) {
+		ui.toolTableSaving = false
+		ui.toolTable = live.Clone()
+		ui.toolTableBase = live
+	} else if ui.toolTableSaving && time.Since(ui.toolTableSavedAt) > toolTableSaveTimeout {
+		// the edits are kept to be saved again
+		ui.toolTableSaving = false
+		ui.toolTableError = "the machine did not change its tool table"
+	}
+	edited := !ui.toolTable.Equal(ui.toolTableBase)
+	if !edited && !ui.toolTableSaving {
+		ui.toolTable = live.Clone()
+		ui.toolTableBase = live
+	}
+	editing := edited && !ui.toolTableSaving
+
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 640, Y: 400}, imgui.ConditionFirstUseEver)
+	if !imgui.BeginV("Tool table", &ui.showToolTable, imgui.WindowFlagsNoCollapse) {
+		imgui.End()
+		return
+	}
+	if imgui.Button("Add") {
+		number, pocket := nextTool(ui.toolTable)
+		ui.toolTable = append(ui.toolTable, machine.ToolEntry{Number: number, Pocket: pocket})
+	}
+	imgui.SameLineV(0, 2)
+	ButtonDisabled("Save", !editing, func() {
+		if err := m.UpdateToolTable(ui.toolTable); err != nil {
+			ui.toolTableError = err.Error()
+			return
+		}
+		ui.toolTableError = ""
+		ui.toolTableSaving = true
+		ui.toolTableSavedAt = time.Now()
+	})
+	imgui.SameLineV(0, 2)
+	ButtonDisabled("Revert", !edited, func() {
+		ui.toolTable = live.Clone()
+		ui.toolTableBase = live
+		ui.toolTableSaving = false
+		ui.toolTableError = ""
+	})
+	imgui.SameLineV(0, 2)
+	ButtonDisabled("Reload", edited, func() {
+		m.ReloadToolTable()
+	})
+	if imgui.IsItemHoveredV(imgui.HoveredFlagsAllowWhenDisabled) {
+		imgui.SetTooltip("Load the tool table file of the controller again")
+	}
+	if ui.toolTableSaving {
+		imgui.SameLine()
+		imgui.Text("saving...")
+	} else if edited && !live.Equal(ui.toolTableBase) {
+		imgui.SameLine()
+		imgui.PushStyleColor(imgui.StyleColorText, RGB(255, 204, 51).V())
+		imgui.Text("changed on the machine since")
+		imgui.PopStyleColor()
+	}
+	if ui.toolTableError != "" {
+		imgui.PushStyleColor(imgui.StyleColorText, RGB(255, 80, 80).V())
+		imgui.Text(ui.toolTableError)
+		imgui.PopStyleColor()
+	}
+
+	inSpindle := m.ToolInSpindle()
+	remove := -1
+	flags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable | imgui.TableFlagsScrollY
+	if imgui.BeginTableV("tools", 6, flags, imgui.Vec2{X: 0, Y: imgui.ContentRegionAvail().Y}, 0) {
+		imgui.TableSetupColumnV("T", imgui.TableColumnFlagsWidthFixed, 60, 0)
+		imgui.TableSetupColumnV("Pocket", imgui.TableColumnFlagsWidthFixed, 60, 0)
+		imgui.TableSetupColumnV("Diameter", imgui.TableColumnFlagsWidthFixed, 90, 0)
+		imgui.TableSetupColumnV("Length", imgui.TableColumnFlagsWidthFixed, 90, 0)
+		imgui.TableSetupColumnV("Comment", imgui.TableColumnFlagsWidthStretch, 0, 0)
+		imgui.TableSetupColumnV("", imgui.TableColumnFlagsWidthFixed, 24, 0)
+		imgui.TableHeadersRow()
+		for i := range ui.toolTable {
+			tool := &ui.toolTable[i]
+			imgui.PushID(fmt.Sprintf("tool%d", i))
+			imgui.TableNextRow()
+			if tool.Number == inSpindle {
+				imgui.TableSetBgColor(imgui.TableBgTargetRowBg1, RGBA(255, 204, 51, 80).V())
+			}
+
+			number, pocket := int32(tool.Number), int32(tool.Pocket)
+			imgui.TableNextColumn()
+			imgui.SetNextItemWidth(-1)
+			if imgui.DragIntV("##number", &number, 0.1, 1, 99999, "T%d", imgui.SliderFlagsAlwaysClamp) {
+				tool.Number = int(number)
+			}
+			imgui.TableNextColumn()
+			imgui.SetNextItemWidth(-1)
+			if imgui.DragIntV("##pocket", &pocket, 0.1, 1, 99999, "P%d", imgui.SliderFlagsAlwaysClamp) {
+				tool.Pocket = int(pocket)
+			}
+			// written back only when edited, to keep the values as sent
+			diameter, length := float32(tool.Diameter), float32(tool.Length)
+			imgui.TableNextColumn()
+			imgui.SetNextItemWidth(-1)
+			if imgui.DragFloatV("##diameter", &diameter, 0.01, 0, 1000, "%.4f", imgui.SliderFlagsAlwaysClamp) {
+				tool.Diameter = float64(diameter)
+			}
+			imgui.TableNextColumn()
+			imgui.SetNextItemWidth(-1)
+			if imgui.DragFloatV("##length", &length, 0.01, -1000, 1000, "%.4f", imgui.SliderFlagsNone) {
+				tool.Length = float64(length)
+			}
+			imgui.TableNextColumn()
+			imgui.SetNextItemWidth(-1)
+			imgui.InputText("##comment", &tool.Comment)
+			imgui.TableNextColumn()
+			if imgui.Button("x") {
+				remove = i
+			}
+			imgui.PopID()
+		}
+		imgui.EndTable()
+	}
+	if remove >= 0 {
+		if ui.toolTable[remove].Number == inSpindle {
+			// asked first, the machine would be left with a tool it does
+			// not know the offsets of
+			ui.toolTableRemove = inSpindle
+			imgui.OpenPopup("Remove tool")
+		} else {
+			ui.toolTable = append(ui.toolTable[:remove], ui.toolTable[remove+1:]...)
+		}
+	}
+	ui.layoutRemoveSpindleTool()
+	imgui.End()
+}
+
+// layoutRemoveSpindleTool asks before the tool in the spindle is removed
+// from the table
+func (ui *Ui) layoutRemoveSpindleTool() {
+	if !imgui.BeginPopupModalV("Remove tool", nil, imgui.WindowFlagsAlwaysAutoResize) {
+		return
+	}
+	imgui.PushStyleColor(imgui.StyleColorText, RGB(255, 204, 51).V())
+	imgui.Text(fmt.Sprintf("T%d is in the spindle", ui.toolTableRemove))
+	imgui.PopStyleColor()
+	imgui.Text("Its offsets are lost when the table is saved without it")
+	if imgui.Button("Remove") {
+		if i, ok := ui.toolTable.Find(ui.toolTableRemove); ok {
+			ui.toolTable = append(ui.toolTable[:i], ui.toolTable[i+1:]...)
+		}
+		imgui.CloseCurrentPopup()
+	}
+	imgui.SameLine()
+	if imgui.Button("Cancel") {
+		imgui.CloseCurrentPopup()
+	}
+	imgui.EndPopup()
+}
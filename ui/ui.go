@@ -3,17 +3,23 @@ package ui
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"image/color"
 	"log"
+	"math"
 	"os"
 	"os/exec"
+	"sort"
 	"time"
 
 	"github.com/adragomir/linuxcncgo/gcode"
+	"github.com/adragomir/linuxcncgo/gcode/parser"
 	"github.com/adragomir/linuxcncgo/machine"
 	"github.com/adragomir/linuxcncgo/network"
+	"github.com/adragomir/linuxcncgo/ui/glutil"
 	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
 
 	"github.com/inkyblackness/imgui-go/v4"
 	pb "github.com/machinekit/machinetalk_protobuf_go"
@@ -44,6 +50,115 @@ func convertPositionToMap(pos []float64) map[string]string {
 	}
 }
 
+func convertTool(in *pb.EmcToolData) gcode.Tool {
+	shape, angle := gcode.ParseToolShape(in.GetComment())
+	return gcode.Tool{
+		Number:   int(in.GetId()),
+		Diameter: float32(in.GetDiameter()),
+		Length:   float32(in.GetOffset().GetZ()),
+		Shape:    shape,
+		Angle:    angle,
+	}
+}
+
+func convertToolTable(in []*pb.EmcToolData) gcode.ToolTable {
+	out := make(gcode.ToolTable)
+	for _, tool := range in {
+		out[int(tool.GetId())] = convertTool(tool)
+	}
+	return out
+}
+
+// spindleTool is the tool in the spindle, the zero tool if there is none
+func spindleTool(io *pb.EmcStatusIo) gcode.Tool {
+	number := io.GetToolInSpindle()
+	if number <= 0 {
+		return gcode.Tool{}
+	}
+	for _, tool := range io.GetToolTable() {
+		if tool.GetId() == number {
+			return convertTool(tool)
+		}
+	}
+	// not in the table, drawn with the default size
+	return gcode.Tool{Number: int(number)}
+}
+
+// tableTool is tool number of tools, the zero tool for 0
+func tableTool(tools gcode.ToolTable, number int) gcode.Tool {
+	if number <= 0 {
+		return gcode.Tool{}
+	}
+	if tool, ok := tools[number]; ok {
+		return tool
+	}
+	// not in the table, drawn with the default size
+	return gcode.Tool{Number: number}
+}
+
+func convertLinearUnits(in pb.EmcLinearUnitsType) gcode.Units {
+	switch in {
+	case pb.EmcLinearUnitsType_LINEAR_UNITS_INCH:
+		return gcode.UnitsInch
+	case pb.EmcLinearUnitsType_LINEAR_UNITS_CM:
+		return gcode.UnitsCM
+	}
+	return gcode.UnitsMM
+}
+
+func toVec3(in []float64) mgl32.Vec3 {
+	out := mgl32.Vec3{}
+	for i := 0; i < len(in) && i < 3; i++ {
+		out[i] = float32(in[i])
+	}
+	return out
+}
+
+// workOffsets are the coordinate system offsets of the machine, so that the
+// preview matches where the program will cut
+func workOffsets(m *machine.Machine) *gcode.WorkOffsets {
+	if m == nil || m.MotionState == nil {
+		return nil
+	}
+	return &gcode.WorkOffsets{
+		Origin:       m.GetG5XIndex(),
+		OriginOffset: toVec3(m.GetG5XOffset()),
+		G92Offset:    toVec3(m.GetG92Offset()),
+	}
+}
+
+// machineLimits are the velocity and acceleration limits and the overrides
+// of the machine, so that the estimated run time matches the machine
+func machineLimits(m *machine.Machine, units gcode.Units) *gcode.MachineLimits {
+	limits := gcode.DefaultLimits(units)
+	if m == nil || m.ConfigState == nil {
+		return limits
+	}
+	config := m.ConfigState
+	for _, axis := range config.GetAxis() {
+		i := int(axis.GetIndex())
+		if i < 0 || i >= len(limits.MaxVelocity) {
+			continue
+		}
+		limits.MaxVelocity[i] = float32(axis.GetMaxVelocity())
+		limits.MaxAcceleration[i] = float32(axis.GetMaxAcceleration())
+	}
+	if v := config.GetMaxVelocity(); v > 0 {
+		limits.MaxPathVelocity = float32(v)
+	}
+	if a := config.GetMaxAcceleration(); a > 0 {
+		limits.MaxPathAcceleration = float32(a)
+	}
+	if v := config.GetDefaultVelocity(); v > 0 {
+		limits.DefaultVelocity = float32(v)
+	}
+	if m.MotionState != nil {
+		limits.FeedOverride = float32(m.MotionState.GetFeedrate())
+		limits.RapidOverride = float32(m.MotionState.GetRapidrate())
+	}
+	return limits
+}
+
 func StartUi(services *machine.Services) {
 	context := imgui.CreateContext(nil)
 	defer context.Destroy()
@@ -81,14 +196,19 @@ type Ui struct {
 
 	state    UiState
 	services *machine.Services
+	// results of the background tasks, applied by the frame loop
+	results chan func()
 
 	// ui state
 	increments         []float64
 	jogVelocity        float32
-	feedOverride       float32
-	rapidOverride      float32
+	feedOverride       override
+	rapidOverride      override
+	spindleOverride    override
 	maxVelocity        float32
 	maxMachineVelocity float32
+	// speed the spindle is started at, in rpm
+	spindleRpm float32
 
 	// ui custom components
 	focusMdi                bool
@@ -99,7 +219,78 @@ type Ui struct {
 
 	gcodePreview *GlPreview
 
+	programPath     string
 	programContents []byte
+	// fraction of the program simulated while it loads, the last load
+	// asked for is the one applied
+	loading        bool
+	loadProgress   taskProgress
+	loadGeneration int
+	diagnostics    []parser.Diagnostic
+	programStats   *gcode.SimulateStats
+	programTools   gcode.ToolTable
+	// playback of the simulated program in the preview, playTime is in
+	// seconds of the program
+	playback  bool
+	playing   bool
+	playTime  float32
+	playSpeed float32
+	lastFrame time.Time
+	// 1-based line run at playTime, 0 if none
+	playLine         int
+	scrollToPlayLine bool
+	// 1-based line picked in the preview, 0 if none
+	pickedLine         int
+	scrollToPickedLine bool
+	// actual positions drawn in the preview
+	showTrail    bool
+	trailVersion int
+	// stock cut by the program, in machine coordinates. A cell of 0 picks
+	// the resolution from the size
+	stockMin, stockMax [3]float32
+	stockCell          float32
+	stockLoading       bool
+	stockProgress      taskProgress
+	// the point the rotary axes turn about in the simulation, in machine
+	// coordinates, the work origin unless set
+	rotaryCenter   [3]float32
+	rotaryAtOrigin bool
+	// simulated path of the loaded program by line, at the work origin it
+	// was loaded with, and the moves of it past the soft limits
+	segmentMap      []gcode.SimMap
+	programOrigin   mgl32.Vec3
+	limitsChecked   bool
+	limitEnvelope   gcode.Envelope
+	limitShift      mgl32.Vec3
+	limitViolations []gcode.LimitViolation
+	// diagnostics of the simulation, without the soft limits
+	programDiagnostics []parser.Diagnostic
+	// 1-based program line -> diagnostics reported on it
+	lineDiagnostics map[int][]parser.Diagnostic
+	// tool table window: the table as edited, the table of the machine it
+	// was edited from, and if and when it was sent to the machine
+	showToolTable    bool
+	toolTable        machine.ToolTable
+	toolTableBase    machine.ToolTable
+	toolTableSaving  bool
+	toolTableSavedAt time.Time
+	toolTableError   string
+	// tool in the spindle asked to be removed from the table
+	toolTableRemove int
+	// guided tool change, and the setter the tools are measured on
+	toolChange toolChange
+	toolSetter machine.ToolSetter
+	// probing window: the routine picked and how it probes, its result and
+	// the coordinate system it is set in
+	showProbing   bool
+	probeRoutine  int32
+	probeSettings machine.ProbeSettings
+	probeRunning  bool
+	probeCancel   context.CancelFunc
+	probeProgress taskProgress
+	probeResult   *machine.ProbeResult
+	probeError    string
+	probeLcs      string
 }
 
 func NewUi(platform Platform, renderer Renderer, services *machine.Services) *Ui {
@@ -110,11 +301,30 @@ func NewUi(platform Platform, renderer Renderer, services *machine.Services) *Ui
 		state:    StateLoading,
 		services: services,
 
-		feedOverride:  1.0,
-		rapidOverride: 1.0,
+		feedOverride:    override{value: 1, reported: 1},
+		rapidOverride:   override{value: 1, reported: 1},
+		spindleOverride: override{value: 1, reported: 1},
+		playSpeed:       1.0,
+		showTrail:       true,
+		trailVersion:    -1,
+		rotaryAtOrigin:  true,
+
+		toolSetter: machine.ToolSetter{Search: 50, Feed: 100},
+		probeSettings: machine.ProbeSettings{
+			TipDiameter: 2,
+			Search:      10,
+			Retract:     1,
+			Feed:        100,
+			LatchFeed:   10,
+			Direction:   [2]float64{1, 1},
+			Diameter:    20,
+			Depth:       5,
+		},
+		probeLcs: "G54",
 
 		dimensions:   make(map[string][2]imgui.Vec2),
 		gcodePreview: &GlPreview{},
+		results:      make(chan func(), 16),
 	}
 	services.AddCb("launcherUpdate", func(l *machine.Launcher) {
 		// firce imgui to refresh
@@ -131,6 +341,7 @@ func NewUi(platform Platform, renderer Renderer, services *machine.Services) *Ui
 			tmpUi.jogVelocity = tmpUi.maxMachineVelocity / 2
 			tmpUi.maxVelocity = tmpUi.maxMachineVelocity / 2
 		}
+		tmpUi.spindleRpm = float32(m.DefaultSpindleSpeed())
 		m.AddCb("configUpdateIncrements", func(incs []float64) {
 			tmpUi.increments = incs
 		})
@@ -176,6 +387,7 @@ func (ui *Ui) Start() {
 		ui.platform.NewFrame()
 		imgui.NewFrame()
 
+		ui.applyResults()
 		ui.Layout()
 		ui.AfterLayout()
 
@@ -373,7 +585,8 @@ func (ui *Ui) LayoutLauncher() {
 
 func (ui *Ui) LayoutMachine() {
 	machine := ui.services.ActiveMachine
-	state := BuildMachineState(machine)
+	ui.checkLimits()
+	state := BuildMachineState(machine, len(ui.limitViolations) > 0)
 
 	imgui.PushStyleVarVec2(imgui.StyleVarWindowPadding, imgui.Vec2{X: 0, Y: 0})
 	imgui.SetNextWindowBgAlpha(0.)
@@ -444,11 +657,34 @@ func (ui *Ui) LayoutMachine() {
 		if imgui.Button("Open Program") {
 			ui.state = StateFiles
 		}
+		imgui.SameLineV(0, 2)
+		if imgui.Button("Tools") {
+			ui.showToolTable = !ui.showToolTable
+		}
+		if machine != nil {
+			imgui.SameLineV(0, 2)
+			ButtonDisabled("Change tool", state.running, func() {
+				imgui.OpenPopup("change tool")
+			})
+			ui.layoutChangeTool(machine)
+		}
+		imgui.SameLineV(0, 2)
+		if imgui.Button("Probe") {
+			ui.showProbing = !ui.showProbing
+		}
 		if state.program != "" {
 			imgui.SameLineV(0, 2)
 			if imgui.Button("CLOSE") {
 				machine.CloseProgram()
+				ui.programPath = ""
 				ui.programContents = []byte{}
+				ui.programDiagnostics = nil
+				ui.setDiagnostics(nil)
+				ui.programStats = nil
+				ui.programTools = nil
+				ui.segmentMap = nil
+				ui.limitViolations = nil
+				ui.stopPlayback()
 				ui.gcodePreview.NoData()
 			}
 		}
@@ -457,6 +693,9 @@ func (ui *Ui) LayoutMachine() {
 		ButtonDisabled("RUN", state.canRun, func() {
 			ui.services.ActiveMachine.RunProgram("execute", 0)
 		})
+		if state.outOfLimits && imgui.IsItemHoveredV(imgui.HoveredFlagsAllowWhenDisabled) {
+			imgui.SetTooltip("The program moves past the soft limits, see the problems")
+		}
 		imgui.SameLineV(0, 2)
 		ButtonDisabled("PAUSE", state.canPause, func() {
 			ui.services.ActiveMachine.PauseProgram("execute")
@@ -536,6 +775,7 @@ func (ui *Ui) LayoutMachine() {
 				imgui.EndDisabled()
 			}
 
+			ui.layoutSpindle(machine, state)
 		}
 		imgui.EndGroup()
 		imgui.EndChild()
@@ -579,6 +819,37 @@ func (ui *Ui) LayoutMachine() {
 				if imgui.Button("Reset") {
 					ui.gcodePreview.Reset()
 				}
+				imgui.SameLine()
+				ui.layoutViews()
+				imgui.SameLine()
+				if imgui.Checkbox("Trail", &ui.showTrail) {
+					ui.trailVersion = -1
+				}
+				imgui.SameLine()
+				if imgui.Button("Clear trail") {
+					if m := ui.services.ActiveMachine; m != nil && m.Trail != nil {
+						m.Trail.Clear()
+					}
+				}
+				imgui.SameLine()
+				ui.layoutStock()
+				imgui.SameLine()
+				ui.layoutRotaryCenter()
+				if line, changed := ui.gcodePreview.PickedLine(); changed {
+					ui.pickedLine = line + 1
+					ui.scrollToPickedLine = true
+				}
+				if ui.pickedLine > 0 {
+					imgui.SameLine()
+					ButtonDisabled(fmt.Sprintf("Run from line %d", ui.pickedLine), state.canRun, func() {
+						ui.services.ActiveMachine.RunProgram("execute", ui.pickedLine)
+					})
+				}
+				ui.updateTrail()
+				ui.updateMachineModel()
+				if ui.programStats != nil && ui.programStats.TotalTime > 0 {
+					ui.layoutPlayback()
+				}
 				pos := ui.gcodePreview.camera.Position()
 				imgui.Text(fmt.Sprintf("camera: %f %f %f", pos[0], pos[1], pos[2]))
 				target := ui.gcodePreview.cameraControl.Target()
@@ -701,15 +972,7 @@ func (ui *Ui) LayoutMachine() {
 				}
 			}
 
-			TextCenter("Feed Override")
-			imgui.SetNextItemWidth(imgui.ContentRegionAvail().X - 10)
-
-			imgui.SliderFloatV("##fo", &ui.feedOverride, state.minFo, state.maxFo, "%.1f", imgui.SliderFlagsAlwaysClamp)
-
-			TextCenter("Rapid Override")
-			rapidOverride := float32(1.0)
-			imgui.SetNextItemWidth(imgui.ContentRegionAvail().X - 10)
-			imgui.SliderFloatV("##ro", &rapidOverride, state.minFo, state.maxFo, "%.1f", imgui.SliderFlagsAlwaysClamp)
+			ui.layoutOverrides(machine, state)
 
 			TextCenter("Max Velocity")
 			imgui.SetNextItemWidth(imgui.ContentRegionAvail().X - 10)
@@ -736,6 +999,18 @@ func (ui *Ui) LayoutMachine() {
 	}
 	imgui.BeginChildV("Bottom", imgui.Vec2{X: 0, Y: imgui.ContentRegionAvail().Y}, true, imgui.WindowFlagsNone)
 	{
+		if ui.loading {
+			imgui.ProgressBarV(ui.loadProgress.Get(), imgui.Vec2{X: -1, Y: 0}, "Simulating program")
+		}
+		if ui.stockLoading {
+			imgui.ProgressBarV(ui.stockProgress.Get(), imgui.Vec2{X: -1, Y: 0}, "Cutting stock")
+		}
+		if len(ui.diagnostics) > 0 {
+			ui.layoutDiagnostics()
+		}
+		if ui.programStats != nil && !ui.loading {
+			imgui.Text(fmt.Sprintf("Estimated run time: %s", formatDuration(ui.programStats.TotalTime)))
+		}
 		if ui.programContents != nil && len(ui.programContents) > 0 {
 			scanner := bufio.NewScanner(bytes.NewReader(ui.programContents))
 			lines := bytes.Count(ui.programContents, []byte{'\n'})
@@ -745,13 +1020,42 @@ func (ui *Ui) LayoutMachine() {
 			for scanner.Scan() {
 				if state.currentLine == i {
 					imgui.PushStyleColor(imgui.StyleColorText, RGBA(255, 0, 0, 255).V())
+				} else if ui.playLine == i {
+					imgui.PushStyleColor(imgui.StyleColorText, RGBA(255, 204, 51, 255).V())
+				} else if ui.pickedLine == i {
+					imgui.PushStyleColor(imgui.StyleColorText, RGBA(255, 64, 255, 255).V())
+				}
+				if diags, ok := ui.lineDiagnostics[i]; ok && state.currentLine != i {
+					imgui.PushStyleColor(imgui.StyleColorText, diagnosticColor(diags[0].Severity).V())
+					imgui.Text(fmt.Sprintf(fstr, i))
+					imgui.PopStyleColor()
+				} else {
+					imgui.Text(fmt.Sprintf(fstr, i))
+				}
+				if diags, ok := ui.lineDiagnostics[i]; ok && imgui.IsItemHovered() {
+					imgui.BeginTooltip()
+					for _, d := range diags {
+						imgui.Text(d.String())
+					}
+					imgui.EndTooltip()
 				}
-				imgui.Text(fmt.Sprintf(fstr, i))
 				imgui.SameLineV(0, 20)
 				imgui.Text(scanner.Text())
 				if state.currentLine == i {
 					imgui.SetScrollHereY(0.0)
 					imgui.PopStyleColor()
+				} else if ui.playLine == i {
+					if ui.scrollToPlayLine {
+						imgui.SetScrollHereY(0.5)
+						ui.scrollToPlayLine = false
+					}
+					imgui.PopStyleColor()
+				} else if ui.pickedLine == i {
+					if ui.scrollToPickedLine {
+						imgui.SetScrollHereY(0.5)
+						ui.scrollToPickedLine = false
+					}
+					imgui.PopStyleColor()
 				}
 				i++
 			}
@@ -762,6 +1066,10 @@ func (ui *Ui) LayoutMachine() {
 	imgui.EndChild()
 	imgui.End()
 	imgui.PopStyleVar()
+
+	ui.layoutToolTable(machine)
+	ui.layoutToolChange(machine)
+	ui.layoutProbing(machine)
 }
 
 func (ui *Ui) LayoutFiles() {
@@ -848,6 +1156,9 @@ type MachineState struct {
 	motionExec   bool
 	motionPaused bool
 
+	// the loaded program moves past the soft limits
+	outOfLimits bool
+
 	canRun    bool
 	canPause  bool
 	canStep   bool
@@ -861,13 +1172,22 @@ type MachineState struct {
 
 	minFo float32
 	maxFo float32
+	minSo float32
+	maxSo float32
+
+	overrides machine.Overrides
+
+	spindle    machine.SpindleState
+	canSpindle bool
 
 	currentLine int
 	totalLines  int
 	progress    float32
 }
 
-func BuildMachineState(m *machine.Machine) MachineState {
+// BuildMachineState is the state of the machine the controls are shown
+// for. A program past the soft limits of the machine cannot be run
+func BuildMachineState(m *machine.Machine, outOfLimits bool) MachineState {
 	tmp := MachineState{
 		pos:       map[string]string{"X": "0.0", "Y": "0.0", "Z": "0.0"},
 		dtg:       map[string]string{"X": "0.0", "Y": "0.0", "Z": "0.0"},
@@ -876,6 +1196,10 @@ func BuildMachineState(m *machine.Machine) MachineState {
 
 		minFo: 0.3,
 		maxFo: 1.4,
+		minSo: 0.5,
+		maxSo: 1.0,
+
+		overrides: machine.Overrides{Feed: 1, Rapid: 1, Spindle: 1},
 
 		currentLine: -1,
 		totalLines:  -1,
@@ -903,19 +1227,25 @@ func BuildMachineState(m *machine.Machine) MachineState {
 			tmp.g5XOffset = convertPositionToMap(m.GetG5XOffset())
 			tmp.currentLine = int(m.MotionState.GetMotionLine())
 		}
+		tmp.spindle = m.GetSpindleState()
+		tmp.overrides = m.GetOverrides()
 		if configState := m.ConfigState; configState != nil {
 			tmp.minFo = float32(m.ConfigState.GetMinFeedOverride())
 			tmp.maxFo = float32(m.ConfigState.GetMaxFeedOverride())
+			tmp.minSo = float32(m.ConfigState.GetMinSpindleOverride())
+			tmp.maxSo = float32(m.ConfigState.GetMaxSpindleOverride())
 		}
 
 		tmp.program = m.CurrentProgram
 		tmp.running = m.Running()
 
-		tmp.canRun = !(tmp.program != "" && tmp.on && !tmp.running && !tmp.motionExec)
+		tmp.outOfLimits = outOfLimits
+		tmp.canRun = !(tmp.program != "" && tmp.on && !tmp.running && !tmp.motionExec && !tmp.outOfLimits)
 		tmp.canPause = !(tmp.on && tmp.running && !tmp.motionPaused)
 		tmp.canStep = !(tmp.program != "" && tmp.on && tmp.running && (tmp.paused && tmp.motionPaused))
 		tmp.canResume = !(tmp.program != "" && tmp.on && tmp.running && tmp.paused)
 		tmp.canStop = !(tmp.on && tmp.motionExec)
+		tmp.canSpindle = !(tmp.on && !tmp.running)
 
 		if tmp.program != "" && tmp.on && tmp.motionExec {
 			tmp.progress = float32(tmp.currentLine) / float32(tmp.totalLines) * 100.0
@@ -925,20 +1255,584 @@ func BuildMachineState(m *machine.Machine) MachineState {
 	}
 }
 
+// layoutSpindle shows the spindle and the coolant as the machine reports
+// them, with their controls
+func (ui *Ui) layoutSpindle(m *machine.Machine, state MachineState) {
+	TextCenter("Spindle")
+	spindle := state.spindle
+	if spindle.Enabled {
+		imgui.PushStyleColor(imgui.StyleColorText, RGBA(255, 204, 51, 255).V())
+		imgui.Text(fmt.Sprintf("%.0f rpm %s", spindle.Speed, spindle.Direction))
+		imgui.PopStyleColor()
+	} else {
+		imgui.Text("stopped")
+	}
+	if spindle.Brake {
+		imgui.SameLine()
+		imgui.Text("brake")
+	}
+	if m == nil {
+		return
+	}
+
+	if state.canSpindle {
+		imgui.BeginDisabled()
+	}
+	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X - 10)
+	imgui.DragFloatV("##spindleRpm", &ui.spindleRpm, 10, 0, 100000, "%.0f rpm", imgui.SliderFlagsAlwaysClamp)
+	if imgui.ButtonV("CCW", imgui.Vec2{X: 44}) {
+		m.SpindleOn(float64(ui.spindleRpm), machine.SpindleReverse)
+	}
+	imgui.SameLineV(0, 2)
+	if imgui.ButtonV("Stop##spindle", imgui.Vec2{X: 44}) {
+		m.SpindleOff()
+	}
+	imgui.SameLineV(0, 2)
+	if imgui.ButtonV("CW", imgui.Vec2{X: 44}) {
+		m.SpindleOn(float64(ui.spindleRpm), machine.SpindleForward)
+	}
+	ButtonDisabled("-##spindle", !spindle.Enabled, func() {
+		m.SpindleSlower()
+	})
+	imgui.SameLineV(0, 2)
+	ButtonDisabled("+##spindle", !spindle.Enabled, func() {
+		m.SpindleFaster()
+	})
+	imgui.SameLineV(0, 2)
+	brake := spindle.Brake
+	if imgui.Checkbox("Brake", &brake) {
+		m.SpindleBrake(brake)
+	}
+
+	TextCenter("Coolant")
+	flood, mist := spindle.Flood, spindle.Mist
+	if imgui.Checkbox("Flood", &flood) {
+		m.Flood(flood)
+	}
+	imgui.SameLine()
+	if imgui.Checkbox("Mist", &mist) {
+		m.Mist(mist)
+	}
+	if state.canSpindle {
+		imgui.EndDisabled()
+	}
+}
+
+// override is a slider of an override of the machine: the value shown and
+// the last the machine reported
+type override struct {
+	value    float32
+	reported float32
+}
+
+// layout shows the slider, moving it sets the override on the machine.
+// The slider follows the override when the machine reports a change, so
+// that the value set is kept until the machine reports it
+func (o *override) layout(id string, reported float64, min, max float32, set func(float64)) {
+	if r := float32(reported); r != o.reported {
+		o.reported = r
+		o.value = r
+	}
+	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X - 10)
+	if imgui.SliderFloatV(id, &o.value, min, max, "%.2f", imgui.SliderFlagsAlwaysClamp) {
+		set(float64(o.value))
+	}
+}
+
+// layoutOverrides shows the feed, rapid and spindle overrides of the
+// machine, with the switches that let them apply
+func (ui *Ui) layoutOverrides(m *machine.Machine, state MachineState) {
+	if m == nil {
+		imgui.BeginDisabled()
+		defer imgui.EndDisabled()
+	}
+	set := func(f func(*machine.Machine, float64)) func(float64) {
+		return func(scale float64) {
+			if m != nil {
+				f(m, scale)
+			}
+		}
+	}
+	overrides := state.overrides
+
+	TextCenter("Feed Override")
+	ui.feedOverride.layout("##fo", overrides.Feed, state.minFo, state.maxFo, set((*machine.Machine).SetFeedOverride))
+	foEnabled, fhEnabled := overrides.FeedOverrideEnabled, overrides.FeedHoldEnabled
+	if imgui.Checkbox("FO##foEnable", &foEnabled) && m != nil {
+		m.SetFeedOverrideEnable(foEnabled)
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Feed override enabled, M48/M49")
+	}
+	imgui.SameLine()
+	if imgui.Checkbox("Hold##fhEnable", &fhEnabled) && m != nil {
+		m.SetFeedHoldEnable(fhEnabled)
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Feed hold enabled, M53")
+	}
+
+	TextCenter("Rapid Override")
+	ui.rapidOverride.layout("##ro", overrides.Rapid, 0, 1, set((*machine.Machine).SetRapidOverride))
+
+	TextCenter("Spindle Override")
+	ui.spindleOverride.layout("##so", overrides.Spindle, state.minSo, state.maxSo, set((*machine.Machine).SetSpindleOverride))
+	soEnabled := overrides.SpindleOverrideEnabled
+	if imgui.Checkbox("SO##soEnable", &soEnabled) && m != nil {
+		m.SetSpindleOverrideEnable(soEnabled)
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Spindle override enabled, M51")
+	}
+}
+
+// loadRemoteFile downloads and simulates a program in the background, the
+// program asked for last is the one loaded. Its text is kept for the
+// listing, and with the segment map and the vertices of the preview is what
+// the program takes in memory, the simulation itself is streamed
 func (ui *Ui) loadRemoteFile(path string) {
-	contents, err := ui.services.ActiveMachine.DownloadRemoteFile(path)
-	if err != nil {
-		log.Printf("Error downloading file: %+v", err)
+	m := ui.services.ActiveMachine
+	ui.loading = true
+	ui.loadProgress.Set(0)
+	ui.loadGeneration++
+	generation := ui.loadGeneration
+	rotaryCenter := ui.rotaryCenterOf(m)
+	// the status of the machine changes on the frame loop, it is read here
+	tools, units, offsets := simulationSettings(m)
+	limits := machineLimits(m, units)
+	origin := workOrigin(m)
+	go func() {
+		contents, err := m.DownloadRemoteFile(path)
+		if err != nil {
+			log.Printf("Error downloading file: %+v", err)
+			ui.post(func() {
+				if generation == ui.loadGeneration {
+					ui.loading = false
+				}
+			})
+			return
+		}
+		vertices := make([]float32, 0)
+		total := float32(len(contents))
+		accumulator, stats, diagnostics, err := gcode.SimulateGCodeStream(bytes.NewReader(contents), tools, units, offsets, limits, rotaryCenter,
+			func(sf *gcode.SimFragment) {
+				vertices = gcode.AppendVertexData(vertices, sf, true)
+			},
+			func(read int64) {
+				ui.loadProgress.Set(float32(read) / total)
+			},
+		)
+		if err != nil {
+			log.Printf("Error simulating program: %v", err)
+		}
+		ui.post(func() {
+			if generation != ui.loadGeneration {
+				return
+			}
+			ui.loading = false
+			ui.programPath = path
+			ui.programContents = contents
+			ui.programDiagnostics = diagnostics
+			ui.setDiagnostics(diagnostics)
+			ui.programStats = &stats
+			ui.programTools = tools
+			ui.programOrigin = origin
+			ui.segmentMap = accumulator.SegmentMap
+			ui.gcodePreview.SetSegmentMap(accumulator.SegmentMap)
+			ui.limitsChecked = false
+			ui.stopPlayback()
+			ui.gcodePreview.SetUnits(units)
+			ui.gcodePreview.SetData(vertices, stats.Bbox)
+			ui.gcodePreview.SetStock(nil, nil)
+			ui.fitStock()
+		})
+	}()
+}
+
+// simulationSettings are the tools, units and offsets of the machine the
+// programs are simulated with
+func simulationSettings(m *machine.Machine) (gcode.ToolTable, gcode.Units, *gcode.WorkOffsets) {
+	tools := make(gcode.ToolTable)
+	units := gcode.UnitsMM
+	if m == nil {
+		return tools, units, nil
+	}
+	if ioState := m.IoState; ioState != nil {
+		tools = convertToolTable(ioState.GetToolTable())
+	}
+	if configState := m.ConfigState; configState != nil {
+		units = convertLinearUnits(configState.GetLinearUnits())
+	}
+	return tools, units, workOffsets(m)
+}
+
+// fitStock sizes the stock to the program: around its moves, from its
+// lowest point up to the work origin
+func (ui *Ui) fitStock() {
+	if ui.programStats == nil {
+		return
+	}
+	min, max := ui.programStats.Bbox.Min(), ui.programStats.Bbox.Max()
+	top := max[2]
+	if m := ui.services.ActiveMachine; m != nil && m.MotionState != nil {
+		if z := workOrigin(m)[2]; z > min[2] {
+			top = z
+		}
+	}
+	ui.stockMin = [3]float32{min[0], min[1], min[2]}
+	ui.stockMax = [3]float32{max[0], max[1], top}
+}
+
+// layoutStock shows the size of the stock and cuts it with the program
+func (ui *Ui) layoutStock() {
+	if imgui.Button("Stock...") {
+		imgui.OpenPopup("stock")
+	}
+	if !imgui.BeginPopup("stock") {
+		return
+	}
+	imgui.DragFloat3V("Min", &ui.stockMin, 0.1, 0, 0, "%.3f", imgui.SliderFlagsNone)
+	imgui.DragFloat3V("Max", &ui.stockMax, 0.1, 0, 0, "%.3f", imgui.SliderFlagsNone)
+	imgui.DragFloatV("Cell", &ui.stockCell, 0.01, 0, 10, "%.3f", imgui.SliderFlagsAlwaysClamp)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Resolution of the simulation, 0 picks one from the size")
+	}
+	if imgui.Button("Fit to program") {
+		ui.fitStock()
+	}
+	imgui.SameLine()
+	ButtonDisabled("Cut", ui.stockLoading, func() {
+		ui.cutStock()
+		imgui.CloseCurrentPopup()
+	})
+	imgui.SameLine()
+	if imgui.Button("Hide") {
+		ui.gcodePreview.SetStock(nil, nil)
+		imgui.CloseCurrentPopup()
+	}
+	imgui.EndPopup()
+}
+
+// rotaryCenterOf is the point the rotary axes turn about in the simulation
+func (ui *Ui) rotaryCenterOf(m *machine.Machine) mgl32.Vec3 {
+	if ui.rotaryAtOrigin {
+		return workOrigin(m)
+	}
+	return mgl32.Vec3(ui.rotaryCenter)
+}
+
+// layoutRotaryCenter sets the point the rotary axes turn about, and
+// simulates the program again with it
+func (ui *Ui) layoutRotaryCenter() {
+	if imgui.Button("Rotary...") {
+		if ui.rotaryAtOrigin {
+			ui.rotaryCenter = workOrigin(ui.services.ActiveMachine)
+		}
+		imgui.OpenPopup("rotary")
+	}
+	if !imgui.BeginPopup("rotary") {
+		return
+	}
+	imgui.Checkbox("At the work origin", &ui.rotaryAtOrigin)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("The rotary axes turn about the origin of the active coordinate system")
+	}
+	if ui.rotaryAtOrigin {
+		imgui.BeginDisabled()
+	}
+	imgui.DragFloat3V("Center", &ui.rotaryCenter, 0.1, 0, 0, "%.3f", imgui.SliderFlagsNone)
+	if ui.rotaryAtOrigin {
+		imgui.EndDisabled()
+	}
+	ButtonDisabled("Apply", ui.loading || ui.programPath == "", func() {
+		ui.loadRemoteFile(ui.programPath)
+		imgui.CloseCurrentPopup()
+	})
+	imgui.EndPopup()
+}
+
+// cutStock simulates the material the loaded program removes from the stock,
+// in the background
+func (ui *Ui) cutStock() {
+	contents := ui.programContents
+	min := mgl32.Vec3(ui.stockMin)
+	max := mgl32.Vec3(ui.stockMax)
+	for i := 0; i < 3; i++ {
+		if max[i] <= min[i] {
+			log.Printf("Error cutting stock: empty stock between %v and %v", min, max)
+			return
+		}
+	}
+	tools, units, offsets := simulationSettings(ui.services.ActiveMachine)
+	cell := ui.stockCell
+	ui.stockLoading = true
+	ui.stockProgress.Set(0)
+	go func() {
+		stock := gcode.NewStock(min, max, cell)
+		total := float32(len(contents))
+		_, err := gcode.CutStockStream(bytes.NewReader(contents), stock, tools, units, offsets, func(read int64) {
+			ui.stockProgress.Set(float32(read) / total)
+		})
+		if err != nil {
+			log.Printf("Error cutting stock: %v", err)
+		}
+		vertices, indices := stock.Mesh(true)
+		ui.post(func() {
+			ui.stockLoading = false
+			ui.gcodePreview.SetStock(vertices, indices)
+		})
+	}()
+}
+
+var PLAYBACK_SPEEDS = []float32{0.5, 1, 2, 5, 10, 50, 100}
+
+func formatDuration(seconds float32) string {
+	return time.Duration(float64(seconds) * float64(time.Second)).Round(time.Second).String()
+}
+
+// layoutPlayback shows the controls that play the simulated program in the
+// preview, and advances the playback
+func (ui *Ui) layoutPlayback() {
+	total := ui.programStats.TotalTime
+	now := time.Now()
+	if ui.playing {
+		ui.playTime += float32(now.Sub(ui.lastFrame).Seconds()) * ui.playSpeed
+		if ui.playTime >= total {
+			ui.playTime = total
+			ui.playing = false
+		}
+	}
+	ui.lastFrame = now
+
+	label := "Play"
+	if ui.playing {
+		label = "Pause"
+	}
+	if imgui.Button(label) {
+		if !ui.playing && ui.playTime >= total {
+			ui.playTime = 0
+		}
+		ui.playing = !ui.playing
+		ui.playback = true
+	}
+	imgui.SameLine()
+	if imgui.Button("Stop") {
+		ui.stopPlayback()
+	}
+	imgui.SameLine()
+	imgui.PushItemWidth(60)
+	if imgui.BeginCombo("##playSpeed", fmt.Sprintf("%gx", ui.playSpeed)) {
+		for _, speed := range PLAYBACK_SPEEDS {
+			if imgui.SelectableV(fmt.Sprintf("%gx", speed), speed == ui.playSpeed, 0, imgui.Vec2{}) {
+				ui.playSpeed = speed
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.PopItemWidth()
+	imgui.SameLine()
+	imgui.PushItemWidth(-1)
+	timeline := fmt.Sprintf("%s / %s", formatDuration(ui.playTime), formatDuration(total))
+	if imgui.SliderFloatV("##timeline", &ui.playTime, 0, total, timeline, imgui.SliderFlagsAlwaysClamp) {
+		ui.playback = true
+	}
+	imgui.PopItemWidth()
+
+	if !ui.playback {
 		return
 	}
-	ui.programContents = contents
-	fragments, accumulator, stats := gcode.SimulateGCode(string(contents))
-	vertices, bbox := gcode.BuildVertexData(fragments, accumulator, stats, true)
-	ui.gcodePreview.SetData(vertices, bbox)
+	ui.gcodePreview.SetPlayTime(ui.playTime, tableTool(ui.programTools, ui.programStats.ToolAt(ui.playTime)))
+	if line := ui.programStats.LineAt(ui.playTime) + 1; line != ui.playLine {
+		ui.playLine = line
+		ui.scrollToPlayLine = true
+	}
+}
+
+// layoutViews shows the buttons of the standard views of the preview and
+// of the fits, with their keys in the tooltips
+func (ui *Ui) layoutViews() {
+	for preset, view := range VIEW_PRESETS {
+		if preset > 0 {
+			imgui.SameLine()
+		}
+		if imgui.Button(view.Name) {
+			ui.gcodePreview.SetView(ViewPreset(preset))
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip(fmt.Sprintf("%s view (%s)", view.Name, glfw.GetKeyName(view.Key, 0)))
+		}
+	}
+	imgui.SameLine()
+	ortho := ui.gcodePreview.Orthographic()
+	if imgui.Checkbox("Ortho", &ortho) {
+		ui.gcodePreview.SetOrthographic(ortho)
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Orthographic projection (o)")
+	}
+	imgui.SameLine()
+	if imgui.Button("Fit program") {
+		ui.gcodePreview.FitProgram()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Fit the program in the view (Home)")
+	}
+	imgui.SameLine()
+	envelope := machineEnvelope(ui.services.ActiveMachine)
+	bbox := glutil.NewBoundingBox()
+	bbox.PushPoint(envelope.Min)
+	bbox.PushPoint(envelope.Max)
+	radius := bbox.SphereRadius()
+	known := envelope.Min.X() < envelope.Max.X() && envelope.Min.Y() < envelope.Max.Y() && envelope.Min.Z() < envelope.Max.Z() &&
+		!math.IsInf(float64(radius), 0) && !math.IsNaN(float64(radius))
+	ButtonDisabled("Fit machine", !known, func() {
+		ui.gcodePreview.FitBox(bbox)
+	})
+	if imgui.IsItemHoveredV(imgui.HoveredFlagsAllowWhenDisabled) {
+		imgui.SetTooltip("Fit the travel of the machine in the view")
+	}
+}
+
+// updateTrail draws the actual positions of the machine when they change
+func (ui *Ui) updateTrail() {
+	m := ui.services.ActiveMachine
+	if m == nil || m.Trail == nil {
+		return
+	}
+	trail := m.Trail
+	version := trail.Version()
+	if version == ui.trailVersion {
+		return
+	}
+	ui.trailVersion = version
+	if ui.showTrail {
+		ui.gcodePreview.SetTrail(trail.Points())
+	} else {
+		ui.gcodePreview.SetTrail(nil)
+	}
+}
+
+// updateMachineModel moves the tool and the work origin in the preview to
+// where the machine is
+func (ui *Ui) updateMachineModel() {
+	m := ui.services.ActiveMachine
+	if m == nil || m.MotionState == nil {
+		return
+	}
+	pos := m.MotionState.GetActualPosition()
+	ui.gcodePreview.SetMachine(
+		mgl32.Vec3{float32(pos.GetX()), float32(pos.GetY()), float32(pos.GetZ())},
+		spindleTool(m.IoState),
+		workOrigin(m),
+	)
+}
+
+// workOrigin is the origin of the active coordinate system, in machine
+// coordinates
+func workOrigin(m *machine.Machine) mgl32.Vec3 {
+	if m == nil || m.MotionState == nil {
+		return mgl32.Vec3{}
+	}
+	g5x, g92 := m.MotionState.GetG5XOffset(), m.MotionState.GetG92Offset()
+	return mgl32.Vec3{
+		float32(g5x.GetX() + g92.GetX()),
+		float32(g5x.GetY() + g92.GetY()),
+		float32(g5x.GetZ() + g92.GetZ()),
+	}
+}
+
+// machineEnvelope is the travel of X, Y and Z between the soft limits
+func machineEnvelope(m *machine.Machine) gcode.Envelope {
+	envelope := gcode.Envelope{}
+	if m == nil || m.ConfigState == nil {
+		return envelope
+	}
+	for _, axis := range m.ConfigState.GetAxis() {
+		i := int(axis.GetIndex())
+		if i < 0 || i >= len(gcode.ENVELOPE_AXES) {
+			continue
+		}
+		envelope.Min[i] = float32(axis.GetMinPositionLimit())
+		envelope.Max[i] = float32(axis.GetMaxPositionLimit())
+	}
+	return envelope
+}
+
+// checkLimits checks the loaded program against the soft limits of the
+// machine, again when the limits or the work offsets change. The program
+// was simulated at the work offsets it was loaded with
+func (ui *Ui) checkLimits() {
+	m := ui.services.ActiveMachine
+	if ui.segmentMap == nil {
+		return
+	}
+	envelope := machineEnvelope(m)
+	shift := workOrigin(m).Sub(ui.programOrigin)
+	if ui.limitsChecked && envelope == ui.limitEnvelope && shift == ui.limitShift {
+		return
+	}
+	ui.limitsChecked = true
+	ui.limitEnvelope, ui.limitShift = envelope, shift
+	ui.limitViolations = gcode.CheckEnvelope(ui.segmentMap, envelope, shift)
+
+	segments := make([][2]mgl32.Vec3, 0)
+	diagnostics := append([]parser.Diagnostic{}, ui.programDiagnostics...)
+	for _, v := range ui.limitViolations {
+		segments = append(segments, v.Segments...)
+		diagnostics = append(diagnostics, v.Diagnostic())
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	ui.gcodePreview.SetOutOfLimits(segments)
+	ui.setDiagnostics(diagnostics)
+}
+
+func (ui *Ui) stopPlayback() {
+	ui.playback = false
+	ui.playing = false
+	ui.playTime = 0
+	ui.playLine = 0
+	ui.gcodePreview.SetPlayTime(-1, gcode.Tool{})
+}
+
+func (ui *Ui) setDiagnostics(diagnostics []parser.Diagnostic) {
+	byLine := make(map[int][]parser.Diagnostic)
+	for _, d := range diagnostics {
+		byLine[d.Line] = append(byLine[d.Line], d)
+	}
+	ui.diagnostics = diagnostics
+	ui.lineDiagnostics = byLine
+}
+
+func diagnosticColor(severity parser.Severity) Color {
+	if severity == parser.SeverityError {
+		return RGBA(255, 96, 64, 255)
+	}
+	return RGBA(255, 200, 0, 255)
+}
+
+// layoutDiagnostics lists the problems found in the loaded program
+func (ui *Ui) layoutDiagnostics() {
+	errors := 0
+	for _, d := range ui.diagnostics {
+		if d.Severity == parser.SeverityError {
+			errors++
+		}
+	}
+	title := fmt.Sprintf("Problems: %d errors, %d warnings###problems", errors, len(ui.diagnostics)-errors)
+	if imgui.CollapsingHeader(title) {
+		for _, d := range ui.diagnostics {
+			imgui.PushStyleColor(imgui.StyleColorText, diagnosticColor(d.Severity).V())
+			imgui.Text(d.String())
+			imgui.PopStyleColor()
+		}
+		imgui.Separator()
+	}
 }
 
 func (ui *Ui) selectRemoteFile(path string) {
 	rp := ui.services.ActiveMachine.GetRemotePath()
 	ui.services.ActiveMachine.ExecuteProgram(rp + path)
-	go ui.loadRemoteFile(path)
+	ui.loadRemoteFile(path)
 }
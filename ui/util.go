@@ -23,6 +23,16 @@ func ButtonDisabled(txt string, cond bool, cb func()) {
 
 }
 
+// DragFloat64 edits a float64 through a float32 widget, written back only
+// when edited to keep the value as set
+func DragFloat64(label string, value *float64, speed float32) {
+	v := float32(*value)
+	imgui.SetNextItemWidth(150)
+	if imgui.DragFloatV(label, &v, speed, 0, 0, "%.3f", imgui.SliderFlagsNone) {
+		*value = float64(v)
+	}
+}
+
 // func Color(R, G, B, A int) imgui.Vec4 {
 // 	return imgui.Vec4{X: float32(R), Y: float32(G), Z: float32(B), W: float32(A)}
 // }
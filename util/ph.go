@@ -19,3 +19,7 @@ func F32(tmp float32) *float32 {
 func F64(tmp float64) *float64 {
 	return &tmp
 }
+
+func B(tmp bool) *bool {
+	return &tmp
+}